@@ -22,6 +22,41 @@ func NewDict(objs ...JSONPair) *JSONDict {
 	return &dict
 }
 
+// NewOrderedDict returns a dict that keeps its keys in insertion order.
+// Removing a key from an ordered dict scans its keys, so removing many keys
+// from a large ordered dict costs O(n) each.
+func NewOrderedDict(objs ...JSONPair) *JSONDict {
+	dict := &JSONDict{data: make(map[string]JSONObject), ordered: true}
+	for _, o := range objs {
+		dict.Set(o.key, o.val)
+	}
+	return dict
+}
+
+// newDictLike returns an empty dict that is ordered if this is ordered
+func (this *JSONDict) newDictLike() *JSONDict {
+	if this.ordered {
+		return NewOrderedDict()
+	}
+	return NewDict()
+}
+
+// IsOrdered tells whether the dict keeps its keys in insertion order
+func (this *JSONDict) IsOrdered() bool {
+	return this.ordered
+}
+
+// SetOrdered switches the dict between insertion order and sorted order.
+// The keys of a dict switched to insertion order start in sorted order.
+func (this *JSONDict) SetOrdered(ordered bool) {
+	if ordered && !this.ordered {
+		this.keys = this.SortedKeys()
+	} else if !ordered {
+		this.keys = nil
+	}
+	this.ordered = ordered
+}
+
 func NewArray(objs ...JSONObject) *JSONArray {
 	arr := JSONArray{data: make([]JSONObject, 0)}
 	for _, o := range objs {
@@ -50,6 +85,11 @@ func NewBool(val bool) *JSONBool {
 }
 
 func (this *JSONDict) Set(key string, value JSONObject) {
+	if this.ordered {
+		if _, ok := this.data[key]; !ok {
+			this.keys = append(this.keys, key)
+		}
+	}
 	this.data[key] = value
 }
 
@@ -75,6 +115,14 @@ func (this *JSONDict) remove(key string, caseSensitive bool) bool {
 	_, rk, ok := dictGet(this.data, key, caseSensitive)
 	if ok {
 		delete(this.data, rk)
+		if this.ordered {
+			for i, k := range this.keys {
+				if k == rk {
+					this.keys = append(this.keys[:i], this.keys[i+1:]...)
+					break
+				}
+			}
+		}
 		return true
 	} else {
 		return false
@@ -85,11 +133,11 @@ func (this *JSONDict) Add(o JSONObject, keys ...string) error {
 	var obj *JSONDict = this
 	for i := 0; i < len(keys); i++ {
 		if i == len(keys)-1 {
-			obj.data[keys[i]] = o
+			obj.Set(keys[i], o)
 		} else {
 			o, ok := obj.data[keys[i]]
 			if !ok {
-				obj.data[keys[i]] = NewDict()
+				obj.Set(keys[i], obj.newDictLike())
 				o, ok = obj.data[keys[i]]
 			}
 			if ok {
@@ -0,0 +1,172 @@
+package jsonutils
+
+import (
+	"fmt"
+	"sort"
+)
+
+func (this *JSONArray) normalizeIndex(i int) int {
+	if i < 0 {
+		i = len(this.data) + i
+	}
+	return i
+}
+
+// InsertAt inserts objs before the element at index i.  A negative i counts
+// from the end, and i equal to the length appends.
+func (this *JSONArray) InsertAt(i int, objs ...JSONObject) error {
+	idx := this.normalizeIndex(i)
+	if idx < 0 || idx > len(this.data) {
+		return fmt.Errorf("Out of range InsertAt(%d)", i)
+	}
+	data := make([]JSONObject, 0, len(this.data)+len(objs))
+	data = append(data, this.data[:idx]...)
+	data = append(data, objs...)
+	this.data = append(data, this.data[idx:]...)
+	return nil
+}
+
+// RemoveAt removes and returns the element at index i
+func (this *JSONArray) RemoveAt(i int) (JSONObject, error) {
+	idx := this.normalizeIndex(i)
+	if idx < 0 || idx >= len(this.data) {
+		return nil, fmt.Errorf("Out of range RemoveAt(%d)", i)
+	}
+	o := this.data[idx]
+	this.data = append(this.data[:idx], this.data[idx+1:]...)
+	return o, nil
+}
+
+// SetAt replaces the element at index i
+func (this *JSONArray) SetAt(i int, o JSONObject) error {
+	idx := this.normalizeIndex(i)
+	if idx < 0 || idx >= len(this.data) {
+		return fmt.Errorf("Out of range SetAt(%d)", i)
+	}
+	this.data[idx] = o
+	return nil
+}
+
+// Slice returns a new array of the elements from start up to but not
+// including end.  Negative indices count from the end.
+func (this *JSONArray) Slice(start, end int) (*JSONArray, error) {
+	s, e := this.normalizeIndex(start), this.normalizeIndex(end)
+	if s < 0 || e > len(this.data) || s > e {
+		return nil, fmt.Errorf("Out of range Slice(%d, %d)", start, end)
+	}
+	return NewArray(this.data[s:e]...), nil
+}
+
+// Filter returns a new array of the elements for which keep returns true
+func (this *JSONArray) Filter(keep func(o JSONObject) bool) *JSONArray {
+	arr := NewArray()
+	for _, o := range this.data {
+		if keep(o) {
+			arr.data = append(arr.data, o)
+		}
+	}
+	return arr
+}
+
+// Map returns a new array of the results of fn applied to each element
+func (this *JSONArray) Map(fn func(o JSONObject) JSONObject) *JSONArray {
+	arr := NewArray()
+	for _, o := range this.data {
+		arr.data = append(arr.data, fn(o))
+	}
+	return arr
+}
+
+// IndexOf returns the index of the first element that Equals o, or -1
+func (this *JSONArray) IndexOf(o JSONObject) int {
+	for i, e := range this.data {
+		if e.Equals(o) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Unique returns a new array of the elements without those that Equals an
+// earlier element
+func (this *JSONArray) Unique() *JSONArray {
+	arr := NewArray()
+	for _, o := range this.data {
+		dup := false
+		for _, e := range arr.data {
+			if e.Equals(o) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			arr.data = append(arr.data, o)
+		}
+	}
+	return arr
+}
+
+// SortBy returns a new array of the elements sorted stably by the value at
+// path in each element, e.g. spec.priority, or by the elements themselves
+// if path is empty.  Values are ordered null, false, true, numbers,
+// strings, arrays, dicts, and elements lacking path sort as null.
+func (this *JSONArray) SortBy(path string) (*JSONArray, error) {
+	var segs []sPathSegment
+	if len(path) > 0 {
+		var err error
+		segs, err = parsePathSegments(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, seg := range segs {
+			if seg.append {
+				return nil, fmt.Errorf("path %s: [-] cannot be used for sorting", path)
+			}
+		}
+	}
+	keys := make([]JSONObject, len(this.data))
+	for i, o := range this.data {
+		keys[i] = pathValue(o, segs)
+	}
+	idx := make([]int, len(this.data))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return jqCompare(keys[idx[i]], keys[idx[j]]) < 0
+	})
+	arr := NewArray()
+	for _, j := range idx {
+		arr.data = append(arr.data, this.data[j])
+	}
+	return arr, nil
+}
+
+// pathValue returns the value at segs, or JSONNull if it does not exist
+func pathValue(o JSONObject, segs []sPathSegment) JSONObject {
+	for _, seg := range segs {
+		switch v := o.(type) {
+		case *JSONDict:
+			if seg.array {
+				return JSONNull
+			}
+			val, ok := v.data[seg.key]
+			if !ok {
+				return JSONNull
+			}
+			o = val
+		case *JSONArray:
+			if !seg.array {
+				return JSONNull
+			}
+			val, err := v.GetAt(seg.index)
+			if err != nil {
+				return JSONNull
+			}
+			o = val
+		default:
+			return JSONNull
+		}
+	}
+	return o
+}
@@ -0,0 +1,142 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestJSONArrayMutations(t *testing.T) {
+	newArr := func() *JSONArray {
+		return NewArray(NewInt(0), NewInt(1), NewInt(2))
+	}
+	cases := []struct {
+		name string
+		op   func(arr *JSONArray) error
+		want string
+		err  string
+	}{
+		{"insert head", func(a *JSONArray) error { return a.InsertAt(0, NewInt(9)) }, `[9,0,1,2]`, ""},
+		{"insert end", func(a *JSONArray) error { return a.InsertAt(3, NewInt(9), NewInt(8)) }, `[0,1,2,9,8]`, ""},
+		{"insert negative", func(a *JSONArray) error { return a.InsertAt(-1, NewInt(9)) }, `[0,1,9,2]`, ""},
+		{"insert out of range", func(a *JSONArray) error { return a.InsertAt(4, NewInt(9)) }, "", "Out of range InsertAt(4)"},
+		{"remove", func(a *JSONArray) error { _, err := a.RemoveAt(1); return err }, `[0,2]`, ""},
+		{"remove negative", func(a *JSONArray) error { _, err := a.RemoveAt(-1); return err }, `[0,1]`, ""},
+		{"remove out of range", func(a *JSONArray) error { _, err := a.RemoveAt(3); return err }, "", "Out of range RemoveAt(3)"},
+		{"set", func(a *JSONArray) error { return a.SetAt(-3, NewString("x")) }, `["x",1,2]`, ""},
+		{"set out of range", func(a *JSONArray) error { return a.SetAt(-4, NewString("x")) }, "", "Out of range SetAt(-4)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			arr := newArr()
+			err := c.op(arr)
+			if len(c.err) > 0 {
+				if err == nil || err.Error() != c.err {
+					t.Errorf("want error %s, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fail %s", err)
+			}
+			if arr.String() != c.want {
+				t.Errorf("want %s, got %s", c.want, arr)
+			}
+		})
+	}
+
+	arr := newArr()
+	o, _ := arr.RemoveAt(0)
+	if !o.Equals(NewInt(0)) {
+		t.Errorf("RemoveAt should return the removed element, got %s", o)
+	}
+}
+
+func TestJSONArraySlice(t *testing.T) {
+	arr := NewArray(NewInt(0), NewInt(1), NewInt(2), NewInt(3))
+	for _, c := range []struct {
+		start, end int
+		want       string
+	}{
+		{0, 4, `[0,1,2,3]`},
+		{1, 3, `[1,2]`},
+		{-2, 4, `[2,3]`},
+		{0, -1, `[0,1,2]`},
+		{2, 2, `[]`},
+	} {
+		got, err := arr.Slice(c.start, c.end)
+		if err != nil {
+			t.Errorf("Slice(%d, %d) fail %s", c.start, c.end, err)
+		} else if got.String() != c.want {
+			t.Errorf("Slice(%d, %d) want %s, got %s", c.start, c.end, c.want, got)
+		}
+	}
+	for _, c := range [][2]int{{0, 5}, {-5, 2}, {3, 1}} {
+		if _, err := arr.Slice(c[0], c[1]); err == nil {
+			t.Errorf("Slice(%d, %d) should fail", c[0], c[1])
+		}
+	}
+	sub, _ := arr.Slice(0, 2)
+	sub.Add(NewInt(9))
+	if arr.String() != `[0,1,2,3]` {
+		t.Errorf("Slice result should not share storage, got %s", arr)
+	}
+}
+
+func TestJSONArrayFunctional(t *testing.T) {
+	arr, _ := ParseString(`[1, "a", 2, {"b": 1}, "a", 1]`)
+	a := arr.(*JSONArray)
+	nums := a.Filter(func(o JSONObject) bool {
+		_, ok := o.(*JSONInt)
+		return ok
+	})
+	if nums.String() != `[1,2,1]` {
+		t.Errorf("Filter got %s", nums)
+	}
+	doubled := nums.Map(func(o JSONObject) JSONObject {
+		i, _ := o.Int()
+		return NewInt(i * 2)
+	})
+	if doubled.String() != `[2,4,2]` || nums.String() != `[1,2,1]` {
+		t.Errorf("Map got %s from %s", doubled, nums)
+	}
+	if a.IndexOf(NewString("a")) != 1 || a.IndexOf(NewDict(JSONPair{key: "b", val: NewInt(1)})) != 3 || a.IndexOf(NewInt(3)) != -1 {
+		t.Errorf("unexpected IndexOf")
+	}
+	unique := a.Unique()
+	if unique.String() != `[1,"a",2,{"b":1}]` || a.Length() != 6 {
+		t.Errorf("Unique got %s from %s", unique, a)
+	}
+}
+
+func TestJSONArraySortBy(t *testing.T) {
+	arr, _ := ParseString(`[
+		{"name": "c", "spec": {"priority": 2}},
+		{"name": "a", "spec": {"priority": 1}},
+		{"name": "d"},
+		{"name": "b", "spec": {"priority": 1}}
+	]`)
+	a := arr.(*JSONArray)
+	orig := a.String()
+	sorted, err := a.SortBy("spec.priority")
+	if err != nil {
+		t.Fatalf("SortBy fail %s", err)
+	}
+	names := sorted.Map(func(o JSONObject) JSONObject {
+		name, _ := o.Get("name")
+		return name
+	})
+	if names.String() != `["d","a","b","c"]` {
+		t.Errorf("SortBy got %s", names)
+	}
+	if a.String() != orig {
+		t.Errorf("SortBy changed the array to %s", a)
+	}
+	if self, _ := names.SortBy(""); self.String() != `["a","b","c","d"]` {
+		t.Errorf("SortBy self got %s", self)
+	}
+	if _, err := a.SortBy("spec..x"); err == nil {
+		t.Errorf("malformed path should fail")
+	}
+	if _, err := a.SortBy("tags[-]"); err == nil {
+		t.Errorf("append marker should fail")
+	}
+}
@@ -11,24 +11,24 @@ func (this *JSONDict) Copy(excludes ...string) *JSONDict {
 }
 
 func (this *JSONDict) CopyExcludes(excludes ...string) *JSONDict {
-	dict := NewDict()
-	for k, v := range this.data {
+	dict := this.newDictLike()
+	this.eachKey(false, func(k string, v JSONObject) {
 		exists, _ := utils.InStringArray(k, excludes)
 		if !exists {
-			dict.data[k] = v
+			dict.Set(k, v)
 		}
-	}
+	})
 	return dict
 }
 
 func (this *JSONDict) CopyIncludes(includes ...string) *JSONDict {
-	dict := NewDict()
-	for k, v := range this.data {
+	dict := this.newDictLike()
+	this.eachKey(false, func(k string, v JSONObject) {
 		exists, _ := utils.InStringArray(k, includes)
 		if exists {
-			dict.data[k] = v
+			dict.Set(k, v)
 		}
-	}
+	})
 	return dict
 }
 
@@ -91,13 +91,14 @@ func DeepCopy(obj JSONObject) JSONObject {
 		vc := NewArray(elemsC...)
 		return vc
 	case *JSONDict:
-		vc := NewDict()
-		m, _ := v.GetMap()
-		for mk, mv := range m {
+		vc := v.newDictLike()
+		v.eachKey(false, func(mk string, mv JSONObject) {
 			mvc := DeepCopy(mv)
 			vc.Set(mk, mvc)
-		}
+		})
 		return vc
+	case *JSONValue:
+		return JSONNull
 	}
 	return nil
 }
@@ -0,0 +1,81 @@
+package jsonutils
+
+/**
+jsonutils.FillDefaults
+
+Fill struct fields with the value of their default tag, e.g.
+
+	type SConfig struct {
+		Port    int    `default:"8080"`
+		Enabled bool   `default:"true"`
+		Region  string `default:"default"`
+	}
+
+The tag text is converted into the field type with the same rules
+used when unmarshaling a JSONString.  Defaults are applied only to fields
+that still hold the zero value.
+
+*/
+
+import (
+	"fmt"
+	"reflect"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/reflectutils"
+)
+
+// FillDefaults applies default tags of obj, and of the structs nested in it,
+// as if obj were unmarshaled from an empty JSONDict.
+func FillDefaults(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(obj))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("FillDefaults: %s is not a struct", value.Type())
+	}
+	if !value.CanSet() {
+		return fmt.Errorf("FillDefaults: %s is not addressable", value.Type())
+	}
+	return fillStructDefaults(value)
+}
+
+func fillStructDefaults(val reflect.Value) error {
+	fieldValues := reflectutils.FetchStructFieldValueSetForWrite(val)
+	for i := range fieldValues {
+		err := fillFieldDefault(&fieldValues[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fillFieldDefault(field *reflectutils.SStructFieldValue) error {
+	if field.Info.Ignore || !field.Value.CanSet() {
+		return nil
+	}
+	defVal, ok := field.Info.Tags["default"]
+	if ok {
+		if !field.Value.IsZero() {
+			return nil
+		}
+		err := NewString(defVal).unmarshalValue(field.Value)
+		if err != nil {
+			return fmt.Errorf("invalid default %q for field %s: %s", defVal, field.Info.FieldName, err)
+		}
+		return nil
+	}
+	fieldValue := field.Value
+	if fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			return nil
+		}
+		fieldValue = fieldValue.Elem()
+	}
+	if fieldValue.Kind() == reflect.Struct && fieldValue.Type() != gotypes.TimeType {
+		return fillStructDefaults(fieldValue)
+	}
+	return nil
+}
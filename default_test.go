@@ -0,0 +1,87 @@
+package jsonutils
+
+import (
+	"testing"
+
+	"yunion.io/x/pkg/tristate"
+)
+
+type defaultSubStruct struct {
+	Interval int    `default:"30"`
+	Mode     string `default:"auto"`
+}
+
+type defaultStruct struct {
+	Name    string            `default:"noname"`
+	Port    int               `default:"8080"`
+	Ratio   float64           `default:"0.5"`
+	Enabled bool              `default:"true"`
+	Tri     tristate.TriState `default:"false"`
+	Count   *int              `default:"3"`
+	Plain   string
+	Sub     defaultSubStruct
+	SubPtr  *defaultSubStruct
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	json, _ := ParseString(`{"name": "test", "sub": {"mode": "manual"}, "sub_ptr": {}}`)
+	s := defaultStruct{}
+	err := json.Unmarshal(&s)
+	if err != nil {
+		t.Fatalf("unmarshal fail %s", err)
+	}
+	if s.Name != "test" {
+		t.Errorf("name should not be overridden by default: %s", s.Name)
+	}
+	if s.Port != 8080 || s.Ratio != 0.5 || !s.Enabled || !s.Tri.IsFalse() {
+		t.Errorf("scalar defaults not applied: %s", Marshal(s))
+	}
+	if s.Count == nil || *s.Count != 3 {
+		t.Errorf("pointer default not applied")
+	}
+	if s.Plain != "" {
+		t.Errorf("field without default should stay zero: %s", s.Plain)
+	}
+	if s.Sub.Interval != 30 || s.Sub.Mode != "manual" {
+		t.Errorf("nested defaults wrong: %#v", s.Sub)
+	}
+	if s.SubPtr == nil || s.SubPtr.Interval != 30 || s.SubPtr.Mode != "auto" {
+		t.Errorf("nested pointer defaults wrong: %#v", s.SubPtr)
+	}
+}
+
+func TestUnmarshalDefaultsExplicitZero(t *testing.T) {
+	json, _ := ParseString(`{"port": 0, "enabled": false}`)
+	s := defaultStruct{}
+	err := json.Unmarshal(&s)
+	if err != nil {
+		t.Fatalf("unmarshal fail %s", err)
+	}
+	if s.Port != 0 || s.Enabled {
+		t.Errorf("explicit values should win over defaults: %d %v", s.Port, s.Enabled)
+	}
+}
+
+func TestFillDefaults(t *testing.T) {
+	s := defaultStruct{Port: 80}
+	err := FillDefaults(&s)
+	if err != nil {
+		t.Fatalf("FillDefaults fail %s", err)
+	}
+	if s.Name != "noname" || s.Port != 80 || s.Sub.Interval != 30 || s.Sub.Mode != "auto" {
+		t.Errorf("FillDefaults wrong: %#v", s)
+	}
+	if s.SubPtr != nil {
+		t.Errorf("FillDefaults should not allocate nil struct pointers")
+	}
+
+	type badDefault struct {
+		Port int `default:"abc"`
+	}
+	if err := FillDefaults(&badDefault{}); err == nil {
+		t.Errorf("invalid default should fail")
+	}
+	if err := FillDefaults(badDefault{}); err == nil {
+		t.Errorf("non-pointer struct should fail")
+	}
+}
@@ -5,9 +5,10 @@ func (dict *JSONDict) Update(json JSONObject) {
 	if !ok {
 		return
 	}
-	for k, v := range dict2.data {
-		dict.data[k] = v
-	}
+	// an ordered dict adds the new keys in the order of dict2
+	dict2.eachKey(dict.ordered, func(k string, v JSONObject) {
+		dict.Set(k, v)
+	})
 }
 
 func (dict *JSONDict) UpdateDefault(json JSONObject) {
@@ -15,9 +16,9 @@ func (dict *JSONDict) UpdateDefault(json JSONObject) {
 	if !ok {
 		return
 	}
-	for k, v := range dict2.data {
+	dict2.eachKey(dict.ordered, func(k string, v JSONObject) {
 		if _, ok := dict.data[k]; !ok {
-			dict.data[k] = v
+			dict.Set(k, v)
 		}
-	}
+	})
 }
@@ -0,0 +1,77 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestOrderedDict(t *testing.T) {
+	dict := NewOrderedDict(JSONPair{key: "name", val: NewString("web")})
+	dict.Set("replicas", NewInt(3))
+	dict.Add(NewString("nginx"), "spec", "image")
+	dict.Add(NewInt(80), "spec", "port")
+	dict.Set("apiVersion", NewString("v1"))
+	dict.Set("name", NewString("api"))
+	want := `{"name":"api","replicas":3,"spec":{"image":"nginx","port":80},"apiVersion":"v1"}`
+	if dict.String() != want {
+		t.Errorf("want %s, got %s", want, dict)
+	}
+	dict.Remove("replicas")
+	dict.Set("replicas", NewInt(1))
+	if got := NewStringArray(dict.Keys()).String(); got != `["name","spec","apiVersion","replicas"]` {
+		t.Errorf("unexpected keys %s", got)
+	}
+	if !dict.IsOrdered() || NewDict().IsOrdered() {
+		t.Errorf("unexpected IsOrdered")
+	}
+	copied := DeepCopy(dict).(*JSONDict)
+	if copied.String() != dict.String() || !copied.IsOrdered() {
+		t.Errorf("DeepCopy should keep the order, got %s", copied)
+	}
+	if c := dict.CopyExcludes("spec"); c.String() != `{"name":"api","apiVersion":"v1","replicas":1}` {
+		t.Errorf("CopyExcludes should keep the order, got %s", c)
+	}
+	dict.SetOrdered(false)
+	if dict.String() != `{"apiVersion":"v1","name":"api","replicas":1,"spec":{"image":"nginx","port":80}}` {
+		t.Errorf("unordered dict should sort keys, got %s", dict)
+	}
+	dict.SetOrdered(true)
+	dict.Set("kind", NewString("Pod"))
+	if got := NewStringArray(dict.Keys()).String(); got != `["apiVersion","name","replicas","spec","kind"]` {
+		t.Errorf("unexpected keys %s", got)
+	}
+}
+
+func TestParseOrdered(t *testing.T) {
+	src := `{"kind": "Deployment", "metadata": {"name": "web", "labels": {"z": "1", "a": "2"}}, "spec": [{"b": 1, "a": 2}], "kind": "Pod"}`
+	obj, err := ParseOrderedString(src)
+	if err != nil {
+		t.Fatalf("parse fail %s", err)
+	}
+	want := `{"kind":"Pod","metadata":{"name":"web","labels":{"z":"1","a":"2"}},"spec":[{"b":1,"a":2}]}`
+	if obj.String() != want {
+		t.Errorf("want %s, got %s", want, obj)
+	}
+	wantYAML := `kind: Pod
+metadata:
+  name: web
+  labels:
+    z: '1'
+    a: '2'
+spec:
+  - b: 1
+    a: 2`
+	if obj.YAMLString() != wantYAML {
+		t.Errorf("want yaml\n%s\ngot\n%s", wantYAML, obj.YAMLString())
+	}
+	sorted, _ := ParseString(src)
+	if sorted.(*JSONDict).IsOrdered() || !sorted.Equals(obj) {
+		t.Errorf("ordered and unordered dicts of the same content should be equal")
+	}
+	if _, err := ParseOrderedString(`{"a" 1}`); err == nil {
+		t.Errorf("invalid json should fail")
+	}
+	merged := MergePatch(obj, NewDict(JSONPair{key: "apiVersion", val: NewString("apps/v1")}))
+	if merged.(*JSONDict).Keys()[3] != "apiVersion" {
+		t.Errorf("MergePatch should append new keys to an ordered dict, got %s", merged)
+	}
+}
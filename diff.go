@@ -0,0 +1,274 @@
+package jsonutils
+
+/**
+jsonutils.Diff
+
+Report the differences between two JSONObject trees, e.g.
+
+	for _, c := range Diff(expected, actual) {
+		log.Infof("%s %s: %s -> %s", c.Kind, c.Path, c.Old, c.New)
+	}
+
+Paths are JSON pointers.  FormatDiff renders the changes in the style of a
+unified diff.
+
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DiffKind string
+
+const (
+	DIFF_ADDED        = DiffKind("added")
+	DIFF_REMOVED      = DiffKind("removed")
+	DIFF_MODIFIED     = DiffKind("modified")
+	DIFF_TYPE_CHANGED = DiffKind("type-changed")
+)
+
+type Change struct {
+	// Path is the JSON pointer of the change.  Removed and modified array
+	// elements are addressed by their index in the old array, added ones by
+	// their index in the new array.
+	Path string
+	Kind DiffKind
+	// Old is nil for added values
+	Old JSONObject
+	// New is nil for removed values
+	New JSONObject
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case DIFF_ADDED:
+		return fmt.Sprintf("%s %s: %s", c.Kind, c.Path, c.New)
+	case DIFF_REMOVED:
+		return fmt.Sprintf("%s %s: %s", c.Kind, c.Path, c.Old)
+	}
+	return fmt.Sprintf("%s %s: %s -> %s", c.Kind, c.Path, c.Old, c.New)
+}
+
+type DiffOptions struct {
+	// IgnorePaths are JSON pointers of subtrees excluded from the
+	// comparison.  A * token matches any key or index, e.g. /items/*/uid
+	IgnorePaths []string
+	// UnorderedArrays compares arrays as multisets
+	UnorderedArrays bool
+	// NumericEquality compares JSONInt and JSONFloat by value, so 1 equals
+	// 1.0 and 1 versus 2.5 is a modification rather than a type change
+	NumericEquality bool
+}
+
+// Diff returns the changes turning a into b, with the default options
+func Diff(a, b JSONObject) []Change {
+	changes, _ := DiffWithOptions(a, b, nil)
+	return changes
+}
+
+// DiffWithOptions returns the changes turning a into b.  It fails only if
+// opts contains a malformed ignore path.
+func DiffWithOptions(a, b JSONObject, opts *DiffOptions) ([]Change, error) {
+	d := &differ{}
+	if opts != nil {
+		d.opts = *opts
+	}
+	for _, path := range d.opts.IgnorePaths {
+		tokens, err := ParseJSONPointer(path)
+		if err != nil {
+			return nil, err
+		}
+		d.ignores = append(d.ignores, tokens)
+	}
+	changes := make([]Change, 0)
+	d.diff(&changes, a, b, []string{})
+	return changes, nil
+}
+
+type differ struct {
+	opts    DiffOptions
+	ignores [][]string
+}
+
+func (d *differ) ignored(tokens []string) bool {
+	for _, pattern := range d.ignores {
+		if matchPointerPattern(pattern, tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) equal(a, b JSONObject, tokens []string) bool {
+	changes := make([]Change, 0)
+	d.diff(&changes, a, b, tokens)
+	return len(changes) == 0
+}
+
+func (d *differ) diff(changes *[]Change, a, b JSONObject, tokens []string) {
+	if d.ignored(tokens) {
+		return
+	}
+	change := func(kind DiffKind) {
+		*changes = append(*changes, Change{Path: JSONPointer(tokens...), Kind: kind, Old: a, New: b})
+	}
+	if na, ok := jsonNumber(a); ok && d.opts.NumericEquality {
+		if nb, ok := jsonNumber(b); ok {
+			if na != nb {
+				change(DIFF_MODIFIED)
+			}
+			return
+		}
+	}
+	if jsonTypeName(a) != jsonTypeName(b) {
+		change(DIFF_TYPE_CHANGED)
+		return
+	}
+	switch va := a.(type) {
+	case *JSONDict:
+		vb := b.(*JSONDict)
+		for _, k := range va.SortedKeys() {
+			sub := subPointer(tokens, k)
+			if v2, ok := vb.data[k]; ok {
+				d.diff(changes, va.data[k], v2, sub)
+			} else if !d.ignored(sub) {
+				*changes = append(*changes, Change{Path: JSONPointer(sub...), Kind: DIFF_REMOVED, Old: va.data[k]})
+			}
+		}
+		for _, k := range vb.SortedKeys() {
+			sub := subPointer(tokens, k)
+			if _, ok := va.data[k]; !ok && !d.ignored(sub) {
+				*changes = append(*changes, Change{Path: JSONPointer(sub...), Kind: DIFF_ADDED, New: vb.data[k]})
+			}
+		}
+	case *JSONArray:
+		vb := b.(*JSONArray)
+		if d.opts.UnorderedArrays {
+			d.diffUnorderedArray(changes, va.data, vb.data, tokens)
+		} else {
+			d.diffArray(changes, va.data, vb.data, tokens)
+		}
+	default:
+		if !a.Equals(b) {
+			change(DIFF_MODIFIED)
+		}
+	}
+}
+
+func (d *differ) arrayChange(changes *[]Change, kind DiffKind, tokens []string, idx int, val JSONObject) {
+	sub := subPointer(tokens, fmt.Sprintf("%d", idx))
+	if d.ignored(sub) {
+		return
+	}
+	change := Change{Path: JSONPointer(sub...), Kind: kind}
+	if kind == DIFF_ADDED {
+		change.New = val
+	} else {
+		change.Old = val
+	}
+	*changes = append(*changes, change)
+}
+
+// diffArray aligns the elements with the edit distance of the arrays, like
+// createArrayPatch, so that an insertion is not reported as modifications
+// of all the following elements
+func (d *differ) diffArray(changes *[]Change, from, to []JSONObject, tokens []string) {
+	n, m := len(from), len(to)
+	equal := make([][]bool, n)
+	for i := range equal {
+		equal[i] = make([]bool, m)
+		for j := range equal[i] {
+			equal[i][j] = d.equal(from[i], to[j], subPointer(tokens, fmt.Sprintf("%d", i)))
+		}
+	}
+	dist := make([][]int, n+1)
+	for i := range dist {
+		dist[i] = make([]int, m+1)
+	}
+	for i := n; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			switch {
+			case i == n:
+				dist[i][j] = m - j
+			case j == m:
+				dist[i][j] = n - i
+			case equal[i][j]:
+				dist[i][j] = dist[i+1][j+1]
+			default:
+				dist[i][j] = 1 + minInt(dist[i+1][j+1], minInt(dist[i+1][j], dist[i][j+1]))
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal[i][j] && dist[i][j] == dist[i+1][j+1]:
+			i, j = i+1, j+1
+		case i < n && j < m && dist[i][j] == dist[i+1][j+1]+1:
+			d.diff(changes, from[i], to[j], subPointer(tokens, fmt.Sprintf("%d", i)))
+			i, j = i+1, j+1
+		case i < n && dist[i][j] == dist[i+1][j]+1:
+			d.arrayChange(changes, DIFF_REMOVED, tokens, i, from[i])
+			i++
+		default:
+			d.arrayChange(changes, DIFF_ADDED, tokens, j, to[j])
+			j++
+		}
+	}
+}
+
+func (d *differ) diffUnorderedArray(changes *[]Change, from, to []JSONObject, tokens []string) {
+	matchOf := matchElements(len(from), len(to), func(i, j int) bool {
+		return d.equal(from[i], to[j], subPointer(tokens, fmt.Sprintf("%d", i)))
+	})
+	matched := make([]bool, len(from))
+	for _, i := range matchOf {
+		if i >= 0 {
+			matched[i] = true
+		}
+	}
+	for i, v := range from {
+		if !matched[i] {
+			d.arrayChange(changes, DIFF_REMOVED, tokens, i, v)
+		}
+	}
+	for j, v := range to {
+		if matchOf[j] < 0 {
+			d.arrayChange(changes, DIFF_ADDED, tokens, j, v)
+		}
+	}
+}
+
+// FormatDiff renders changes in the style of a unified diff, with a hunk
+// for each change showing the old value prefixed by - and the new value
+// prefixed by +
+func FormatDiff(changes []Change, fromName, toName string) string {
+	var buf strings.Builder
+	if len(changes) == 0 {
+		return ""
+	}
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for _, c := range changes {
+		path := c.Path
+		if len(path) == 0 {
+			path = "/"
+		}
+		fmt.Fprintf(&buf, "@@ %s %s @@\n", path, c.Kind)
+		if c.Old != nil {
+			writeDiffLines(&buf, "-", c.Old)
+		}
+		if c.New != nil {
+			writeDiffLines(&buf, "+", c.New)
+		}
+	}
+	return buf.String()
+}
+
+func writeDiffLines(buf *strings.Builder, prefix string, val JSONObject) {
+	for _, line := range strings.Split(val.PrettyString(), "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
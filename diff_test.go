@@ -0,0 +1,131 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		opts *DiffOptions
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    `{"a": [1, {"b": "c"}]}`,
+			b:    `{"a": [1, {"b": "c"}]}`,
+			want: []string{},
+		},
+		{
+			name: "dict",
+			a:    `{"a": 1, "b": "x", "c": {"d": true}, "e": null}`,
+			b:    `{"b": "y", "c": {"d": false, "f": 1}, "e": 1, "g": []}`,
+			want: []string{
+				"removed /a: 1",
+				`modified /b: "x" -> "y"`,
+				"modified /c/d: true -> false",
+				"added /c/f: 1",
+				"type-changed /e: null -> 1",
+				"added /g: []",
+			},
+		},
+		{
+			name: "array insertion",
+			a:    `[1, 2, 3]`,
+			b:    `[0, 1, 2, 4]`,
+			want: []string{
+				"added /0: 0",
+				"modified /2: 3 -> 4",
+			},
+		},
+		{
+			name: "array element changed",
+			a:    `[{"name": "a", "cpu": 1}, {"name": "b", "cpu": 1}]`,
+			b:    `[{"name": "a", "cpu": 1}, {"name": "b", "cpu": 2}]`,
+			want: []string{"modified /1/cpu: 1 -> 2"},
+		},
+		{
+			name: "numbers are typed by default",
+			a:    `{"a": 1, "b": 2}`,
+			b:    `{"a": 1.0, "b": 2.5}`,
+			want: []string{"type-changed /a: 1 -> 1.000000", "type-changed /b: 2 -> 2.500000"},
+		},
+		{
+			name: "numeric equality",
+			a:    `{"a": 1, "b": 2}`,
+			b:    `{"a": 1.0, "b": 2.5}`,
+			opts: &DiffOptions{NumericEquality: true},
+			want: []string{"modified /b: 2 -> 2.500000"},
+		},
+		{
+			name: "unordered arrays",
+			a:    `{"tags": ["a", "b", "c", "c"]}`,
+			b:    `{"tags": ["c", "b", "d", "a"]}`,
+			opts: &DiffOptions{UnorderedArrays: true},
+			want: []string{`removed /tags/3: "c"`, `added /tags/2: "d"`},
+		},
+		{
+			name: "unordered arrays beyond a greedy matching",
+			a:    `[{"x": 1, "y": 1}, {"x": 2, "y": 1}]`,
+			b:    `[{"x": 2, "y": 1}, {"x": 1, "y": 1}]`,
+			opts: &DiffOptions{UnorderedArrays: true, IgnorePaths: []string{"/0/x"}},
+			want: []string{},
+		},
+		{
+			name: "ignore paths",
+			a:    `{"meta": {"uid": 1, "name": "x"}, "items": [{"id": 1, "v": 1}], "ts": 1}`,
+			b:    `{"meta": {"uid": 2, "name": "x"}, "items": [{"id": 2, "v": 1}]}`,
+			opts: &DiffOptions{IgnorePaths: []string{"/meta/uid", "/items/*/id", "/ts"}},
+			want: []string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, _ := ParseString(c.a)
+			b, _ := ParseString(c.b)
+			changes, err := DiffWithOptions(a, b, c.opts)
+			if err != nil {
+				t.Fatalf("diff fail %s", err)
+			}
+			got := make([]string, len(changes))
+			for i := range changes {
+				got[i] = changes[i].String()
+			}
+			if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("want\n%s\ngot\n%s", strings.Join(c.want, "\n"), strings.Join(got, "\n"))
+			}
+			if c.opts != nil {
+				opts := &EqualsOptions{IgnorePaths: c.opts.IgnorePaths, UnorderedArrays: c.opts.UnorderedArrays, NumericEquality: c.opts.NumericEquality}
+				if equal := EqualsWithOptions(a, b, opts); equal != (len(changes) == 0) {
+					t.Errorf("EqualsWithOptions = %v with %d changes", equal, len(changes))
+				}
+			}
+		})
+	}
+	if _, err := DiffWithOptions(JSONNull, JSONNull, &DiffOptions{IgnorePaths: []string{"a"}}); err == nil {
+		t.Errorf("malformed ignore path should fail")
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	a, _ := ParseString(`{"replicas": 1, "ports": [80]}`)
+	b, _ := ParseString(`{"replicas": 3, "ports": [80, 443]}`)
+	want := `--- expected
++++ actual
+@@ /ports/1 added @@
++443
+@@ /replicas modified @@
+-1
++3
+`
+	got := FormatDiff(Diff(a, b), "expected", "actual")
+	if got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+	if FormatDiff(Diff(a, a), "expected", "actual") != "" {
+		t.Errorf("no changes should render empty")
+	}
+}
@@ -1,5 +1,11 @@
 package jsonutils
 
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
 // import "yunion.io/x/pkg/gotypes"
 
 func (dict *JSONDict) Equals(json JSONObject) bool {
@@ -77,3 +83,270 @@ func (o *JSONValue) Equals(json JSONObject) bool {
 	}
 	return true
 }
+
+func jsonNumber(o JSONObject) (float64, bool) {
+	switch v := o.(type) {
+	case *JSONInt:
+		return float64(v.data), true
+	case *JSONFloat:
+		return v.data, true
+	}
+	return 0, false
+}
+
+// jsonValueEquals is like Equals, except that JSONInt and JSONFloat
+// are equal when they hold the same number, as JSON does not tell
+// integers from floats
+func jsonValueEquals(a, b JSONObject) bool {
+	if na, ok := jsonNumber(a); ok {
+		nb, ok := jsonNumber(b)
+		return ok && na == nb
+	}
+	switch va := a.(type) {
+	case *JSONDict:
+		vb, ok := b.(*JSONDict)
+		if !ok || len(va.data) != len(vb.data) {
+			return false
+		}
+		for k, v := range va.data {
+			v2, ok := vb.data[k]
+			if !ok || !jsonValueEquals(v, v2) {
+				return false
+			}
+		}
+		return true
+	case *JSONArray:
+		vb, ok := b.(*JSONArray)
+		if !ok || len(va.data) != len(vb.data) {
+			return false
+		}
+		for i, v := range va.data {
+			if !jsonValueEquals(v, vb.data[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equals(b)
+}
+
+type EqualsOptions struct {
+	// NumericEquality compares JSONInt, JSONFloat and JSONString holding
+	// a number by value, so 1, 1.0 and "1" are equal
+	NumericEquality bool
+	// FloatEpsilon is the largest difference of numbers deemed equal, when
+	// one of them is a JSONFloat or a string.  Two JSONInt are only equal
+	// if they hold the same integer.
+	FloatEpsilon float64
+	// UnorderedArrays compares arrays as multisets
+	UnorderedArrays bool
+	// CaseInsensitiveKeys matches dict keys, and keys in IgnorePaths,
+	// regardless of case
+	CaseInsensitiveKeys bool
+	// NullEqualsMissing treats a null value as equal to a missing key
+	NullEqualsMissing bool
+	// IgnorePaths are JSON pointers of subtrees excluded from the
+	// comparison.  A * token matches any key or index, e.g. /items/*/uid.
+	// Malformed pointers match nothing.
+	IgnorePaths []string
+}
+
+// EqualsWithOptions tells whether a and b are equal under the relaxed
+// rules of opts.  With nil opts, it is the same as a.Equals(b).
+func EqualsWithOptions(a, b JSONObject, opts *EqualsOptions) bool {
+	if opts == nil {
+		return a.Equals(b)
+	}
+	cmp := &equalsComparer{opts: opts}
+	for _, path := range opts.IgnorePaths {
+		tokens, err := ParseJSONPointer(path)
+		if err != nil {
+			continue
+		}
+		cmp.ignores = append(cmp.ignores, cmp.normalizeTokens(tokens))
+	}
+	return cmp.equals(a, b, []string{})
+}
+
+type equalsComparer struct {
+	opts    *EqualsOptions
+	ignores [][]string
+}
+
+func (cmp *equalsComparer) normalizeTokens(tokens []string) []string {
+	if !cmp.opts.CaseInsensitiveKeys {
+		return tokens
+	}
+	lower := make([]string, len(tokens))
+	for i := range tokens {
+		lower[i] = strings.ToLower(tokens[i])
+	}
+	return lower
+}
+
+func (cmp *equalsComparer) ignored(tokens []string) bool {
+	if len(cmp.ignores) == 0 {
+		return false
+	}
+	tokens = cmp.normalizeTokens(tokens)
+	for _, pattern := range cmp.ignores {
+		if matchPointerPattern(pattern, tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp *equalsComparer) number(o JSONObject) (float64, bool) {
+	if n, ok := jsonNumber(o); ok {
+		return n, true
+	}
+	if s, ok := o.(*JSONString); ok && cmp.opts.NumericEquality {
+		n, err := strconv.ParseFloat(strings.TrimSpace(s.data), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func (cmp *equalsComparer) equals(a, b JSONObject, tokens []string) bool {
+	if cmp.ignored(tokens) {
+		return true
+	}
+	if ia, ok := a.(*JSONInt); ok {
+		if ib, ok := b.(*JSONInt); ok {
+			// integers beyond 2^53 differ as int64 but not as float64
+			return ia.data == ib.data
+		}
+	}
+	if na, ok := cmp.number(a); ok {
+		if nb, ok := cmp.number(b); ok {
+			_, aIsString := a.(*JSONString)
+			_, bIsString := b.(*JSONString)
+			if aIsString && bIsString {
+				// two strings are only equal as strings
+				return a.Equals(b)
+			}
+			if !cmp.opts.NumericEquality && jsonTypeName(a) != jsonTypeName(b) {
+				return false
+			}
+			return math.Abs(na-nb) <= cmp.opts.FloatEpsilon
+		}
+	}
+	switch va := a.(type) {
+	case *JSONDict:
+		vb, ok := b.(*JSONDict)
+		if !ok {
+			return false
+		}
+		return cmp.dictEquals(va, vb, tokens)
+	case *JSONArray:
+		vb, ok := b.(*JSONArray)
+		if !ok || len(va.data) != len(vb.data) {
+			return false
+		}
+		if cmp.opts.UnorderedArrays {
+			return cmp.multisetEquals(va.data, vb.data, tokens)
+		}
+		for i := range va.data {
+			if !cmp.equals(va.data[i], vb.data[i], subPointer(tokens, strconv.Itoa(i))) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equals(b)
+}
+
+func (cmp *equalsComparer) lookup(dict *JSONDict, key string) (JSONObject, string, bool) {
+	if val, ok := dict.data[key]; ok {
+		return val, key, true
+	}
+	if cmp.opts.CaseInsensitiveKeys {
+		for k, val := range dict.data {
+			if strings.EqualFold(k, key) {
+				return val, k, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+func (cmp *equalsComparer) dictEquals(a, b *JSONDict, tokens []string) bool {
+	matched := make(map[string]bool)
+	for k, v := range a.data {
+		sub := subPointer(tokens, k)
+		v2, k2, ok := cmp.lookup(b, k)
+		if !ok {
+			if !cmp.ignored(sub) && !(cmp.opts.NullEqualsMissing && v == JSONNull) {
+				return false
+			}
+			continue
+		}
+		matched[k2] = true
+		if !cmp.equals(v, v2, sub) {
+			return false
+		}
+	}
+	for k, v := range b.data {
+		if matched[k] {
+			continue
+		}
+		if _, _, ok := cmp.lookup(a, k); ok {
+			// keys differing only in case matched another key of a
+			continue
+		}
+		if !cmp.ignored(subPointer(tokens, k)) && !(cmp.opts.NullEqualsMissing && v == JSONNull) {
+			return false
+		}
+	}
+	return true
+}
+
+// multisetEquals finds a perfect matching between the elements of a and b
+func (cmp *equalsComparer) multisetEquals(a, b []JSONObject, tokens []string) bool {
+	matchOf := matchElements(len(a), len(b), func(i, j int) bool {
+		return cmp.equals(a[i], b[j], subPointer(tokens, strconv.Itoa(i)))
+	})
+	for _, i := range matchOf {
+		if i < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchElements finds a maximum matching between n elements and m others
+// that equal tells apart, and returns the element matched to each of the
+// m others, or -1.  It uses augmenting paths, as equality with FloatEpsilon
+// or ignored paths is not transitive and a greedy matching may miss some.
+func matchElements(n, m int, equal func(i, j int) bool) []int {
+	equals := make([][]bool, n)
+	for i := range equals {
+		equals[i] = make([]bool, m)
+		for j := range equals[i] {
+			equals[i][j] = equal(i, j)
+		}
+	}
+	matchOf := make([]int, m)
+	for j := range matchOf {
+		matchOf[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for j := 0; j < m; j++ {
+			if !equals[i][j] || seen[j] {
+				continue
+			}
+			seen[j] = true
+			if matchOf[j] < 0 || augment(matchOf[j], seen) {
+				matchOf[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < n; i++ {
+		augment(i, make([]bool, m))
+	}
+	return matchOf
+}
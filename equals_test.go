@@ -0,0 +1,153 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestEqualsWithOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		opts *EqualsOptions
+		want bool
+	}{
+		{
+			name: "nil options",
+			a:    `{"a": 1}`,
+			b:    `{"a": 1.0}`,
+			want: false,
+		},
+		{
+			name: "typed numbers",
+			a:    `{"a": 1}`,
+			b:    `{"a": 1.0}`,
+			opts: &EqualsOptions{},
+			want: false,
+		},
+		{
+			name: "numeric equality",
+			a:    `{"a": 1, "b": "2", "c": 3.5}`,
+			b:    `{"a": 1.0, "b": 2, "c": "3.5"}`,
+			opts: &EqualsOptions{NumericEquality: true},
+			want: true,
+		},
+		{
+			name: "numeric strings compare as strings",
+			a:    `["1"]`,
+			b:    `["1.0"]`,
+			opts: &EqualsOptions{NumericEquality: true},
+			want: false,
+		},
+		{
+			name: "float epsilon",
+			a:    `[0.1, 1]`,
+			b:    `[0.1000001, 1.0000001]`,
+			opts: &EqualsOptions{NumericEquality: true, FloatEpsilon: 1e-6},
+			want: true,
+		},
+		{
+			name: "float epsilon exceeded",
+			a:    `[0.1]`,
+			b:    `[0.11]`,
+			opts: &EqualsOptions{FloatEpsilon: 1e-6},
+			want: false,
+		},
+		{
+			name: "large integers",
+			a:    `[9007199254740993]`,
+			b:    `[9007199254740992]`,
+			opts: &EqualsOptions{},
+			want: false,
+		},
+		{
+			name: "integers ignore epsilon",
+			a:    `[9007199254740993, 1]`,
+			b:    `[9007199254740992, 2]`,
+			opts: &EqualsOptions{NumericEquality: true, FloatEpsilon: 1},
+			want: false,
+		},
+		{
+			name: "ordered arrays",
+			a:    `["a", "b"]`,
+			b:    `["b", "a"]`,
+			opts: &EqualsOptions{},
+			want: false,
+		},
+		{
+			name: "unordered arrays",
+			a:    `{"rules": [{"port": 80}, {"port": 443}, {"port": 80}]}`,
+			b:    `{"rules": [{"port": 443}, {"port": 80}, {"port": 80}]}`,
+			opts: &EqualsOptions{UnorderedArrays: true},
+			want: true,
+		},
+		{
+			name: "unordered arrays are multisets",
+			a:    `["a", "a", "b"]`,
+			b:    `["a", "b", "b"]`,
+			opts: &EqualsOptions{UnorderedArrays: true},
+			want: false,
+		},
+		{
+			name: "unordered arrays with epsilon",
+			a:    `[1.0, 1.1]`,
+			b:    `[1.1, 1.05]`,
+			opts: &EqualsOptions{UnorderedArrays: true, FloatEpsilon: 0.06},
+			want: true,
+		},
+		{
+			name: "case insensitive keys",
+			a:    `{"Name": "x", "Tags": {"Env": "prod"}}`,
+			b:    `{"name": "x", "tags": {"env": "prod"}}`,
+			opts: &EqualsOptions{CaseInsensitiveKeys: true},
+			want: true,
+		},
+		{
+			name: "case sensitive keys",
+			a:    `{"Name": "x"}`,
+			b:    `{"name": "x"}`,
+			opts: &EqualsOptions{},
+			want: false,
+		},
+		{
+			name: "null equals missing",
+			a:    `{"a": 1, "b": null}`,
+			b:    `{"a": 1, "c": null}`,
+			opts: &EqualsOptions{NullEqualsMissing: true},
+			want: true,
+		},
+		{
+			name: "null differs from missing",
+			a:    `{"a": 1, "b": null}`,
+			b:    `{"a": 1}`,
+			opts: &EqualsOptions{},
+			want: false,
+		},
+		{
+			name: "ignore paths",
+			a:    `{"id": "1", "items": [{"uid": 1, "v": 1}], "ts": 1}`,
+			b:    `{"id": "2", "items": [{"uid": 2, "v": 1}]}`,
+			opts: &EqualsOptions{IgnorePaths: []string{"/id", "/items/*/uid", "/ts"}},
+			want: true,
+		},
+		{
+			name: "ignore paths with case insensitive keys",
+			a:    `{"Meta": {"UID": 1}}`,
+			b:    `{"meta": {"uid": 2}}`,
+			opts: &EqualsOptions{CaseInsensitiveKeys: true, IgnorePaths: []string{"/meta/uid"}},
+			want: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, _ := ParseString(c.a)
+			b, _ := ParseString(c.b)
+			if got := EqualsWithOptions(a, b, c.opts); got != c.want {
+				t.Errorf("EqualsWithOptions(%s, %s) = %v, want %v", a, b, got, c.want)
+			}
+			if got := EqualsWithOptions(b, a, c.opts); got != c.want {
+				t.Errorf("EqualsWithOptions(%s, %s) = %v, want %v", b, a, got, c.want)
+			}
+		})
+	}
+}
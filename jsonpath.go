@@ -0,0 +1,749 @@
+package jsonutils
+
+/**
+jsonutils.JSONPath
+
+Query JSONObject trees with JSONPath, e.g.
+
+	path, err := CompileJSONPath("$.servers[*].nics[?(@.ip)].mac")
+	for _, r := range path.Find(obj) {
+		fmt.Println(r.Path, r.Value)
+	}
+
+Supported syntax:
+
+	$            the root
+	.name ['name']  member of a dict
+	[0] [-1]     array element, negative indices count from the end
+	[start:end:step]  array slice
+	.* [*]       all members of a dict or elements of an array
+	..           recursive descent, e.g. $..name, $..[0]
+	[a,b]        union of selectors
+	[?(expr)]    members or elements for which expr holds
+
+Filter expressions compare values of queries relative to the current
+node (@) or the root ($) and literals with == != < <= > >=, match strings
+against regular expressions with =~ /re/, and combine with && || ! and
+parentheses.  A query alone tests for existence.
+
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type JSONPath struct {
+	expr     string
+	segments []jsonPathSegment
+}
+
+type JSONPathResult struct {
+	// Path is the JSON pointer of Value in the queried tree
+	Path  string
+	Value JSONObject
+}
+
+type jsonPathNode struct {
+	tokens []string
+	value  JSONObject
+}
+
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []jsonPathSelector
+}
+
+type jsonPathSelector interface {
+	selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode
+}
+
+// CompileJSONPath parses a JSONPath expression for repeated use
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	p := &jsonPathParser{expr: expr}
+	p.skipSpace()
+	if !p.consume("$") {
+		return nil, p.errorf("must start with $")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return &JSONPath{expr: expr, segments: segments}, nil
+}
+
+// QueryJSONPath compiles expr and returns the results of applying it to obj
+func QueryJSONPath(obj JSONObject, expr string) ([]JSONPathResult, error) {
+	path, err := CompileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return path.Find(obj), nil
+}
+
+func (path *JSONPath) String() string {
+	return path.expr
+}
+
+// Find returns the values matching the path, in document order with dict
+// members ordered by key
+func (path *JSONPath) Find(obj JSONObject) []JSONPathResult {
+	nodes := evalJSONPathSegments(path.segments, jsonPathNode{tokens: []string{}, value: obj}, obj)
+	results := make([]JSONPathResult, len(nodes))
+	for i, node := range nodes {
+		results[i] = JSONPathResult{Path: JSONPointer(node.tokens...), Value: node.value}
+	}
+	return results
+}
+
+// FindValues is like Find, but returns the values only
+func (path *JSONPath) FindValues(obj JSONObject) []JSONObject {
+	nodes := evalJSONPathSegments(path.segments, jsonPathNode{tokens: []string{}, value: obj}, obj)
+	values := make([]JSONObject, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.value
+	}
+	return values
+}
+
+func evalJSONPathSegments(segments []jsonPathSegment, start jsonPathNode, root JSONObject) []jsonPathNode {
+	nodes := []jsonPathNode{start}
+	for _, seg := range segments {
+		inputs := nodes
+		if seg.descendant {
+			inputs = make([]jsonPathNode, 0)
+			for _, node := range nodes {
+				inputs = jsonPathDescendants(node, inputs)
+			}
+		}
+		nodes = make([]jsonPathNode, 0)
+		for _, node := range inputs {
+			for _, sel := range seg.selectors {
+				nodes = sel.selectNodes(node, root, nodes)
+			}
+		}
+	}
+	return nodes
+}
+
+func jsonPathDescendants(node jsonPathNode, out []jsonPathNode) []jsonPathNode {
+	out = append(out, node)
+	for _, child := range jsonPathChildren(node) {
+		out = jsonPathDescendants(child, out)
+	}
+	return out
+}
+
+func jsonPathChildren(node jsonPathNode) []jsonPathNode {
+	var children []jsonPathNode
+	switch v := node.value.(type) {
+	case *JSONDict:
+		for _, k := range v.SortedKeys() {
+			children = append(children, jsonPathNode{tokens: subPointer(node.tokens, k), value: v.data[k]})
+		}
+	case *JSONArray:
+		for i, val := range v.data {
+			children = append(children, jsonPathNode{tokens: subPointer(node.tokens, strconv.Itoa(i)), value: val})
+		}
+	}
+	return children
+}
+
+type jsonPathName string
+
+func (sel jsonPathName) selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode {
+	if dict, ok := node.value.(*JSONDict); ok {
+		if val, ok := dict.data[string(sel)]; ok {
+			out = append(out, jsonPathNode{tokens: subPointer(node.tokens, string(sel)), value: val})
+		}
+	}
+	return out
+}
+
+type jsonPathWildcard struct{}
+
+func (sel jsonPathWildcard) selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode {
+	return append(out, jsonPathChildren(node)...)
+}
+
+type jsonPathIndex int
+
+func (sel jsonPathIndex) selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode {
+	if arr, ok := node.value.(*JSONArray); ok {
+		idx := int(sel)
+		if idx < 0 {
+			idx += len(arr.data)
+		}
+		if idx >= 0 && idx < len(arr.data) {
+			out = append(out, jsonPathNode{tokens: subPointer(node.tokens, strconv.Itoa(idx)), value: arr.data[idx]})
+		}
+	}
+	return out
+}
+
+type jsonPathSlice struct {
+	start, end *int
+	step       int
+}
+
+func (sel jsonPathSlice) selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode {
+	arr, ok := node.value.(*JSONArray)
+	if !ok || sel.step == 0 {
+		return out
+	}
+	n := len(arr.data)
+	normalize := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		if *i < 0 {
+			return *i + n
+		}
+		return *i
+	}
+	add := func(i int) {
+		out = append(out, jsonPathNode{tokens: subPointer(node.tokens, strconv.Itoa(i)), value: arr.data[i]})
+	}
+	if sel.step > 0 {
+		lower := minInt(maxInt(normalize(sel.start, 0), 0), n)
+		upper := minInt(maxInt(normalize(sel.end, n), 0), n)
+		for i := lower; i < upper; i += sel.step {
+			add(i)
+		}
+	} else {
+		upper := minInt(maxInt(normalize(sel.start, n-1), -1), n-1)
+		lower := minInt(maxInt(normalize(sel.end, -n-1), -1), n-1)
+		for i := upper; lower < i; i += sel.step {
+			add(i)
+		}
+	}
+	return out
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+type jsonPathFilter struct {
+	expr jsonPathExpr
+}
+
+func (sel jsonPathFilter) selectNodes(node jsonPathNode, root JSONObject, out []jsonPathNode) []jsonPathNode {
+	for _, child := range jsonPathChildren(node) {
+		if sel.expr.eval(child, root) {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+type jsonPathExpr interface {
+	eval(current jsonPathNode, root JSONObject) bool
+}
+
+type jsonPathOr struct{ left, right jsonPathExpr }
+
+func (e jsonPathOr) eval(current jsonPathNode, root JSONObject) bool {
+	return e.left.eval(current, root) || e.right.eval(current, root)
+}
+
+type jsonPathAnd struct{ left, right jsonPathExpr }
+
+func (e jsonPathAnd) eval(current jsonPathNode, root JSONObject) bool {
+	return e.left.eval(current, root) && e.right.eval(current, root)
+}
+
+type jsonPathNot struct{ expr jsonPathExpr }
+
+func (e jsonPathNot) eval(current jsonPathNode, root JSONObject) bool {
+	return !e.expr.eval(current, root)
+}
+
+// jsonPathOperand is either a literal or a query relative to the current
+// node or the root
+type jsonPathOperand struct {
+	literal  JSONObject
+	query    []jsonPathSegment
+	relative bool
+}
+
+func (o *jsonPathOperand) nodes(current jsonPathNode, root JSONObject) []jsonPathNode {
+	start := jsonPathNode{tokens: []string{}, value: root}
+	if o.relative {
+		start = current
+	}
+	return evalJSONPathSegments(o.query, start, root)
+}
+
+func (o *jsonPathOperand) value(current jsonPathNode, root JSONObject) (JSONObject, bool) {
+	if o.literal != nil {
+		return o.literal, true
+	}
+	nodes := o.nodes(current, root)
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes[0].value, true
+}
+
+type jsonPathExists struct{ operand *jsonPathOperand }
+
+func (e jsonPathExists) eval(current jsonPathNode, root JSONObject) bool {
+	return len(e.operand.nodes(current, root)) > 0
+}
+
+type jsonPathComparison struct {
+	op          string
+	left, right *jsonPathOperand
+	regexp      *regexp.Regexp
+}
+
+func (e jsonPathComparison) eval(current jsonPathNode, root JSONObject) bool {
+	l, lok := e.left.value(current, root)
+	if e.op == "=~" {
+		s, ok := l.(*JSONString)
+		return lok && ok && e.regexp.MatchString(s.data)
+	}
+	r, rok := e.right.value(current, root)
+	if !lok || !rok {
+		switch e.op {
+		case "==":
+			return !lok && !rok
+		case "!=":
+			return lok != rok
+		}
+		return false
+	}
+	switch e.op {
+	case "==":
+		return jsonValueEquals(l, r)
+	case "!=":
+		return !jsonValueEquals(l, r)
+	}
+	var cmp int
+	if nl, ok := jsonNumber(l); ok {
+		nr, ok := jsonNumber(r)
+		if !ok {
+			return false
+		}
+		switch {
+		case nl < nr:
+			cmp = -1
+		case nl > nr:
+			cmp = 1
+		}
+	} else if sl, ok := l.(*JSONString); ok {
+		sr, ok := r.(*JSONString)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(sl.data, sr.data)
+	} else {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+type jsonPathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("JSONPath %q: %s at offset %d", p.expr, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t' || p.expr[p.pos] == '\n' || p.expr[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) parseSegments() ([]jsonPathSegment, error) {
+	segments := make([]jsonPathSegment, 0)
+	for {
+		var seg jsonPathSegment
+		switch {
+		case p.consume(".."):
+			seg.descendant = true
+			if p.peek() == '[' {
+				selectors, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = selectors
+			} else if p.consume("*") {
+				seg.selectors = []jsonPathSelector{jsonPathWildcard{}}
+			} else {
+				name := p.parseName()
+				if len(name) == 0 {
+					return nil, p.errorf("expect a member name after ..")
+				}
+				seg.selectors = []jsonPathSelector{jsonPathName(name)}
+			}
+		case p.consume("."):
+			if p.consume("*") {
+				seg.selectors = []jsonPathSelector{jsonPathWildcard{}}
+			} else {
+				name := p.parseName()
+				if len(name) == 0 {
+					return nil, p.errorf("expect a member name after .")
+				}
+				seg.selectors = []jsonPathSelector{jsonPathName(name)}
+			}
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = selectors
+		default:
+			return segments, nil
+		}
+		segments = append(segments, seg)
+	}
+}
+
+func isJSONPathNameRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (p *jsonPathParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.expr) {
+		r, size := utf8.DecodeRuneInString(p.expr[p.pos:])
+		if !isJSONPathNameRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.expr[start:p.pos]
+}
+
+func (p *jsonPathParser) parseBracket() ([]jsonPathSelector, error) {
+	p.pos++ // [
+	selectors := make([]jsonPathSelector, 0)
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expect , or ]")
+		}
+	}
+}
+
+func (p *jsonPathParser) parseSelector() (jsonPathSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathName(s), nil
+	case c == '*':
+		p.pos++
+		return jsonPathWildcard{}, nil
+	case c == '?':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathFilter{expr: expr}, nil
+	}
+	start, err := p.parseOptionalInt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.peek() != ':' {
+		if start == nil {
+			return nil, p.errorf("invalid selector")
+		}
+		return jsonPathIndex(*start), nil
+	}
+	slice := jsonPathSlice{start: start, step: 1}
+	p.pos++
+	p.skipSpace()
+	if slice.end, err = p.parseOptionalInt(); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.consume(":") {
+		p.skipSpace()
+		step, err := p.parseOptionalInt()
+		if err != nil {
+			return nil, err
+		}
+		if step != nil {
+			slice.step = *step
+		}
+	}
+	return slice, nil
+}
+
+func (p *jsonPathParser) parseOptionalInt() (*int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid integer %q", p.expr[start:p.pos])
+	}
+	return &i, nil
+}
+
+func (p *jsonPathParser) parseQuoted() (string, error) {
+	quote := p.expr[p.pos]
+	p.pos++
+	var buf strings.Builder
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return buf.String(), nil
+		case '\\':
+			if p.pos >= len(p.expr) {
+				return "", p.errorf("unterminated string")
+			}
+			c = p.expr[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			default:
+				buf.WriteByte(c)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPathParser) parseOr() (jsonPathExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jsonPathOr{left: left, right: right}
+	}
+}
+
+func (p *jsonPathParser) parseAnd() (jsonPathExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = jsonPathAnd{left: left, right: right}
+	}
+}
+
+func (p *jsonPathParser) parseUnary() (jsonPathExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.expr[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathNot{expr: expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expect )")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+func (p *jsonPathParser) parseComparison() (jsonPathExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	op := ""
+	for _, o := range jsonPathOperators {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if len(op) == 0 {
+		if left.literal != nil {
+			return nil, p.errorf("expect a comparison operator")
+		}
+		return jsonPathExists{operand: left}, nil
+	}
+	p.skipSpace()
+	comparison := jsonPathComparison{op: op, left: left}
+	if op == "=~" {
+		var pattern string
+		switch p.peek() {
+		case '/':
+			pattern, err = p.parseRegexpLiteral()
+		case '\'', '"':
+			pattern, err = p.parseQuoted()
+		default:
+			err = p.errorf("=~ expects a regular expression")
+		}
+		if err != nil {
+			return nil, err
+		}
+		comparison.regexp, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, p.errorf("invalid regular expression: %s", err)
+		}
+		return comparison, nil
+	}
+	comparison.right, err = p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison, nil
+}
+
+func (p *jsonPathParser) parseRegexpLiteral() (string, error) {
+	p.pos++ // /
+	var buf strings.Builder
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		p.pos++
+		if c == '/' {
+			return buf.String(), nil
+		}
+		if c == '\\' && p.peek() == '/' {
+			c = '/'
+			p.pos++
+		} else if c == '\\' && p.pos < len(p.expr) {
+			buf.WriteByte(c)
+			c = p.expr[p.pos]
+			p.pos++
+		}
+		buf.WriteByte(c)
+	}
+	return "", p.errorf("unterminated regular expression")
+}
+
+func (p *jsonPathParser) parseOperand() (*jsonPathOperand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		query, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathOperand{query: query, relative: c == '@'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathOperand{literal: NewString(s)}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.expr) && strings.IndexByte("0123456789.eE+-", p.expr[p.pos]) >= 0 {
+			p.pos++
+		}
+		num := p.expr[start:p.pos]
+		if i, err := strconv.ParseInt(num, 10, 64); err == nil {
+			return &jsonPathOperand{literal: NewInt(i)}, nil
+		}
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", num)
+		}
+		return &jsonPathOperand{literal: NewFloat(f)}, nil
+	case p.consume("true"):
+		return &jsonPathOperand{literal: JSONTrue}, nil
+	case p.consume("false"):
+		return &jsonPathOperand{literal: JSONFalse}, nil
+	case p.consume("null"):
+		return &jsonPathOperand{literal: JSONNull}, nil
+	}
+	return nil, p.errorf("expect a query or literal")
+}
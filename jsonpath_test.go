@@ -0,0 +1,102 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	doc, _ := ParseString(`{
+		"name": "cluster",
+		"servers": [
+			{"name": "web1", "cpu": 2, "status": "running", "nics": [{"ip": "10.0.0.1", "mac": "00:01"}, {"mac": "00:02"}]},
+			{"name": "web2", "cpu": 4, "status": "stopped", "nics": [{"ip": "10.0.0.2", "mac": "00:03"}]},
+			{"name": "db1", "cpu": 8.5, "status": "running", "nics": []}
+		],
+		"limits": {"cpu": 16}
+	}`)
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"$.name", []string{`/name="cluster"`}},
+		{"$['name']", []string{`/name="cluster"`}},
+		{"$.servers[*].nics[?(@.ip)].mac", []string{`/servers/0/nics/0/mac="00:01"`, `/servers/1/nics/0/mac="00:03"`}},
+		{"$.servers[-1].name", []string{`/servers/2/name="db1"`}},
+		{"$.servers[0,2].name", []string{`/servers/0/name="web1"`, `/servers/2/name="db1"`}},
+		{"$.servers[1:].name", []string{`/servers/1/name="web2"`, `/servers/2/name="db1"`}},
+		{"$.servers[::-2].name", []string{`/servers/2/name="db1"`, `/servers/0/name="web1"`}},
+		{"$.servers[:1]['name','cpu']", []string{`/servers/0/name="web1"`, `/servers/0/cpu=2`}},
+		{"$..cpu", []string{`/limits/cpu=16`, `/servers/0/cpu=2`, `/servers/1/cpu=4`, `/servers/2/cpu=8.500000`}},
+		{"$..nics[0].ip", []string{`/servers/0/nics/0/ip="10.0.0.1"`, `/servers/1/nics/0/ip="10.0.0.2"`}},
+		{"$.servers[?(@.cpu > 2 && @.status == 'running')].name", []string{`/servers/2/name="db1"`}},
+		{"$.servers[?(@.cpu >= 4 || @.name == \"web1\")].name", []string{`/servers/0/name="web1"`, `/servers/1/name="web2"`, `/servers/2/name="db1"`}},
+		{"$.servers[?(!(@.status == 'running'))].name", []string{`/servers/1/name="web2"`}},
+		{"$.servers[?(@.name =~ /^web/)].cpu", []string{`/servers/0/cpu=2`, `/servers/1/cpu=4`}},
+		{"$.servers[?(@.cpu < $.limits.cpu / 2)]", nil},
+		{"$.servers[?(@.cpu * 2 > 1)]", nil},
+		{"$.servers[?(@.cpu == 8.5)].name", []string{`/servers/2/name="db1"`}},
+		{"$.servers[?(@.missing != 1)].name", []string{`/servers/0/name="web1"`, `/servers/1/name="web2"`, `/servers/2/name="db1"`}},
+		{"$.servers[?(@.nics[1])].name", []string{`/servers/0/name="web1"`}},
+		{"$.limits.*", []string{`/limits/cpu=16`}},
+		{"$.nothing", []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			path, err := CompileJSONPath(c.expr)
+			if c.want == nil {
+				if err == nil {
+					t.Errorf("expect compile error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile fail %s", err)
+			}
+			got := make([]string, 0)
+			for _, r := range path.Find(doc) {
+				got = append(got, r.Path+"="+r.Value.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("want\n%s\ngot\n%s", strings.Join(c.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestJSONPathRoot(t *testing.T) {
+	doc, _ := ParseString(`{"a": [1, 2]}`)
+	results, err := QueryJSONPath(doc, "$")
+	if err != nil {
+		t.Fatalf("query fail %s", err)
+	}
+	if len(results) != 1 || results[0].Path != "" || results[0].Value != doc {
+		t.Errorf("$ should select the root, got %v", results)
+	}
+	path, _ := CompileJSONPath("$.a[*]")
+	values := path.FindValues(doc)
+	if len(values) != 2 || path.String() != "$.a[*]" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"servers",
+		"$.",
+		"$..",
+		"$[",
+		"$[0",
+		"$['a",
+		"$[?(@.a == )]",
+		"$[?(@.a =~ 1)]",
+		"$[?(@.a =~ /(/)]",
+		"$[?(1)]",
+		"$.a b",
+	} {
+		if _, err := CompileJSONPath(expr); err == nil {
+			t.Errorf("%q should fail", expr)
+		}
+	}
+}
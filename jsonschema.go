@@ -0,0 +1,316 @@
+package jsonutils
+
+/**
+jsonutils.SchemaOf
+
+Describe the JSON produced by Marshal for a Go type as a JSON Schema (draft-07)
+
+*/
+
+import (
+	"reflect"
+	"strings"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/reflectutils"
+)
+
+const (
+	JSONSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+)
+
+type schemaGenerator struct {
+	definitions *JSONDict
+	visiting    map[reflect.Type]bool
+	recursive   map[reflect.Type]bool
+	embedding   map[reflect.Type]bool
+}
+
+// SchemaOf returns the JSON Schema of the value that Marshal produces for
+// a value of type tp.  Self-referencing struct types are placed under
+// definitions and referred to with $ref.
+func SchemaOf(tp reflect.Type) JSONObject {
+	gen := &schemaGenerator{
+		definitions: NewDict(),
+		visiting:    make(map[reflect.Type]bool),
+		recursive:   make(map[reflect.Type]bool),
+		embedding:   make(map[reflect.Type]bool),
+	}
+	for tp.Kind() == reflect.Ptr && tp != JSONDictPtrType && tp != JSONArrayPtrType &&
+		tp != JSONStringPtrType && tp != JSONIntPtrType && tp != JSONFloatPtrType && tp != JSONBoolPtrType {
+		tp = tp.Elem()
+	}
+	schema := gen.schemaOf(tp, nil)
+	root := NewDict()
+	if schema != nil {
+		if ref, _ := schema.GetString("$ref"); len(ref) > 0 {
+			def, _ := gen.definitions.Get(schemaDefinitionName(tp))
+			schema = def.(*JSONDict)
+		}
+		root.Update(schema)
+	} else {
+		root.Set("not", NewDict())
+	}
+	root.Set("$schema", NewString(JSONSchemaDraft07))
+	if gen.definitions.Length() > 0 {
+		root.Set("definitions", gen.definitions)
+	}
+	return root
+}
+
+func schemaDefinitionName(tp reflect.Type) string {
+	return strings.Replace(tp.String(), "/", "_", -1)
+}
+
+func schemaType(tp string) *JSONDict {
+	return NewDict(JSONPair{key: "type", val: NewString(tp)})
+}
+
+func schemaNullable(schema *JSONDict) *JSONDict {
+	return NewDict(JSONPair{key: "anyOf", val: NewArray(schema, schemaType("null"))})
+}
+
+func isForceString(info *reflectutils.SStructFieldInfo) bool {
+	return info != nil && info.ForceString
+}
+
+func isOmitEmpty(info *reflectutils.SStructFieldInfo) bool {
+	return info != nil && info.OmitEmpty
+}
+
+func (gen *schemaGenerator) schemaOf(tp reflect.Type, info *reflectutils.SStructFieldInfo) *JSONDict {
+	switch tp {
+	case JSONObjectType:
+		return NewDict()
+	case JSONDictType, JSONDictPtrType:
+		return schemaType("object")
+	case JSONArrayType, JSONArrayPtrType:
+		return schemaType("array")
+	case JSONStringType, JSONStringPtrType:
+		return schemaType("string")
+	case JSONIntType, JSONIntPtrType:
+		return schemaType("integer")
+	case JSONFloatType, JSONFloatPtrType:
+		return schemaType("number")
+	case JSONBoolType, JSONBoolPtrType:
+		return schemaType("boolean")
+	case tristate.TriStateType:
+		return schemaType("boolean")
+	case gotypes.TimeType:
+		schema := schemaType("string")
+		if isOmitEmpty(info) {
+			schema.Set("format", NewString("date-time"))
+		} else {
+			// zero time is marshaled as an empty string
+			schema.Set("anyOf", NewArray(
+				NewDict(JSONPair{key: "format", val: NewString("date-time")}),
+				NewDict(JSONPair{key: "maxLength", val: NewInt(0)}),
+			))
+		}
+		return schema
+	}
+	switch tp.Kind() {
+	case reflect.Slice, reflect.Array:
+		if isForceString(info) {
+			return schemaType("string")
+		}
+		schema := schemaType("array")
+		elemType := tp.Elem()
+		items := gen.schemaOf(elemType, nil)
+		if items == nil {
+			items = schemaType("null")
+		} else if isNullableType(elemType) && items.Length() > 0 {
+			items = schemaNullable(items)
+		}
+		schema.Set("items", items)
+		if tp.Kind() == reflect.Array {
+			schema.Set("minItems", NewInt(int64(tp.Len())))
+			schema.Set("maxItems", NewInt(int64(tp.Len())))
+		}
+		return schema
+	case reflect.Map:
+		if isForceString(info) {
+			return schemaType("string")
+		}
+		schema := schemaType("object")
+		values := gen.schemaOf(tp.Elem(), nil)
+		if values == nil {
+			values = NewDict(JSONPair{key: "not", val: NewDict()})
+		}
+		schema.Set("additionalProperties", values)
+		return schema
+	case reflect.Struct:
+		if isForceString(info) {
+			return schemaType("string")
+		}
+		return gen.structSchema(tp)
+	case reflect.String:
+		return schemaType("string")
+	case reflect.Bool:
+		if isForceString(info) {
+			schema := schemaType("string")
+			schema.Set("enum", NewStringArray([]string{"true", "false"}))
+			return schema
+		}
+		return schemaType("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isForceString(info) {
+			schema := schemaType("string")
+			schema.Set("pattern", NewString("^-?[0-9]+$"))
+			return schema
+		}
+		return schemaType("integer")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isForceString(info) {
+			schema := schemaType("string")
+			schema.Set("pattern", NewString("^[0-9]+$"))
+			return schema
+		}
+		schema := schemaType("integer")
+		schema.Set("minimum", NewInt(0))
+		return schema
+	case reflect.Float32, reflect.Float64:
+		if isForceString(info) {
+			return schemaType("string")
+		}
+		return schemaType("number")
+	case reflect.Ptr:
+		return gen.schemaOf(tp.Elem(), info)
+	case reflect.Interface:
+		return NewDict()
+	}
+	// Marshal cannot represent the type, e.g. chan and func
+	return nil
+}
+
+func isNullableType(tp reflect.Type) bool {
+	switch tp.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return true
+	}
+	return tp == tristate.TriStateType
+}
+
+func (gen *schemaGenerator) structSchema(tp reflect.Type) *JSONDict {
+	name := schemaDefinitionName(tp)
+	if gen.visiting[tp] {
+		gen.recursive[tp] = true
+		return NewDict(JSONPair{key: "$ref", val: NewString("#/definitions/" + name)})
+	}
+	gen.visiting[tp] = true
+	defer delete(gen.visiting, tp)
+
+	schema := schemaType("object")
+	props := NewDict()
+	required := make([]string, 0)
+	closed := gen.structProperties(tp, props, &required, false)
+	schema.Set("properties", props)
+	if len(required) > 0 {
+		schema.Set("required", NewStringArray(required))
+	}
+	if closed {
+		schema.Set("additionalProperties", JSONFalse)
+	}
+	if gen.recursive[tp] {
+		gen.definitions.Set(name, schema)
+		return NewDict(JSONPair{key: "$ref", val: NewString("#/definitions/" + name)})
+	}
+	return schema
+}
+
+// structProperties mirrors reflectutils.FetchStructFieldValueSet and
+// struct2JSONPairs.  It returns false if anonymous interface fields make
+// the set of properties unknown.
+func (gen *schemaGenerator) structProperties(tp reflect.Type, props *JSONDict, required *[]string, optional bool) bool {
+	closed := true
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		if !gotypes.IsFieldExportable(sf.Name) {
+			continue
+		}
+		if sf.Anonymous {
+			ft := sf.Type
+			embedOptional := optional
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+				// nil embedded pointers contribute no fields
+				embedOptional = true
+			}
+			if ft.Kind() == reflect.Interface {
+				// reflectutils flattens the structs held by an embedded
+				// interface, and writes other values, like a JSONObject,
+				// under the name of the field
+				if ft != JSONObjectType {
+					closed = false
+				}
+				info := reflectutils.ParseStructFieldJsonInfo(sf)
+				if !info.Ignore {
+					props.Set(info.MarshalName(), NewDict())
+				}
+				continue
+			}
+			if ft.Kind() == reflect.Struct && ft != gotypes.TimeType {
+				if ft == tp || gen.embedding[ft] {
+					// a struct embedding itself through a pointer
+					continue
+				}
+				gen.embedding[ft] = true
+				if !gen.structProperties(ft, props, required, embedOptional) {
+					closed = false
+				}
+				delete(gen.embedding, ft)
+				continue
+			}
+		}
+		info := reflectutils.ParseStructFieldJsonInfo(sf)
+		if info.Ignore {
+			continue
+		}
+		schema := gen.schemaOf(sf.Type, &info)
+		if schema == nil {
+			continue
+		}
+		name := info.MarshalName()
+		props.Set(name, schema)
+		if !optional && alwaysMarshaled(sf.Type, &info) {
+			*required = append(*required, name)
+		}
+	}
+	return closed
+}
+
+// alwaysMarshaled tells whether Marshal emits the field regardless of its value
+func alwaysMarshaled(tp reflect.Type, info *reflectutils.SStructFieldInfo) bool {
+	switch tp {
+	case JSONDictType, JSONArrayType, JSONStringType, JSONIntType, JSONFloatType, JSONBoolType, gotypes.TimeType:
+		return !info.OmitEmpty
+	case JSONObjectType, tristate.TriStateType:
+		return false
+	}
+	switch tp.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return !info.OmitEmpty
+	case reflect.Bool:
+		return !info.OmitFalse
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return !info.OmitZero
+	case reflect.Struct:
+		if !info.OmitEmpty {
+			return true
+		}
+		for i := 0; i < tp.NumField(); i++ {
+			sf := tp.Field(i)
+			if !gotypes.IsFieldExportable(sf.Name) || sf.Anonymous {
+				continue
+			}
+			fieldInfo := reflectutils.ParseStructFieldJsonInfo(sf)
+			if !fieldInfo.Ignore && alwaysMarshaled(sf.Type, &fieldInfo) {
+				return true
+			}
+		}
+	}
+	return false
+}
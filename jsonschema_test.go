@@ -0,0 +1,114 @@
+package jsonutils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"yunion.io/x/pkg/tristate"
+)
+
+type schemaTree struct {
+	Name     string
+	Children []schemaTree
+	Parent   *schemaTree
+}
+
+func TestSchemaOf(t *testing.T) {
+	type SEmbed struct {
+		EmbedName string
+	}
+	type SStruct struct {
+		SEmbed
+
+		Name    string
+		Id      string `json:"id,allowempty"`
+		Count   int
+		Zero    int `json:",omitzero"`
+		Size    uint64
+		Ratio   float32
+		Enabled bool
+		Flag    bool `json:",omitfalse"`
+		Tri     tristate.TriState
+		Created time.Time
+		Tags    []string
+		Nics    []*SEmbed
+		Labels  map[string]int
+		Spec    JSONObject
+		Meta    *JSONDict
+		Port    int    `json:"port,string"`
+		Ignored string `json:"-"`
+		Func    func()
+	}
+	want := `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"embed_name": {"type": "string"},
+			"name": {"type": "string"},
+			"id": {"type": "string"},
+			"count": {"type": "integer"},
+			"zero": {"type": "integer"},
+			"size": {"type": "integer", "minimum": 0},
+			"ratio": {"type": "number"},
+			"enabled": {"type": "boolean"},
+			"flag": {"type": "boolean"},
+			"tri": {"type": "boolean"},
+			"created": {"type": "string", "format": "date-time"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"nics": {"type": "array", "items": {"anyOf": [
+				{"type": "object", "additionalProperties": false, "properties": {"embed_name": {"type": "string"}}},
+				{"type": "null"}
+			]}},
+			"labels": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"spec": {},
+			"meta": {"type": "object"},
+			"port": {"type": "string", "pattern": "^-?[0-9]+$"}
+		},
+		"required": ["id", "count", "size", "ratio", "enabled", "port"]
+	}`
+	wantJson, err := ParseString(want)
+	if err != nil {
+		t.Fatalf("parse want %s", err)
+	}
+	got := SchemaOf(reflect.TypeOf(&SStruct{}))
+	if !got.Equals(wantJson) {
+		t.Errorf("want %s\ngot %s", wantJson.PrettyString(), got.PrettyString())
+	}
+}
+
+func TestSchemaOfRecursive(t *testing.T) {
+	got := SchemaOf(reflect.TypeOf(schemaTree{}))
+	ref, _ := got.GetString("properties", "children", "items", "$ref")
+	if ref != "#/definitions/jsonutils.schemaTree" {
+		t.Errorf("recursive struct should use $ref: %s", got)
+	}
+	if !got.Contains("definitions", "jsonutils.schemaTree", "properties", "parent") {
+		t.Errorf("missing definition: %s", got)
+	}
+	if tp, _ := got.GetString("type"); tp != "object" {
+		t.Errorf("root should be inlined: %s", got)
+	}
+}
+
+func TestSchemaOfScalars(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want string
+	}{
+		{"", `{"type": "string"}`},
+		{int8(0), `{"type": "integer"}`},
+		{[2]int{}, `{"type": "array", "items": {"type": "integer"}, "minItems": 2, "maxItems": 2}`},
+		{time.Time{}, `{"type": "string", "anyOf": [{"format": "date-time"}, {"maxLength": 0}]}`},
+		{NewArray(), `{"type": "array"}`},
+	}
+	for _, c := range cases {
+		got := SchemaOf(reflect.TypeOf(c.in)).(*JSONDict)
+		got.Remove("$schema")
+		want, _ := ParseString(c.want)
+		if !got.Equals(want) {
+			t.Errorf("%T: want %s, got %s", c.in, want, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package jsonutils
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -13,31 +14,128 @@ import (
 	"yunion.io/x/pkg/gotypes"
 )
 
+var (
+	// ErrJSONEmpty is the cause of the JSONError of blank input
+	ErrJSONEmpty = errors.New("empty JSON")
+	// ErrJSONTruncated is the cause of the JSONError of input ending in
+	// the middle of a value
+	ErrJSONTruncated = errors.New("truncated JSON")
+	// ErrJSONUnexpectedChar is the cause of the JSONError of a character
+	// that cannot be at its place, like a missing comma or colon
+	ErrJSONUnexpectedChar = errors.New("unexpected character in JSON")
+	// ErrJSONInvalidEscape is the cause of the JSONError of a \u or \x
+	// escape without hex digits in a string
+	ErrJSONInvalidEscape = errors.New("invalid escape in JSON string")
+)
+
+// JSONError is a syntax error in JSON.  Its cause, one of the ErrJSON
+// errors, is found by errors.Is.
 type JSONError struct {
 	pos    int
-	substr string
-	msg    string
+	line   int
+	column int
+	// context is the line of the error, trimmed around the error at offset
+	// caret
+	context string
+	caret   int
+	msg     string
+	err     error
 }
 
 func (e *JSONError) Error() string {
-	return fmt.Sprintf("JSON error %s at %d: %s...", e.msg, e.pos, e.substr)
+	return fmt.Sprintf("JSON error %s at line %d column %d (offset %d): %s^%s",
+		e.msg, e.line, e.column, e.pos, e.context[:e.caret], e.context[e.caret:])
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.err
+}
+
+// Offset returns the byte offset of the error
+func (e *JSONError) Offset() int {
+	return e.pos
+}
+
+// Line returns the 1-based line of the error
+func (e *JSONError) Line() int {
+	return e.line
+}
+
+// Column returns the 1-based byte column of the error
+func (e *JSONError) Column() int {
+	return e.column
+}
+
+// Reason returns the message of the error without its place
+func (e *JSONError) Reason() string {
+	return e.msg
+}
+
+// Context returns the line of the error, trimmed to the text around it,
+// and a line with a ^ below the error
+func (e *JSONError) Context() string {
+	var marker strings.Builder
+	for _, r := range e.context[:e.caret] {
+		if r == '\t' {
+			marker.WriteByte('\t')
+		} else {
+			marker.WriteByte(' ')
+		}
+	}
+	marker.WriteByte('^')
+	return e.context + "\n" + marker.String()
 }
 
 func NewJSONError(str []byte, pos int, msg string) *JSONError {
-	sublen := 10
-	start := pos - sublen
-	end := pos + sublen
-	if start < 0 {
-		start = 0
+	return newJSONError(str, pos, nil, msg)
+}
+
+// newJSONError returns the JSONError at offset pos caused by err.  The
+// context is at most jsonErrorContextLen bytes on each side of the error,
+// without the indentation of the line.
+func newJSONError(str []byte, pos int, err error, msg string) *JSONError {
+	if pos > len(str) {
+		pos = len(str)
+	} else if pos < 0 {
+		pos = 0
+	}
+	lineStart := bytes.LastIndexByte(str[:pos], '\n') + 1
+	lineEnd := len(str)
+	if n := bytes.IndexByte(str[pos:], '\n'); n >= 0 {
+		lineEnd = pos + n
 	}
-	if end > len(str) {
-		end = len(str)
+	start, end := lineStart, lineEnd
+	var prefix, suffix string
+	if pos-start > jsonErrorContextLen {
+		start, prefix = pos-jsonErrorContextLen, "..."
+		for start < pos && !utf8.RuneStart(str[start]) {
+			start++
+		}
+	}
+	if end-pos > jsonErrorContextLen {
+		end, suffix = pos+jsonErrorContextLen, "..."
+		for end > pos && !utf8.RuneStart(str[end]) {
+			end--
+		}
+	}
+	before := string(str[start:pos])
+	if start == lineStart {
+		before = strings.TrimLeft(before, " \t")
+	}
+	after := strings.TrimRight(string(str[pos:end]), "\r")
+	return &JSONError{
+		pos:     pos,
+		line:    bytes.Count(str[:pos], []byte{'\n'}) + 1,
+		column:  pos - lineStart + 1,
+		context: prefix + before + after + suffix,
+		caret:   len(prefix) + len(before),
+		msg:     msg,
+		err:     err,
 	}
-	substr := append(str[start:pos], '^')
-	substr = append(substr, str[pos:end]...)
-	return &JSONError{pos: pos, substr: string(substr), msg: msg}
 }
 
+const jsonErrorContextLen = 30
+
 type JSONObject interface {
 	gotypes.ISerializable
 
@@ -81,6 +179,10 @@ var (
 type JSONDict struct {
 	JSONValue
 	data map[string]JSONObject
+	// an ordered dict records the insertion order of its keys and
+	// serializes in that order
+	ordered bool
+	keys    []string
 }
 
 type JSONArray struct {
@@ -147,6 +249,26 @@ func hexstr2byte(str []byte) (byte, error) {
 	return v1*16 + v2, nil
 }
 
+// escapeDigits returns the first n bytes of str, the digits of a \u or \x
+// escape, or all of str if the input ends before
+func escapeDigits(str []byte, n int) []byte {
+	if len(str) > n {
+		return str[:n]
+	}
+	return str
+}
+
+// isHexDigits reports whether str holds only hex digits, so that an escape
+// too short is cut by the end of the input
+func isHexDigits(str []byte) bool {
+	for _, c := range str {
+		if _, e := hexchar2num(c); e != nil {
+			return false
+		}
+	}
+	return true
+}
+
 func hexstr2rune(str []byte) (rune, error) {
 	if len(str) < 4 {
 		return 0, fmt.Errorf("Input must be 4 hex chars")
@@ -187,24 +309,26 @@ func parseString(str []byte, offset int) (string, bool, int, error) {
 				switch str[i] {
 				case 'u':
 					i++
-					if i+4 >= len(str) {
-						return "", quote, i, NewJSONError(str, i, "Incomplete unicode")
+					hex := escapeDigits(str[i:], 4)
+					if len(hex) < 4 && isHexDigits(hex) {
+						return "", quote, i, newJSONError(str, i, ErrJSONTruncated, "Incomplete unicode")
 					}
-					r, e := hexstr2rune(str[i : i+4])
+					r, e := hexstr2rune(hex)
 					if e != nil {
-						return "", quote, i, NewJSONError(str, i, e.Error())
+						return "", quote, i, newJSONError(str, i, ErrJSONInvalidEscape, e.Error())
 					}
 					runen = utf8.EncodeRune(runebytes, r)
 					buffer.Write(runebytes[0:runen])
 					i += 4
 				case 'x':
 					i++
-					if i+2 >= len(str) {
-						return "", quote, i, NewJSONError(str, i, "Incomplete hex")
+					hex := escapeDigits(str[i:], 2)
+					if len(hex) < 2 && isHexDigits(hex) {
+						return "", quote, i, newJSONError(str, i, ErrJSONTruncated, "Incomplete hex")
 					}
-					b, e := hexstr2byte(str[i : i+2])
+					b, e := hexstr2byte(hex)
 					if e != nil {
-						return "", quote, i, NewJSONError(str, i, e.Error())
+						return "", quote, i, newJSONError(str, i, ErrJSONInvalidEscape, e.Error())
 					}
 					buffer.WriteByte(b)
 					i += 2
@@ -222,7 +346,7 @@ func parseString(str []byte, offset int) (string, bool, int, error) {
 					i++
 				}
 			} else {
-				return "", quote, i, NewJSONError(str, i, "Incomplete escape")
+				return "", quote, i, newJSONError(str, i, ErrJSONTruncated, "Incomplete escape")
 			}
 		} else if strings.IndexByte(endstr, str[i]) >= 0 {
 			if quote {
@@ -389,10 +513,11 @@ func (this *JSONBool) Value() bool {
 	return this.data
 }
 
-func parseDict(str []byte, offset int) (map[string]JSONObject, int, error) {
+func parseDict(str []byte, offset int, ordered bool, positions *JSONPositions) (map[string]JSONObject, []string, int, error) {
 	var dict = make(map[string]JSONObject)
+	var keys []string
 	if str[offset] != '{' {
-		return dict, offset, NewJSONError(str, offset, "{ not found")
+		return dict, keys, offset, newJSONError(str, offset, ErrJSONUnexpectedChar, "{ not found")
 	}
 	var i = offset + 1
 	var e error = nil
@@ -401,7 +526,7 @@ func parseDict(str []byte, offset int) (map[string]JSONObject, int, error) {
 	for !stop && i < len(str) {
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return dict, i, NewJSONError(str, i, "Truncated")
+			return dict, keys, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		if str[i] == '}' {
 			stop = true
@@ -410,41 +535,41 @@ func parseDict(str []byte, offset int) (map[string]JSONObject, int, error) {
 		}
 		key, _, i, e = parseString(str, i)
 		if e != nil {
-			return dict, i, e
+			return dict, keys, i, e
 		}
 		if i >= len(str) {
-			return dict, i, NewJSONError(str, i, "Truncated")
+			return dict, keys, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return dict, i, NewJSONError(str, i, "Truncated")
+			return dict, keys, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		if str[i] != ':' {
-			return dict, i, NewJSONError(str, i, ": not found")
+			return dict, keys, i, newJSONError(str, i, ErrJSONUnexpectedChar, ": not found")
 		}
 		i++
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return dict, i, NewJSONError(str, i, "Truncated")
+			return dict, keys, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		var val JSONObject = nil
-		switch str[i] {
-		case '[':
-			val = &JSONArray{}
-			i, e = val.parse(str, i)
-		case '{':
-			val = &JSONDict{}
-			i, e = val.parse(str, i)
-		default:
-			val, i, e = parseJSONValue(str, i)
+		if positions != nil {
+			positions.path = append(positions.path, key)
+		}
+		val, i, e = parseJSONObject(str, i, ordered, positions)
+		if positions != nil {
+			positions.path = positions.path[:len(positions.path)-1]
 		}
 		if e != nil {
-			return dict, i, e
+			return dict, keys, i, e
+		}
+		if _, ok := dict[key]; !ok && ordered {
+			keys = append(keys, key)
 		}
 		dict[key] = val
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return dict, i, NewJSONError(str, i, "Truncated")
+			return dict, keys, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		switch str[i] {
 		case ',':
@@ -453,16 +578,16 @@ func parseDict(str []byte, offset int) (map[string]JSONObject, int, error) {
 			i++
 			stop = true
 		default:
-			return dict, i, NewJSONError(str, i, "Unexpected char")
+			return dict, keys, i, newJSONError(str, i, ErrJSONUnexpectedChar, "Unexpected char")
 		}
 	}
-	return dict, i, nil
+	return dict, keys, i, nil
 }
 
-func parseArray(str []byte, offset int) ([]JSONObject, int, error) {
+func parseArray(str []byte, offset int, ordered bool, positions *JSONPositions) ([]JSONObject, int, error) {
 	var list = make([]JSONObject, 0)
 	if str[offset] != '[' {
-		return list, offset, NewJSONError(str, offset, "[ not found")
+		return list, offset, newJSONError(str, offset, ErrJSONUnexpectedChar, "[ not found")
 	}
 	var i = offset + 1
 	var val JSONObject = nil
@@ -471,32 +596,32 @@ func parseArray(str []byte, offset int) ([]JSONObject, int, error) {
 	for !stop && i < len(str) {
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return list, i, NewJSONError(str, i, "Truncated")
+			return list, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		switch str[i] {
 		case ']':
 			i++
 			stop = true
 			continue
-		case '[':
-			val = &JSONArray{}
-			i, e = val.parse(str, i)
-		case '{':
-			val = &JSONDict{}
-			i, e = val.parse(str, i)
 		default:
-			val, i, e = parseJSONValue(str, i)
+			if positions != nil {
+				positions.path = append(positions.path, strconv.Itoa(len(list)))
+			}
+			val, i, e = parseJSONObject(str, i, ordered, positions)
+			if positions != nil {
+				positions.path = positions.path[:len(positions.path)-1]
+			}
 		}
 		if e != nil {
 			return list, i, e
 		}
 		if i >= len(str) {
-			return list, i, NewJSONError(str, i, "Truncated")
+			return list, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		list = append(list, val)
 		i = skipEmpty(str, i)
 		if i >= len(str) {
-			return list, i, NewJSONError(str, i, "Truncated")
+			return list, i, newJSONError(str, i, ErrJSONTruncated, "Truncated")
 		}
 		switch str[i] {
 		case ',':
@@ -505,20 +630,83 @@ func parseArray(str []byte, offset int) ([]JSONObject, int, error) {
 			i++
 			stop = true
 		default:
-			return list, i, NewJSONError(str, i, "Unexpected char")
+			return list, i, newJSONError(str, i, ErrJSONUnexpectedChar, "Unexpected char")
 		}
 	}
 	return list, i, nil
 }
 
+// parseJSONObject parses the value at offset.  With ordered set, dicts
+// record the source order of their keys, and positions, if not nil,
+// records the offsets of the values.
+func parseJSONObject(str []byte, offset int, ordered bool, positions *JSONPositions) (JSONObject, int, error) {
+	var val JSONObject
+	var i int
+	var e error
+	switch str[offset] {
+	case '[':
+		array := &JSONArray{}
+		var list []JSONObject
+		list, i, e = parseArray(str, offset, ordered, positions)
+		if e == nil {
+			array.data = list
+		}
+		val = array
+	case '{':
+		dict := &JSONDict{ordered: ordered}
+		var data map[string]JSONObject
+		var keys []string
+		data, keys, i, e = parseDict(str, offset, ordered, positions)
+		if e == nil {
+			dict.data = data
+			dict.keys = keys
+		}
+		val = dict
+	default:
+		val, i, e = parseJSONValue(str, offset)
+	}
+	if e == nil && positions != nil {
+		positions.record(positions.path, val, offset)
+	}
+	return val, i, e
+}
+
 func (this *JSONDict) parse(str []byte, offset int) (int, error) {
-	val, i, e := parseDict(str, offset)
+	val, keys, i, e := parseDict(str, offset, this.ordered, nil)
 	if e == nil {
 		this.data = val
+		this.keys = keys
 	}
 	return i, e
 }
 
+// Keys returns the keys in insertion order for an ordered dict, and in
+// sorted order otherwise
+func (this *JSONDict) Keys() []string {
+	if this.ordered {
+		keys := make([]string, len(this.keys))
+		copy(keys, this.keys)
+		return keys
+	}
+	return this.SortedKeys()
+}
+
+// eachKey calls fn with the keys and values of the dict: in insertion
+// order for an ordered dict, and in sorted order if sorted is set or in map
+// order otherwise for an unordered one, saving the sorting where the order
+// does not matter
+func (this *JSONDict) eachKey(sorted bool, fn func(key string, val JSONObject)) {
+	if this.ordered || sorted {
+		for _, k := range this.Keys() {
+			fn(k, this.data[k])
+		}
+		return
+	}
+	for k, v := range this.data {
+		fn(k, v)
+	}
+}
+
 func (this *JSONDict) SortedKeys() []string {
 	keys := make([]string, 0, len(this.data))
 	for k := range this.data {
@@ -532,7 +720,7 @@ func (this *JSONDict) String() string {
 	var buffer bytes.Buffer
 	buffer.WriteByte('{')
 	var idx = 0
-	for _, k := range this.SortedKeys() {
+	for _, k := range this.Keys() {
 		v := this.data[k]
 		if idx > 0 {
 			buffer.WriteString(",")
@@ -560,7 +748,7 @@ func (this *JSONDict) prettyString(level int) string {
 	buffer.WriteString(tab)
 	buffer.WriteByte('{')
 	var idx = 0
-	for _, k := range this.SortedKeys() {
+	for _, k := range this.Keys() {
 		v := this.data[k]
 		if idx > 0 {
 			buffer.WriteString(",")
@@ -595,7 +783,7 @@ func (this *JSONDict) Value() map[string]JSONObject {
 }
 
 func (this *JSONArray) parse(str []byte, offset int) (int, error) {
-	val, i, e := parseArray(str, offset)
+	val, i, e := parseArray(str, offset, false, nil)
 	if e == nil {
 		this.data = val
 	}
@@ -652,21 +840,31 @@ func ParseString(str string) (JSONObject, error) {
 }
 
 func Parse(str []byte) (JSONObject, error) {
+	return parse(str, false, nil)
+}
+
+// ParseOrderedString is like ParseString, but the dicts are ordered and
+// keep the source order of their keys
+func ParseOrderedString(str string) (JSONObject, error) {
+	return ParseOrdered([]byte(str))
+}
+
+func ParseOrdered(str []byte) (JSONObject, error) {
+	return parse(str, true, nil)
+}
+
+func parse(str []byte, ordered bool, positions *JSONPositions) (JSONObject, error) {
 	var i = 0
 	i = skipEmpty(str, i)
 	var val JSONObject = nil
 	var e error = nil
 	if i < len(str) {
 		switch str[i] {
-		case '{':
-			val = &JSONDict{}
-			i, e = val.parse(str, i)
-		case '[':
-			val = &JSONArray{}
-			i, e = val.parse(str, i)
+		case '{', '[':
+			val, i, e = parseJSONObject(str, i, ordered, positions)
 		default:
 			// val, i, e = parseJSONValue(str, i)
-			return nil, NewJSONError(str, i, "Invalid JSON string")
+			return nil, newJSONError(str, i, ErrJSONUnexpectedChar, "Invalid JSON string")
 		}
 		if e != nil {
 			return nil, e
@@ -674,6 +872,6 @@ func Parse(str []byte) (JSONObject, error) {
 			return val, nil
 		}
 	} else {
-		return nil, NewJSONError(str, i, "Empty string")
+		return nil, newJSONError(str, i, ErrJSONEmpty, "Empty string")
 	}
 }
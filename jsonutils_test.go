@@ -1,6 +1,8 @@
 package jsonutils
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -96,3 +98,67 @@ func TestJSONParse(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONError(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	cases := []struct {
+		json    string
+		cause   error
+		line    int
+		column  int
+		context string
+	}{
+		{"{\"a\": 1,\n  \"b\" 2}", ErrJSONUnexpectedChar, 2, 7, "\"b\" 2}\n    ^"},
+		{"{\"a\": [1, 2", ErrJSONTruncated, 1, 12, "{\"a\": [1, 2\n           ^"},
+		{"  ", ErrJSONEmpty, 1, 3, "\n^"},
+		{"[\"\\u12x4\"]", ErrJSONInvalidEscape, 1, 5, "[\"\\u12x4\"]\n    ^"},
+		{"{\"a\":\"\\u12\"}", ErrJSONInvalidEscape, 1, 9, "{\"a\":\"\\u12\"}\n        ^"},
+		{"[\"\\x4\", 1]", ErrJSONInvalidEscape, 1, 5, "[\"\\x4\", 1]\n    ^"},
+		{"[\"\\u12", ErrJSONTruncated, 1, 5, "[\"\\u12\n    ^"},
+		{"[\"\\x", ErrJSONTruncated, 1, 5, "[\"\\x\n    ^"},
+		{"[\"" + long + "\" \"" + long + "\"]", ErrJSONUnexpectedChar, 1, 45, "..." + long[:28] + "\" \"" + long[:29] + "...\n" + strings.Repeat(" ", 33) + "^"},
+		{"[\"" + strings.Repeat("é", 20) + "\" 1]", ErrJSONUnexpectedChar, 1, 45, "..." + strings.Repeat("é", 14) + "\" 1]\n" + strings.Repeat(" ", 19) + "^"},
+	}
+	for _, c := range cases {
+		input := []byte(c.json)
+		_, err := Parse(input)
+		jerr, ok := err.(*JSONError)
+		if !ok {
+			t.Errorf("%q: got %T %v", c.json, err, err)
+			continue
+		}
+		if !errors.Is(err, c.cause) {
+			t.Errorf("%q: %v is not %v", c.json, err, c.cause)
+		}
+		if jerr.Line() != c.line || jerr.Column() != c.column {
+			t.Errorf("%q: got line %d column %d, want %d %d", c.json, jerr.Line(), jerr.Column(), c.line, c.column)
+		}
+		if got := jerr.Context(); got != c.context {
+			t.Errorf("%q: got context %q, want %q", c.json, got, c.context)
+		}
+		if string(input) != c.json {
+			t.Errorf("%q: input changed to %q", c.json, input)
+		}
+	}
+	if obj, err := ParseString(`["\u00e9\x41"]`); err != nil || obj.String() != `["éA"]` {
+		t.Errorf("escapes: got %v %v", obj, err)
+	}
+}
+
+func TestJSONErrorString(t *testing.T) {
+	_, err := ParseString("{\"a\": 1,\n  \"b\" 2}")
+	want := `JSON error : not found at line 2 column 7 (offset 15): "b" ^2}`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+	jerr := err.(*JSONError)
+	if jerr.Offset() != 15 || jerr.Reason() != ": not found" {
+		t.Errorf("got offset %d reason %q", jerr.Offset(), jerr.Reason())
+	}
+	if errors.Is(err, ErrJSONTruncated) {
+		t.Errorf("%v is %v", err, ErrJSONTruncated)
+	}
+	if errors.Unwrap(NewJSONError([]byte("x"), 0, "custom")) != nil {
+		t.Errorf("NewJSONError has a cause")
+	}
+}
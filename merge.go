@@ -0,0 +1,278 @@
+package jsonutils
+
+/**
+jsonutils.DeepMerge
+
+Merge JSONObject trees recursively, e.g. to layer configurations of
+defaults, environment and user overrides:
+
+	conf, err := DeepMergeAll(&DeepMergeOptions{
+		PathRules: map[string]ArrayMergeRule{
+			"/servers": {Strategy: ArrayMergeByKey, MergeKey: "name"},
+			"/dns":     {Strategy: ArrayMergeUnion},
+		},
+	}, defaults, env, user)
+
+Dicts are merged key by key.  How arrays are combined is decided by the
+ArrayMergeRule of their path, and other values of the override replace
+those of the base, unless OnConflict decides otherwise.
+
+*/
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ArrayMergeStrategy int
+
+const (
+	// the override array replaces the base array
+	ArrayMergeReplace ArrayMergeStrategy = iota
+	// elements of the override array are appended to the base array
+	ArrayMergeAppend
+	// elements of the override array not in the base array are appended
+	ArrayMergeUnion
+	// dict elements with the same value of MergeKey are merged, others
+	// are appended
+	ArrayMergeByKey
+)
+
+type ArrayMergeRule struct {
+	Strategy ArrayMergeStrategy
+	// MergeKey is the key identifying dict elements, e.g. name or id,
+	// for ArrayMergeByKey
+	MergeKey string
+}
+
+type DeepMergeOptions struct {
+	// ArrayMergeRule applies to arrays without a rule in PathRules
+	ArrayMergeRule
+
+	// PathRules overrides the array merge rule of the arrays at the given
+	// JSON pointers.  A * token matches any key or index, e.g.
+	// /servers/*/nics.  Of the patterns matching a path, the one with the
+	// fewest * applies, and of those the one whose first * comes last.
+	PathRules map[string]ArrayMergeRule
+
+	// OnConflict is called when base and override hold different values
+	// at path that cannot be merged, i.e. they are not both dicts or both
+	// arrays.  It returns the value to keep.  If unset, override wins.
+	OnConflict func(path string, base, override JSONObject) (JSONObject, error)
+}
+
+// DeepMerge merges override into base and returns the result.  The inputs
+// are not modified.  opts may be nil for the default options.
+func DeepMerge(base, override JSONObject, opts *DeepMergeOptions) (JSONObject, error) {
+	if opts == nil {
+		opts = &DeepMergeOptions{}
+	}
+	var rules []sPathMergeRule
+	paths := make([]string, 0, len(opts.PathRules))
+	for path := range opts.PathRules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		rule := opts.PathRules[path]
+		tokens, err := ParseJSONPointer(path)
+		if err != nil {
+			return nil, err
+		}
+		if rule.Strategy == ArrayMergeByKey && len(rule.MergeKey) == 0 {
+			return nil, fmt.Errorf("path %s: merge by key requires MergeKey", path)
+		}
+		rules = append(rules, sPathMergeRule{path: path, tokens: tokens, rule: rule})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].moreSpecific(rules[j])
+	})
+	if opts.Strategy == ArrayMergeByKey && len(opts.MergeKey) == 0 {
+		return nil, fmt.Errorf("merge by key requires MergeKey")
+	}
+	merger := &deepMerger{opts: opts, rules: rules}
+	return merger.merge(base, override, []string{})
+}
+
+// DeepMergeAll merges layers in order, later layers taking precedence
+func DeepMergeAll(opts *DeepMergeOptions, layers ...JSONObject) (JSONObject, error) {
+	if len(layers) == 0 {
+		return JSONNull, nil
+	}
+	result := DeepCopy(layers[0])
+	for _, layer := range layers[1:] {
+		var err error
+		result, err = DeepMerge(result, layer, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+type sPathMergeRule struct {
+	path   string
+	tokens []string
+	rule   ArrayMergeRule
+}
+
+// moreSpecific orders the rules so that the first one matching a path is
+// the most specific: the one with fewer * tokens, or with a key where the
+// other has its first *
+func (r sPathMergeRule) moreSpecific(o sPathMergeRule) bool {
+	if nr, no := countStars(r.tokens), countStars(o.tokens); nr != no {
+		return nr < no
+	}
+	for i := 0; i < len(r.tokens) && i < len(o.tokens); i++ {
+		if sr, so := r.tokens[i] == "*", o.tokens[i] == "*"; sr != so {
+			return so
+		}
+	}
+	return r.path < o.path
+}
+
+func countStars(tokens []string) int {
+	count := 0
+	for _, token := range tokens {
+		if token == "*" {
+			count++
+		}
+	}
+	return count
+}
+
+type deepMerger struct {
+	opts  *DeepMergeOptions
+	rules []sPathMergeRule
+}
+
+func matchPointerPattern(pattern, tokens []string) bool {
+	if len(pattern) != len(tokens) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *deepMerger) arrayRule(tokens []string) ArrayMergeRule {
+	for _, r := range m.rules {
+		if matchPointerPattern(r.tokens, tokens) {
+			return r.rule
+		}
+	}
+	return m.opts.ArrayMergeRule
+}
+
+func (m *deepMerger) conflict(tokens []string, base, override JSONObject) (JSONObject, error) {
+	if m.opts.OnConflict == nil || jsonValueEquals(base, override) {
+		return DeepCopy(override), nil
+	}
+	val, err := m.opts.OnConflict(JSONPointer(tokens...), base, override)
+	if err != nil {
+		return nil, err
+	}
+	return DeepCopy(val), nil
+}
+
+func (m *deepMerger) merge(base, override JSONObject, tokens []string) (JSONObject, error) {
+	switch vb := base.(type) {
+	case *JSONDict:
+		if vo, ok := override.(*JSONDict); ok {
+			return m.mergeDict(vb, vo, tokens)
+		}
+	case *JSONArray:
+		if vo, ok := override.(*JSONArray); ok {
+			return m.mergeArray(vb, vo, tokens)
+		}
+	}
+	return m.conflict(tokens, base, override)
+}
+
+func (m *deepMerger) mergeDict(base, override *JSONDict, tokens []string) (JSONObject, error) {
+	result := base.newDictLike()
+	for _, k := range base.Keys() {
+		result.Set(k, DeepCopy(base.data[k]))
+	}
+	for _, k := range override.Keys() {
+		v := override.data[k]
+		if cur, ok := base.data[k]; ok {
+			merged, err := m.merge(cur, v, subPointer(tokens, k))
+			if err != nil {
+				return nil, err
+			}
+			result.Set(k, merged)
+		} else {
+			result.Set(k, DeepCopy(v))
+		}
+	}
+	return result, nil
+}
+
+func (m *deepMerger) mergeArray(base, override *JSONArray, tokens []string) (JSONObject, error) {
+	rule := m.arrayRule(tokens)
+	result := NewArray()
+	switch rule.Strategy {
+	case ArrayMergeReplace:
+		return DeepCopy(override), nil
+	case ArrayMergeAppend:
+		result = DeepCopy(base).(*JSONArray)
+		for _, v := range override.data {
+			result.Add(DeepCopy(v))
+		}
+	case ArrayMergeUnion:
+		result = DeepCopy(base).(*JSONArray)
+		for _, v := range override.data {
+			found := false
+			for _, e := range result.data {
+				if jsonValueEquals(e, v) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				result.Add(DeepCopy(v))
+			}
+		}
+	case ArrayMergeByKey:
+		result = DeepCopy(base).(*JSONArray)
+		for _, v := range override.data {
+			idx := -1
+			if key, ok := mergeKeyOf(v, rule.MergeKey); ok {
+				for i, e := range result.data {
+					if ekey, ok := mergeKeyOf(e, rule.MergeKey); ok && jsonValueEquals(key, ekey) {
+						idx = i
+						break
+					}
+				}
+			}
+			if idx < 0 {
+				result.Add(DeepCopy(v))
+				continue
+			}
+			merged, err := m.merge(result.data[idx], v, subPointer(tokens, fmt.Sprintf("%d", idx)))
+			if err != nil {
+				return nil, err
+			}
+			result.data[idx] = merged
+		}
+	default:
+		return nil, fmt.Errorf("unknown array merge strategy %d at %s", rule.Strategy, JSONPointer(tokens...))
+	}
+	return result, nil
+}
+
+func mergeKeyOf(o JSONObject, key string) (JSONObject, bool) {
+	dict, ok := o.(*JSONDict)
+	if !ok {
+		return nil, false
+	}
+	val, ok := dict.data[key]
+	if !ok || val == JSONNull {
+		return nil, false
+	}
+	return val, true
+}
@@ -0,0 +1,129 @@
+package jsonutils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeepMerge(t *testing.T) {
+	base := `{"name": "base", "spec": {"replicas": 1, "image": "nginx", "ports": [80], "env": {"A": "1"}},
+		"servers": [{"name": "a", "cpu": 1, "tags": ["x"]}, {"name": "b", "cpu": 1}]}`
+	override := `{"spec": {"replicas": 3, "ports": [443, 80], "env": {"B": "2"}},
+		"servers": [{"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`
+	cases := []struct {
+		name string
+		opts *DeepMergeOptions
+		want string
+	}{
+		{
+			name: "replace",
+			want: `{"name": "base", "spec": {"replicas": 3, "image": "nginx", "ports": [443, 80], "env": {"A": "1", "B": "2"}},
+				"servers": [{"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`,
+		},
+		{
+			name: "append",
+			opts: &DeepMergeOptions{ArrayMergeRule: ArrayMergeRule{Strategy: ArrayMergeAppend}},
+			want: `{"name": "base", "spec": {"replicas": 3, "image": "nginx", "ports": [80, 443, 80], "env": {"A": "1", "B": "2"}},
+				"servers": [{"name": "a", "cpu": 1, "tags": ["x"]}, {"name": "b", "cpu": 1}, {"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`,
+		},
+		{
+			name: "union",
+			opts: &DeepMergeOptions{ArrayMergeRule: ArrayMergeRule{Strategy: ArrayMergeUnion}},
+			want: `{"name": "base", "spec": {"replicas": 3, "image": "nginx", "ports": [80, 443], "env": {"A": "1", "B": "2"}},
+				"servers": [{"name": "a", "cpu": 1, "tags": ["x"]}, {"name": "b", "cpu": 1}, {"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`,
+		},
+		{
+			name: "by key with path rules",
+			opts: &DeepMergeOptions{
+				PathRules: map[string]ArrayMergeRule{
+					"/servers":        {Strategy: ArrayMergeByKey, MergeKey: "name"},
+					"/servers/*/tags": {Strategy: ArrayMergeAppend},
+				},
+			},
+			want: `{"name": "base", "spec": {"replicas": 3, "image": "nginx", "ports": [443, 80], "env": {"A": "1", "B": "2"}},
+				"servers": [{"name": "a", "cpu": 1, "tags": ["x"]}, {"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`,
+		},
+		{
+			name: "overlapping path rules",
+			opts: &DeepMergeOptions{
+				PathRules: map[string]ArrayMergeRule{
+					"/*":       {Strategy: ArrayMergeAppend},
+					"/servers": {Strategy: ArrayMergeByKey, MergeKey: "name"},
+					"/*/ports": {Strategy: ArrayMergeUnion},
+					"/spec/*":  {Strategy: ArrayMergeAppend},
+				},
+			},
+			want: `{"name": "base", "spec": {"replicas": 3, "image": "nginx", "ports": [80, 443, 80], "env": {"A": "1", "B": "2"}},
+				"servers": [{"name": "a", "cpu": 1, "tags": ["x"]}, {"name": "b", "cpu": 4, "tags": ["y"]}, {"name": "c"}, {"cpu": 8}]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, _ := ParseString(base)
+			o, _ := ParseString(override)
+			want, _ := ParseString(c.want)
+			// merge a few times to catch a result depending on the order of
+			// the PathRules map
+			for i := 0; i < 10; i++ {
+				got, err := DeepMerge(b, o, c.opts)
+				if err != nil {
+					t.Fatalf("merge fail %s", err)
+				}
+				if !got.Equals(want) {
+					t.Fatalf("want %s\ngot  %s", want, got)
+				}
+			}
+			b2, _ := ParseString(base)
+			o2, _ := ParseString(override)
+			if !b.Equals(b2) || !o.Equals(o2) {
+				t.Errorf("inputs modified")
+			}
+		})
+	}
+}
+
+func TestDeepMergeConflict(t *testing.T) {
+	base, _ := ParseString(`{"a": 1, "b": {"c": "x"}, "d": [1], "e": 2}`)
+	override, _ := ParseString(`{"a": 2, "b": "y", "d": {"f": 1}, "e": 2.0}`)
+	conflicts := []string{}
+	opts := &DeepMergeOptions{
+		OnConflict: func(path string, b, o JSONObject) (JSONObject, error) {
+			conflicts = append(conflicts, path)
+			return b, nil
+		},
+	}
+	got, err := DeepMerge(base, override, opts)
+	if err != nil {
+		t.Fatalf("merge fail %s", err)
+	}
+	if !jsonValueEquals(got, base) {
+		t.Errorf("conflict callback keeping base should give base, got %s", got)
+	}
+	if fmt.Sprintf("%v", conflicts) != "[/a /b /d]" {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	opts.OnConflict = func(path string, b, o JSONObject) (JSONObject, error) {
+		return nil, fmt.Errorf("conflict at %s", path)
+	}
+	if _, err := DeepMerge(base, override, opts); err == nil {
+		t.Errorf("conflict callback error should fail the merge")
+	}
+	opts = &DeepMergeOptions{ArrayMergeRule: ArrayMergeRule{Strategy: ArrayMergeByKey}}
+	if _, err := DeepMerge(base, override, opts); err == nil {
+		t.Errorf("merge by key without key should fail")
+	}
+}
+
+func TestDeepMergeAll(t *testing.T) {
+	defaults, _ := ParseString(`{"log": {"level": "info", "file": "/var/log/app"}, "port": 80}`)
+	env, _ := ParseString(`{"log": {"level": "debug"}}`)
+	user, _ := ParseString(`{"port": 8080}`)
+	want, _ := ParseString(`{"log": {"level": "debug", "file": "/var/log/app"}, "port": 8080}`)
+	got, err := DeepMergeAll(nil, defaults, env, user)
+	if err != nil {
+		t.Fatalf("merge fail %s", err)
+	}
+	if !got.Equals(want) {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
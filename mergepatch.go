@@ -0,0 +1,86 @@
+package jsonutils
+
+/**
+jsonutils.MergePatch
+
+JSON Merge Patch (RFC 7396): nested dicts of the patch are merged into the
+target recursively, a JSONNull value deletes the key and any other value
+replaces the target value as a whole.
+
+*/
+
+// MergePatch returns the result of applying patch to target.  Neither
+// target nor patch is modified, and the result shares no values with them.
+func MergePatch(target, patch JSONObject) JSONObject {
+	if target != nil {
+		target = DeepCopy(target)
+	}
+	return mergePatch(target, patch)
+}
+
+// mergePatch applies patch to target, which it may modify
+func mergePatch(target, patch JSONObject) JSONObject {
+	patchDict, ok := patch.(*JSONDict)
+	if !ok {
+		return DeepCopy(patch)
+	}
+	result, ok := target.(*JSONDict)
+	if !ok {
+		result = NewDict()
+	}
+	for _, k := range patchDict.Keys() {
+		v := patchDict.data[k]
+		if v == JSONNull {
+			result.Remove(k)
+			continue
+		}
+		var cur JSONObject = JSONNull
+		if exist, ok := result.data[k]; ok {
+			cur = exist
+		}
+		result.Set(k, mergePatch(cur, v))
+	}
+	return result
+}
+
+// CreateMergePatch returns a merge patch that turns original into
+// modified.  As JSONNull in a merge patch means deletion, null values in
+// modified dicts cannot be expressed and are treated as removed keys.
+func CreateMergePatch(original, modified JSONObject) JSONObject {
+	origDict, ok1 := original.(*JSONDict)
+	modDict, ok2 := modified.(*JSONDict)
+	if !ok1 || !ok2 {
+		return DeepCopy(modified)
+	}
+	patch := NewDict()
+	for _, k := range origDict.SortedKeys() {
+		if v, ok := modDict.data[k]; !ok || v == JSONNull {
+			patch.Set(k, JSONNull)
+		}
+	}
+	for _, k := range modDict.SortedKeys() {
+		v := modDict.data[k]
+		if v == JSONNull {
+			continue
+		}
+		orig, ok := origDict.data[k]
+		if !ok {
+			patch.Set(k, DeepCopy(v))
+			continue
+		}
+		if orig.Equals(v) {
+			continue
+		}
+		if _, isDict := v.(*JSONDict); isDict {
+			if _, isDict := orig.(*JSONDict); isDict {
+				sub := CreateMergePatch(orig, v)
+				if sub.(*JSONDict).Length() > 0 {
+					patch.Set(k, sub)
+				}
+				continue
+			}
+		}
+		patch.Set(k, DeepCopy(v))
+	}
+	return patch
+}
@@ -0,0 +1,85 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples from RFC 7396 Appendix A
+	cases := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		target, _ := ParseString(c.target)
+		orig := target.String()
+		patch, _ := ParseString(c.patch)
+		want, _ := ParseString(c.want)
+		got := MergePatch(target, patch)
+		if !got.Equals(want) {
+			t.Errorf("MergePatch(%s, %s): want %s, got %s", c.target, c.patch, want, got)
+		}
+		if target.String() != orig {
+			t.Errorf("MergePatch(%s, %s) modifies target", c.target, c.patch)
+		}
+	}
+	if got := MergePatch(NewDict(), JSONNull); got != JSONNull {
+		t.Errorf("null patch should give null, got %s", got)
+	}
+	target, _ := ParseString(`{"a": {"b": 1}, "c": [1]}`)
+	patch, _ := ParseString(`{"d": {"e": 2}}`)
+	got := MergePatch(target, patch).(*JSONDict)
+	got.data["a"].(*JSONDict).Set("b", NewInt(3))
+	got.data["c"].(*JSONArray).Add(NewInt(2))
+	got.data["d"].(*JSONDict).Set("e", NewInt(4))
+	if target.String() != `{"a":{"b":1},"c":[1]}` || patch.String() != `{"d":{"e":2}}` {
+		t.Errorf("changing the result changes target %s or patch %s", target, patch)
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	cases := []struct {
+		original string
+		modified string
+		want     string
+	}{
+		{`{"a":1,"b":2}`, `{"a":1,"b":2}`, `{}`},
+		{`{"a":1,"b":{"c":1,"d":2}}`, `{"a":2,"b":{"c":1}}`, `{"a":2,"b":{"d":null}}`},
+		{`{"a":1,"b":[1,2]}`, `{"b":[1],"c":{"d":1}}`, `{"a":null,"b":[1],"c":{"d":1}}`},
+		{`{"a":{"b":1}}`, `{"a":"x"}`, `{"a":"x"}`},
+		{`{"a":1,"n":null}`, `{"a":null}`, `{"a":null,"n":null}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+	}
+	for _, c := range cases {
+		original, _ := ParseString(c.original)
+		modified, _ := ParseString(c.modified)
+		want, _ := ParseString(c.want)
+		got := CreateMergePatch(original, modified)
+		if !got.Equals(want) {
+			t.Errorf("CreateMergePatch(%s, %s): want %s, got %s", c.original, c.modified, want, got)
+		}
+		applied := MergePatch(original, got)
+		expect := MergePatch(NewDict(), modified)
+		if _, ok := modified.(*JSONDict); !ok {
+			expect = modified
+		}
+		if !applied.Equals(expect) {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", c.original, got, applied, expect)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package jsonutils
+
+/**
+jsonutils.ApplyPatch
+
+Apply and create JSON Patch (RFC 6902) documents, e.g.
+
+	[
+		{"op": "replace", "path": "/spec/replicas", "value": 3},
+		{"op": "add", "path": "/spec/ports/-", "value": 8080},
+		{"op": "remove", "path": "/metadata/labels/stale"}
+	]
+
+*/
+
+import (
+	"fmt"
+)
+
+const (
+	PATCH_OP_ADD     = "add"
+	PATCH_OP_REMOVE  = "remove"
+	PATCH_OP_REPLACE = "replace"
+	PATCH_OP_MOVE    = "move"
+	PATCH_OP_COPY    = "copy"
+	PATCH_OP_TEST    = "test"
+)
+
+// ApplyPatch applies patch to a copy of doc and returns the result.  The
+// patch is applied atomically: if any operation fails, an error is returned
+// and doc is left untouched.
+func ApplyPatch(doc JSONObject, patch *JSONArray) (JSONObject, error) {
+	if patch == nil {
+		return nil, fmt.Errorf("nil patch")
+	}
+	result := DeepCopy(doc)
+	for i, op := range patch.data {
+		var err error
+		result, err = applyPatchOperation(result, op)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d: %s", i, err)
+		}
+	}
+	return result, nil
+}
+
+func patchOperationPointer(op JSONObject, key string) (string, []string, error) {
+	ptr, err := op.GetString(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("missing %s", key)
+	}
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return "", nil, err
+	}
+	return ptr, tokens, nil
+}
+
+func applyPatchOperation(doc JSONObject, op JSONObject) (JSONObject, error) {
+	if _, ok := op.(*JSONDict); !ok {
+		return nil, fmt.Errorf("operation must be a JSONDict, got %s", jsonTypeName(op))
+	}
+	opName, err := op.GetString("op")
+	if err != nil {
+		return nil, fmt.Errorf("missing op")
+	}
+	path, tokens, err := patchOperationPointer(op, "path")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", opName, err)
+	}
+	value, _ := op.Get("value")
+	switch opName {
+	case PATCH_OP_ADD, PATCH_OP_REPLACE, PATCH_OP_TEST:
+		if value == nil {
+			return nil, fmt.Errorf("%s %s: missing value", opName, path)
+		}
+	}
+	fail := func(err error) (JSONObject, error) {
+		return nil, fmt.Errorf("%s %s: %s", opName, path, err)
+	}
+	switch opName {
+	case PATCH_OP_ADD:
+		doc, err = patchAdd(doc, tokens, DeepCopy(value))
+	case PATCH_OP_REMOVE:
+		_, err = pointerRemove(doc, tokens)
+	case PATCH_OP_REPLACE:
+		if _, err = pointerGet(doc, tokens); err != nil {
+			return fail(err)
+		}
+		if len(tokens) == 0 {
+			return DeepCopy(value), nil
+		}
+		err = pointerSet(doc, tokens, DeepCopy(value), false)
+	case PATCH_OP_MOVE, PATCH_OP_COPY:
+		from, fromTokens, err := patchOperationPointer(op, "from")
+		if err != nil {
+			return fail(err)
+		}
+		if opName == PATCH_OP_MOVE {
+			if from == path {
+				_, err = pointerGet(doc, fromTokens)
+				if err != nil {
+					return fail(err)
+				}
+				return doc, nil
+			}
+			if len(fromTokens) < len(tokens) && isPointerPrefix(fromTokens, tokens) {
+				return fail(fmt.Errorf("cannot move %s into its own child", from))
+			}
+		}
+		val, err := pointerGet(doc, fromTokens)
+		if err != nil {
+			return fail(fmt.Errorf("from %s: %s", from, err))
+		}
+		if opName == PATCH_OP_MOVE {
+			if len(fromTokens) == 0 {
+				return fail(fmt.Errorf("cannot move the root"))
+			}
+			_, err = pointerRemove(doc, fromTokens)
+		} else {
+			val = DeepCopy(val)
+		}
+		if err == nil {
+			doc, err = patchAdd(doc, tokens, val)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	case PATCH_OP_TEST:
+		var cur JSONObject
+		cur, err = pointerGet(doc, tokens)
+		if err == nil && !jsonValueEquals(cur, value) {
+			err = fmt.Errorf("value %s does not equal %s", cur, value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown op %q", opName)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return doc, nil
+}
+
+func patchAdd(doc JSONObject, tokens []string, val JSONObject) (JSONObject, error) {
+	if len(tokens) == 0 {
+		return val, nil
+	}
+	err := pointerSet(doc, tokens, val, true)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func isPointerPrefix(prefix, tokens []string) bool {
+	if len(prefix) > len(tokens) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newPatchOperation(op string, tokens []string, value JSONObject) *JSONDict {
+	dict := NewDict()
+	dict.Set("op", NewString(op))
+	dict.Set("path", NewString(JSONPointer(tokens...)))
+	if value != nil {
+		dict.Set("value", DeepCopy(value))
+	}
+	return dict
+}
+
+// CreatePatch returns a JSON Patch that turns from into to.  Dicts are
+// compared key by key and arrays with an edit distance, so that only
+// the changed parts of the trees appear in the patch.  Numbers are
+// compared by value, as by the test operation, so 1 and 1.0 are equal.
+func CreatePatch(from, to JSONObject) *JSONArray {
+	patch := NewArray()
+	createPatch(patch, from, to, []string{})
+	return patch
+}
+
+func subPointer(tokens []string, token string) []string {
+	sub := make([]string, len(tokens)+1)
+	copy(sub, tokens)
+	sub[len(tokens)] = token
+	return sub
+}
+
+func createPatch(patch *JSONArray, from, to JSONObject, tokens []string) {
+	if jsonValueEquals(from, to) {
+		return
+	}
+	switch vf := from.(type) {
+	case *JSONDict:
+		if vt, ok := to.(*JSONDict); ok {
+			for _, k := range vf.SortedKeys() {
+				if v2, ok := vt.data[k]; ok {
+					createPatch(patch, vf.data[k], v2, subPointer(tokens, k))
+				} else {
+					patch.Add(newPatchOperation(PATCH_OP_REMOVE, subPointer(tokens, k), nil))
+				}
+			}
+			for _, k := range vt.SortedKeys() {
+				if _, ok := vf.data[k]; !ok {
+					patch.Add(newPatchOperation(PATCH_OP_ADD, subPointer(tokens, k), vt.data[k]))
+				}
+			}
+			return
+		}
+	case *JSONArray:
+		if vt, ok := to.(*JSONArray); ok {
+			createArrayPatch(patch, vf.data, vt.data, tokens)
+			return
+		}
+	}
+	patch.Add(newPatchOperation(PATCH_OP_REPLACE, tokens, to))
+}
+
+// createArrayPatch emits the shortest sequence of element removals,
+// insertions and replacements, found by dynamic programming on the
+// edit distance of the two arrays
+func createArrayPatch(patch *JSONArray, from, to []JSONObject, tokens []string) {
+	n, m := len(from), len(to)
+	// dist[i][j] is the edit distance between from[i:] and to[j:]
+	dist := make([][]int, n+1)
+	for i := range dist {
+		dist[i] = make([]int, m+1)
+	}
+	for i := n; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			switch {
+			case i == n:
+				dist[i][j] = m - j
+			case j == m:
+				dist[i][j] = n - i
+			case jsonValueEquals(from[i], to[j]):
+				dist[i][j] = dist[i+1][j+1]
+			default:
+				dist[i][j] = 1 + minInt(dist[i+1][j+1], minInt(dist[i+1][j], dist[i][j+1]))
+			}
+		}
+	}
+	i, j, idx := 0, 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && jsonValueEquals(from[i], to[j]) && dist[i][j] == dist[i+1][j+1]:
+			i, j, idx = i+1, j+1, idx+1
+		case i < n && j < m && dist[i][j] == dist[i+1][j+1]+1:
+			createPatch(patch, from[i], to[j], subPointer(tokens, fmt.Sprintf("%d", idx)))
+			i, j, idx = i+1, j+1, idx+1
+		case i < n && dist[i][j] == dist[i+1][j]+1:
+			patch.Add(newPatchOperation(PATCH_OP_REMOVE, subPointer(tokens, fmt.Sprintf("%d", idx)), nil))
+			i++
+		default:
+			patch.Add(newPatchOperation(PATCH_OP_ADD, subPointer(tokens, fmt.Sprintf("%d", idx)), to[j]))
+			j, idx = j+1, idx+1
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
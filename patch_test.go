@@ -0,0 +1,151 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{
+			name:  "add object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "add array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}, {"op": "add", "path": "/foo/-", "value": "end"}]`,
+			want:  `{"foo": ["bar", "qux", "baz", "end"]}`,
+		},
+		{
+			name:  "remove",
+			doc:   `{"baz": "qux", "foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/baz"}, {"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "replace",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "move",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}, "arr": [1, 2, 3, 4]}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}, {"op": "move", "from": "/arr/1", "path": "/arr/3"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}, "arr": [1, 3, 4, 2]}`,
+		},
+		{
+			name:  "copy",
+			doc:   `{"foo": {"bar": [1]}}`,
+			patch: `[{"op": "copy", "from": "/foo/bar", "path": "/baz"}, {"op": "add", "path": "/baz/-", "value": 2}]`,
+			want:  `{"foo": {"bar": [1]}, "baz": [1, 2]}`,
+		},
+		{
+			name:  "test",
+			doc:   `{"baz": "qux", "foo": ["a", 2, "c"], "n": 1.0}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}, {"op": "test", "path": "/n", "value": 1}]`,
+			want:  `{"baz": "qux", "foo": ["a", 2, "c"], "n": 1.0}`,
+		},
+		{
+			name:  "replace root",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "", "value": [1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "null value",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/foo", "value": null}]`,
+			want:  `{"foo": null}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, _ := ParseString(c.doc)
+			orig := doc.String()
+			patch, _ := ParseString(c.patch)
+			want, _ := ParseString(c.want)
+			got, err := ApplyPatch(doc, patch.(*JSONArray))
+			if err != nil {
+				t.Fatalf("apply fail %s", err)
+			}
+			if !got.Equals(want) {
+				t.Errorf("want %s, got %s", want, got)
+			}
+			if doc.String() != orig {
+				t.Errorf("doc modified: %s", doc)
+			}
+		})
+	}
+}
+
+func TestApplyPatchError(t *testing.T) {
+	doc, _ := ParseString(`{"foo": {"bar": "baz"}, "arr": [1]}`)
+	cases := []string{
+		`[{"op": "test", "path": "/foo/bar", "value": "qux"}]`,
+		`[{"op": "add", "path": "/missing/x", "value": 1}]`,
+		`[{"op": "add", "path": "/arr/2", "value": 1}]`,
+		`[{"op": "remove", "path": "/nope"}]`,
+		`[{"op": "replace", "path": "/nope", "value": 1}]`,
+		`[{"op": "move", "from": "/foo", "path": "/foo/child"}]`,
+		`[{"op": "copy", "from": "/nope", "path": "/x"}]`,
+		`[{"op": "add", "path": "/x"}]`,
+		`[{"op": "invalid", "path": "/x"}]`,
+		`[{"op": "remove", "path": ""}]`,
+		`[{"op": "add", "path": "/x", "value": 1}, {"op": "test", "path": "/x", "value": 2}]`,
+	}
+	for _, c := range cases {
+		patch, _ := ParseString(c)
+		_, err := ApplyPatch(doc, patch.(*JSONArray))
+		if err == nil {
+			t.Errorf("%s should fail", c)
+		} else {
+			t.Logf("%s: %s", c, err)
+		}
+	}
+	if doc.Contains("x") {
+		t.Errorf("failed patch should not modify the document")
+	}
+	if _, err := ApplyPatch(doc, nil); err == nil {
+		t.Errorf("nil patch should fail")
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	cases := []struct {
+		from string
+		to   string
+		ops  int
+	}{
+		{`{"a": 1}`, `{"a": 1}`, 0},
+		{`{"a": 1, "b": {"c": [1, 2, 3]}}`, `{"a": 2, "b": {"c": [1, 3], "d": true}}`, 3},
+		{`[1, 2, 3, 4, 5]`, `[0, 1, 2, 4, 5, 6]`, 3},
+		{`[{"name": "a", "ip": "1"}, {"name": "b"}]`, `[{"name": "a", "ip": "2"}, {"name": "b"}]`, 1},
+		{`["a", "b", "c"]`, `["c", "b", "a"]`, 2},
+		{`{"a": [1]}`, `{"a": {"b": 1}}`, 1},
+		{`[]`, `[1, [2], {"x": null}]`, 3},
+		{`{"a": 1}`, `[1]`, 1},
+		{`{"a": 1, "b": [2.0]}`, `{"a": 1.0, "b": [2]}`, 0},
+	}
+	for _, c := range cases {
+		from, _ := ParseString(c.from)
+		to, _ := ParseString(c.to)
+		patch := CreatePatch(from, to)
+		if patch.Length() != c.ops {
+			t.Errorf("%s -> %s: want %d operations, got %s", c.from, c.to, c.ops, patch)
+		}
+		got, err := ApplyPatch(from, patch)
+		if err != nil {
+			t.Errorf("%s -> %s: apply %s fail %s", c.from, c.to, patch, err)
+		} else if !jsonValueEquals(got, to) {
+			t.Errorf("%s -> %s: patch %s gives %s", c.from, c.to, patch, got)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package jsonutils
+
+/**
+jsonutils.GetPointer
+
+Address values in a JSONObject tree with JSON Pointer (RFC 6901), e.g.
+/servers/0/name.  Array elements are addressed by index, the special
+token - refers to the position after the last element, and ~1, ~0 escape
+/ and ~ in keys.
+
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseJSONPointer splits a JSON pointer into its unescaped reference tokens
+func ParseJSONPointer(ptr string) ([]string, error) {
+	if len(ptr) == 0 {
+		return []string{}, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("JSON pointer %q must be empty or start with /", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 >= len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("JSON pointer %q: invalid escape in segment %q", ptr, token)
+			}
+		}
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// JSONPointer builds a JSON pointer from unescaped reference tokens
+func JSONPointer(tokens ...string) string {
+	var buf strings.Builder
+	for _, token := range tokens {
+		buf.WriteByte('/')
+		buf.WriteString(strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1))
+	}
+	return buf.String()
+}
+
+func jsonTypeName(o JSONObject) string {
+	switch o.(type) {
+	case *JSONDict:
+		return "JSONDict"
+	case *JSONArray:
+		return "JSONArray"
+	case *JSONString:
+		return "JSONString"
+	case *JSONInt:
+		return "JSONInt"
+	case *JSONFloat:
+		return "JSONFloat"
+	case *JSONBool:
+		return "JSONBool"
+	}
+	return "JSONNull"
+}
+
+// pointerIndex resolves an array index token.  When allowEnd is set, the
+// token - and the index equal to the array length denote the end.
+func pointerIndex(arr *JSONArray, token string, allowEnd bool) (int, error) {
+	if token == "-" {
+		if allowEnd {
+			return len(arr.data), nil
+		}
+		return -1, fmt.Errorf("index - refers to a nonexistent element")
+	}
+	if len(token) == 0 || (len(token) > 1 && token[0] == '0') {
+		return -1, fmt.Errorf("invalid array index %q", token)
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return -1, fmt.Errorf("invalid array index %q", token)
+		}
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return -1, fmt.Errorf("invalid array index %q", token)
+	}
+	if idx > len(arr.data) || (idx == len(arr.data) && !allowEnd) {
+		return -1, fmt.Errorf("index %d out of range (length %d)", idx, len(arr.data))
+	}
+	return idx, nil
+}
+
+func pointerGet(obj JSONObject, tokens []string) (JSONObject, error) {
+	for i, token := range tokens {
+		switch v := obj.(type) {
+		case *JSONDict:
+			val, ok := v.data[token]
+			if !ok {
+				return nil, fmt.Errorf("no such key %q at %s", token, JSONPointer(tokens[:i+1]...))
+			}
+			obj = val
+		case *JSONArray:
+			idx, err := pointerIndex(v, token, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s at %s", err, JSONPointer(tokens[:i+1]...))
+			}
+			obj = v.data[idx]
+		default:
+			return nil, fmt.Errorf("%s is a %s, cannot descend into %q", pointerLocation(tokens[:i]), jsonTypeName(obj), token)
+		}
+	}
+	return obj, nil
+}
+
+func pointerLocation(tokens []string) string {
+	if len(tokens) == 0 {
+		return "root"
+	}
+	return JSONPointer(tokens...)
+}
+
+func pointerGetString(obj JSONObject, ptr string) (JSONObject, error) {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	val, err := pointerGet(obj, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	return val, nil
+}
+
+// pointerParent returns the container holding the value addressed by
+// tokens, which must not be empty
+func pointerParent(obj JSONObject, tokens []string) (JSONObject, error) {
+	parent, err := pointerGet(obj, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	switch parent.(type) {
+	case *JSONDict, *JSONArray:
+		return parent, nil
+	}
+	return nil, fmt.Errorf("%s is a %s, cannot descend into %q", pointerLocation(tokens[:len(tokens)-1]), jsonTypeName(parent), tokens[len(tokens)-1])
+}
+
+// pointerSet stores val at tokens.  Array elements are replaced, unless
+// insert is set or the index is - or the array length, in which case val is
+// inserted.
+func pointerSet(obj JSONObject, tokens []string, val JSONObject, insert bool) error {
+	if len(tokens) == 0 {
+		return fmt.Errorf("cannot replace the root")
+	}
+	parent, err := pointerParent(obj, tokens)
+	if err != nil {
+		return err
+	}
+	token := tokens[len(tokens)-1]
+	switch v := parent.(type) {
+	case *JSONDict:
+		v.Set(token, val)
+	case *JSONArray:
+		idx, err := pointerIndex(v, token, true)
+		if err != nil {
+			return fmt.Errorf("%s at %s", err, JSONPointer(tokens...))
+		}
+		if insert || idx == len(v.data) {
+			v.data = append(v.data, nil)
+			copy(v.data[idx+1:], v.data[idx:])
+		}
+		v.data[idx] = val
+	}
+	return nil
+}
+
+func pointerRemove(obj JSONObject, tokens []string) (JSONObject, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the root")
+	}
+	parent, err := pointerParent(obj, tokens)
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[len(tokens)-1]
+	switch v := parent.(type) {
+	case *JSONDict:
+		val, ok := v.data[token]
+		if !ok {
+			return nil, fmt.Errorf("no such key %q at %s", token, JSONPointer(tokens...))
+		}
+		v.Remove(token)
+		return val, nil
+	case *JSONArray:
+		idx, err := pointerIndex(v, token, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s at %s", err, JSONPointer(tokens...))
+		}
+		val := v.data[idx]
+		v.data = append(v.data[:idx], v.data[idx+1:]...)
+		return val, nil
+	}
+	return nil, nil
+}
+
+func pointerSetString(obj JSONObject, ptr string, val JSONObject) error {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return err
+	}
+	err = pointerSet(obj, tokens, val, false)
+	if err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	return nil
+}
+
+func pointerRemoveString(obj JSONObject, ptr string) error {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return err
+	}
+	_, err = pointerRemove(obj, tokens)
+	if err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	return nil
+}
+
+// GetPointer returns the value addressed by the JSON pointer ptr
+func (this *JSONDict) GetPointer(ptr string) (JSONObject, error) {
+	return pointerGetString(this, ptr)
+}
+
+func (this *JSONArray) GetPointer(ptr string) (JSONObject, error) {
+	return pointerGetString(this, ptr)
+}
+
+// SetPointer stores val at the JSON pointer ptr.  The parent of the
+// addressed location must exist.  Existing array elements are replaced, and
+// the index - appends to an array.
+func (this *JSONDict) SetPointer(ptr string, val JSONObject) error {
+	return pointerSetString(this, ptr, val)
+}
+
+func (this *JSONArray) SetPointer(ptr string, val JSONObject) error {
+	return pointerSetString(this, ptr, val)
+}
+
+// RemovePointer removes the value addressed by the JSON pointer ptr
+func (this *JSONDict) RemovePointer(ptr string) error {
+	return pointerRemoveString(this, ptr)
+}
+
+func (this *JSONArray) RemovePointer(ptr string) error {
+	return pointerRemoveString(this, ptr)
+}
@@ -0,0 +1,131 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONPointerParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{}},
+		{"/", []string{""}},
+		{"/a~1b/m~0n/0", []string{"a/b", "m~n", "0"}},
+		{"/~01", []string{"~1"}},
+	}
+	for _, c := range cases {
+		got, err := ParseJSONPointer(c.in)
+		if err != nil {
+			t.Errorf("%s: %s", c.in, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(c.want, "|") || len(got) != len(c.want) {
+			t.Errorf("%s: want %q, got %q", c.in, c.want, got)
+		}
+		if JSONPointer(got...) != c.in {
+			t.Errorf("JSONPointer(%q) = %s, want %s", got, JSONPointer(got...), c.in)
+		}
+	}
+	for _, in := range []string{"a", "/a~2", "/a~"} {
+		if _, err := ParseJSONPointer(in); err == nil {
+			t.Errorf("%s should fail", in)
+		}
+	}
+}
+
+func TestJSONPointerGet(t *testing.T) {
+	json, _ := ParseString(`{"servers": [{"name": "a", "nics": [{"mac": "m0"}]}, {"name": "b"}], "a/b": 1, "m~n": 2, "": 3}`)
+	dict := json.(*JSONDict)
+	cases := []struct {
+		ptr  string
+		want string
+	}{
+		{"/servers/0/name", `"a"`},
+		{"/servers/1/name", `"b"`},
+		{"/servers/0/nics/0/mac", `"m0"`},
+		{"/a~1b", "1"},
+		{"/m~0n", "2"},
+		{"/", "3"},
+	}
+	for _, c := range cases {
+		got, err := dict.GetPointer(c.ptr)
+		if err != nil {
+			t.Errorf("%s: %s", c.ptr, err)
+		} else if got.String() != c.want {
+			t.Errorf("%s: want %s, got %s", c.ptr, c.want, got)
+		}
+	}
+	if got, _ := dict.GetPointer(""); got != dict {
+		t.Errorf("empty pointer should return the document")
+	}
+	errCases := []struct {
+		ptr string
+		msg string
+	}{
+		{"/servers/2/name", "index 2 out of range (length 2) at /servers/2"},
+		{"/servers/01", `invalid array index "01" at /servers/01`},
+		{"/servers/-", "index - refers to a nonexistent element at /servers/-"},
+		{"/servers/0/name/x", `/servers/0/name is a JSONString, cannot descend into "x"`},
+		{"/server", `no such key "server" at /server`},
+	}
+	for _, c := range errCases {
+		_, err := dict.GetPointer(c.ptr)
+		if err == nil {
+			t.Errorf("%s should fail", c.ptr)
+		} else if !strings.HasSuffix(err.Error(), c.msg) {
+			t.Errorf("%s: want error %q, got %q", c.ptr, c.msg, err)
+		}
+	}
+}
+
+func TestJSONPointerSetRemove(t *testing.T) {
+	json, _ := ParseString(`{"servers": [{"name": "a"}, {"name": "b"}]}`)
+	dict := json.(*JSONDict)
+	steps := []struct {
+		op   string
+		ptr  string
+		val  JSONObject
+		want string
+	}{
+		{"set", "/servers/0/name", NewString("x"), `{"servers":[{"name":"x"},{"name":"b"}]}`},
+		{"set", "/servers/-", NewString("c"), `{"servers":[{"name":"x"},{"name":"b"},"c"]}`},
+		{"set", "/servers/3", NewInt(4), `{"servers":[{"name":"x"},{"name":"b"},"c",4]}`},
+		{"set", "/count", NewInt(4), `{"count":4,"servers":[{"name":"x"},{"name":"b"},"c",4]}`},
+		{"remove", "/servers/2", nil, `{"count":4,"servers":[{"name":"x"},{"name":"b"},4]}`},
+		{"remove", "/servers/0/name", nil, `{"count":4,"servers":[{},{"name":"b"},4]}`},
+		{"remove", "/count", nil, `{"servers":[{},{"name":"b"},4]}`},
+	}
+	for _, s := range steps {
+		var err error
+		if s.op == "set" {
+			err = dict.SetPointer(s.ptr, s.val)
+		} else {
+			err = dict.RemovePointer(s.ptr)
+		}
+		if err != nil {
+			t.Fatalf("%s %s: %s", s.op, s.ptr, err)
+		}
+		if dict.String() != s.want {
+			t.Fatalf("%s %s: want %s, got %s", s.op, s.ptr, s.want, dict)
+		}
+	}
+	for _, ptr := range []string{"", "/servers/5", "/missing/x", "/servers/2/x"} {
+		if err := dict.SetPointer(ptr, JSONNull); err == nil {
+			t.Errorf("set %s should fail", ptr)
+		}
+	}
+	for _, ptr := range []string{"", "/servers/3", "/missing", "/servers/-"} {
+		if err := dict.RemovePointer(ptr); err == nil {
+			t.Errorf("remove %s should fail", ptr)
+		}
+	}
+	arr := NewArray(NewInt(1))
+	if err := arr.SetPointer("/0", NewInt(2)); err != nil || arr.String() != "[2]" {
+		t.Errorf("array set: %s %s", arr, err)
+	}
+	if err := arr.RemovePointer("/0"); err != nil || arr.String() != "[]" {
+		t.Errorf("array remove: %s %s", arr, err)
+	}
+}
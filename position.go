@@ -0,0 +1,151 @@
+package jsonutils
+
+/**
+jsonutils.ParseWithPositions, jsonutils.ParseYAMLWithPositions
+
+Parse and record the source position of every value, to point at the
+place of a value when validating a file:
+
+	obj, positions, err := jsonutils.ParseYAMLWithPositions(yaml, nil)
+	...
+	if pos, ok := positions.PositionAt("/spec/replicas"); ok {
+		return fmt.Errorf("config.yaml:%s: replicas must be positive", pos)
+	}
+
+A position is the byte offset of a value in the source text, with its
+1-based line and byte column.  The values copied by YAML aliases and merge
+keys are at the position of the value they are copied from.
+
+PositionOf looks up a value itself.  Every null, true and false is the
+same JSONNull, JSONTrue or JSONFalse object, so their positions are only
+found by their path with PositionAt.
+
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Position is the place of a value in the source text
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// String returns the line and column as line:column
+func (pos Position) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// JSONPositions are the source positions of the values of a parsed
+// JSONObject
+type JSONPositions struct {
+	lineStarts []int
+	nodes      map[JSONObject]int
+	paths      map[string]int
+	// path is the path of the value being parsed
+	path []string
+}
+
+func newJSONPositions(str string, start int) *JSONPositions {
+	positions := &JSONPositions{
+		lineStarts: []int{start},
+		nodes:      make(map[JSONObject]int),
+		paths:      make(map[string]int),
+	}
+	for i := start; i < len(str); i++ {
+		if str[i] == '\n' {
+			positions.lineStarts = append(positions.lineStarts, i+1)
+		}
+	}
+	return positions
+}
+
+func (this *JSONPositions) record(path []string, node JSONObject, offset int) {
+	this.paths[JSONPointer(path...)] = offset
+	switch node {
+	case JSONNull, JSONTrue, JSONFalse:
+		return
+	}
+	if _, ok := this.nodes[node]; !ok {
+		this.nodes[node] = offset
+	}
+}
+
+func (this *JSONPositions) position(offset int) Position {
+	line := sort.Search(len(this.lineStarts), func(i int) bool { return this.lineStarts[i] > offset })
+	return Position{Offset: offset, Line: line, Column: offset - this.lineStarts[line-1] + 1}
+}
+
+// PositionOf returns the position of a value of the parsed JSONObject
+func (this *JSONPositions) PositionOf(node JSONObject) (Position, bool) {
+	offset, ok := this.nodes[node]
+	if !ok {
+		return Position{}, false
+	}
+	return this.position(offset), true
+}
+
+// PositionAt returns the position of the value at a JSON pointer, like
+// /spec/containers/0/image
+func (this *JSONPositions) PositionAt(ptr string) (Position, bool) {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return Position{}, false
+	}
+	offset, ok := this.paths[JSONPointer(tokens...)]
+	if !ok {
+		return Position{}, false
+	}
+	return this.position(offset), true
+}
+
+// ParseWithPositions is Parse recording the positions of the values
+func ParseWithPositions(str []byte) (JSONObject, *JSONPositions, error) {
+	positions := newJSONPositions(string(str), 0)
+	obj, err := parse(str, false, positions)
+	if err != nil {
+		return nil, nil, err
+	}
+	positions.path = nil
+	return obj, positions, nil
+}
+
+// ParseYAMLWithPositions is ParseYAMLWithOptions recording the positions of
+// the values.  opts may be nil for the default options.
+func ParseYAMLWithPositions(str string, opts *YAMLParseOptions) (JSONObject, *JSONPositions, error) {
+	p, docs, err := parseYAMLStream(str)
+	if err != nil {
+		return nil, nil, err
+	}
+	var start int
+	if strings.HasPrefix(str, "\ufeff") {
+		start = len("\ufeff")
+	}
+	positions := newJSONPositions(str, start)
+	if len(docs) == 0 {
+		return JSONNull, positions, nil
+	} else if len(docs) > 1 {
+		return nil, nil, p.errorf(docs[1].start, "expected a single document, use ParseYAMLDocuments for streams")
+	}
+	root := docs[0].root
+	obj, err := newYAMLConverter(p, opts).convert(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	record := func(path []string, node *yamlNode) {
+		// the parser reads the text without a BOM and with \r\n line
+		// breaks as \n, which leaves the lines and columns as they are
+		line, col := p.position(node.start)
+		val, _ := pointerGet(obj, path)
+		positions.record(path, val, positions.lineStarts[line-1]+col-1)
+	}
+	record(nil, root)
+	newYAMLPathWalker(func(path []string, key, val *yamlNode) {
+		record(path, val)
+	}).walk(root, nil, false)
+	return obj, positions, nil
+}
@@ -0,0 +1,101 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWithPositions(t *testing.T) {
+	json := "{\"a\": [1, {\"b\": null}],\n  \"c\": \"x\"}"
+	obj, positions, err := ParseWithPositions([]byte(json))
+	if err != nil {
+		t.Fatalf("ParseWithPositions: %v", err)
+	}
+	cases := []struct {
+		ptr  string
+		want Position
+	}{
+		{"", Position{Offset: 0, Line: 1, Column: 1}},
+		{"/a", Position{Offset: 6, Line: 1, Column: 7}},
+		{"/a/0", Position{Offset: 7, Line: 1, Column: 8}},
+		{"/a/1/b", Position{Offset: 16, Line: 1, Column: 17}},
+		{"/c", Position{Offset: 31, Line: 2, Column: 8}},
+	}
+	for _, c := range cases {
+		if got, ok := positions.PositionAt(c.ptr); !ok || got != c.want {
+			t.Errorf("PositionAt(%q) = %v, %v, want %v", c.ptr, got, ok, c.want)
+		}
+	}
+	if _, ok := positions.PositionAt("/d"); ok {
+		t.Errorf("PositionAt(/d) found")
+	}
+	a, _ := obj.Get("a")
+	if got, ok := positions.PositionOf(a); !ok || got.String() != "1:7" {
+		t.Errorf("PositionOf(a) = %v, %v", got, ok)
+	}
+	if _, ok := positions.PositionOf(JSONNull); ok {
+		t.Errorf("PositionOf(JSONNull) found")
+	}
+	if _, _, err := ParseWithPositions([]byte(`{"a": 1`)); err == nil {
+		t.Errorf("want error")
+	}
+}
+
+func TestParseYAMLWithPositions(t *testing.T) {
+	yaml := `# config
+base: &base
+  port: 80
+  tls: true
+web:
+  <<: *base
+  name: web
+  ports:
+    - 80
+    - *base
+`
+	cases := []struct {
+		name string
+		yaml string
+		ptr  string
+		want string
+		text string
+	}{
+		{"anchored mapping", yaml, "/base", "2:7", "&base"},
+		{"scalar", yaml, "/web/name", "7:9", "web"},
+		{"merged", yaml, "/web/port", "3:9", "80"},
+		{"merged bool", yaml, "/web/tls", "4:8", "true"},
+		{"sequence item", yaml, "/web/ports/0", "9:7", "80"},
+		{"alias", yaml, "/web/ports/1", "10:7", "*base"},
+		{"alias content", yaml, "/web/ports/1/port", "3:9", "80"},
+		{"crlf", "a: 1\r\nb:\r\n  c: 2\r\n", "/b/c", "3:6", "2\r\n"},
+		{"bom", "\ufeffa: [1, 2]\n", "/a/1", "1:8", "2]"},
+		{"flow", "{a: {b: x}, c: [y]}", "/c/0", "1:17", "y]"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			obj, positions, err := ParseYAMLWithPositions(c.yaml, nil)
+			if err != nil {
+				t.Fatalf("ParseYAMLWithPositions: %v", err)
+			}
+			got, ok := positions.PositionAt(c.ptr)
+			if !ok || got.String() != c.want {
+				t.Fatalf("PositionAt(%q) = %v, %v, want %s", c.ptr, got, ok, c.want)
+			}
+			if !strings.HasPrefix(c.yaml[got.Offset:], c.text) {
+				t.Errorf("offset %d is at %q, want %q", got.Offset, c.yaml[got.Offset:], c.text)
+			}
+			val, _ := pointerGet(obj, mustParsePointer(t, c.ptr))
+			if pos, ok := positions.PositionOf(val); ok && pos != got {
+				t.Errorf("PositionOf = %v, want %v", pos, got)
+			}
+		})
+	}
+}
+
+func mustParsePointer(t *testing.T, ptr string) []string {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		t.Fatalf("ParseJSONPointer(%q): %v", ptr, err)
+	}
+	return tokens
+}
@@ -82,7 +82,7 @@ func (this *JSONArray) _queryString(key string) string {
 
 func (this *JSONDict) _queryString(key string) string {
 	rets := make([]string, 0)
-	for _, k := range this.SortedKeys() {
+	for _, k := range this.Keys() {
 		v := this.data[k]
 		if len(key) > 0 {
 			k = key + "." + k
@@ -0,0 +1 @@
+package schema // import "yunion.io/x/jsonutils/schema"
@@ -0,0 +1,666 @@
+package schema
+
+/**
+schema.Compile
+
+Compile a JSON Schema, itself given as a JSONObject, and validate
+JSONObject values against it.
+
+Supported keywords:
+
+	type, enum, const
+	properties, required, additionalProperties, minProperties, maxProperties
+	items, minItems, maxItems, uniqueItems
+	pattern, minLength, maxLength
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+	allOf, anyOf, oneOf, not
+	$ref to a location within the same document, e.g. #/definitions/server
+
+Other keywords, e.g. format and description, are ignored.
+
+*/
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"yunion.io/x/jsonutils"
+)
+
+type Schema struct {
+	root *schemaNode
+}
+
+type schemaNode struct {
+	// the false schema, which rejects everything
+	reject bool
+
+	types []string
+	enum  []jsonutils.JSONObject
+	konst jsonutils.JSONObject
+
+	properties           map[string]*schemaNode
+	required             []string
+	additionalProperties *schemaNode
+	minProperties        int
+	maxProperties        int
+
+	items       *schemaNode
+	tupleItems  []*schemaNode
+	minItems    int
+	maxItems    int
+	uniqueItems bool
+
+	pattern   *regexp.Regexp
+	minLength int
+	maxLength int
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+
+	ref *schemaNode
+}
+
+type schemaCompiler struct {
+	doc  jsonutils.JSONObject
+	refs map[string]*schemaNode
+}
+
+var schemaTypes = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// Compile compiles schema for later validation.  Errors are reported with
+// the JSON pointer of the offending keyword in schema.
+func Compile(schema jsonutils.JSONObject) (*Schema, error) {
+	compiler := &schemaCompiler{
+		doc:  schema,
+		refs: make(map[string]*schemaNode),
+	}
+	root, err := compiler.compileRef("#")
+	if err != nil {
+		return nil, err
+	}
+	err = compiler.checkCycles()
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// CompileString parses and compiles a JSON Schema document
+func CompileString(schema string) (*Schema, error) {
+	json, err := jsonutils.ParseString(schema)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(json)
+}
+
+func (c *schemaCompiler) compileRef(ref string) (*schemaNode, error) {
+	if node, ok := c.refs[ref]; ok {
+		return node, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q: only references within the document are supported", ref)
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %s", ref, err)
+	}
+	json, err := resolvePointer(c.doc, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %s", ref, err)
+	}
+	// register before compiling so that recursive references terminate
+	node := newSchemaNode()
+	c.refs[ref] = node
+	err = c.compile(json, pointer, node)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// checkCycles rejects the $refs leading back to their schema only through
+// $ref, allOf, anyOf, oneOf and not, which apply to the same value, so that
+// validating them would never end
+func (c *schemaCompiler) checkCycles() error {
+	refs := make([]string, 0, len(c.refs))
+	names := make(map[*schemaNode]string, len(c.refs))
+	for ref, node := range c.refs {
+		refs = append(refs, ref)
+		names[node] = ref
+	}
+	sort.Strings(refs)
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*schemaNode]int)
+	var visit func(node *schemaNode) error
+	visit = func(node *schemaNode) error {
+		switch state[node] {
+		case visiting:
+			// only a $ref leads back to a node being visited
+			return fmt.Errorf("invalid $ref %q: circular reference without a nested value in between", names[node])
+		case visited:
+			return nil
+		}
+		state[node] = visiting
+		subs := make([]*schemaNode, 0)
+		if node.ref != nil {
+			subs = append(subs, node.ref)
+		}
+		subs = append(subs, node.allOf...)
+		subs = append(subs, node.anyOf...)
+		subs = append(subs, node.oneOf...)
+		if node.not != nil {
+			subs = append(subs, node.not)
+		}
+		for _, sub := range subs {
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		state[node] = visited
+		return nil
+	}
+	for _, ref := range refs {
+		if err := visit(c.refs[ref]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func resolvePointer(doc jsonutils.JSONObject, pointer string) (jsonutils.JSONObject, error) {
+	if len(pointer) == 0 {
+		return doc, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+	cur := doc
+	segs := strings.Split(pointer[1:], "/")
+	for i, seg := range segs {
+		seg = strings.Replace(strings.Replace(seg, "~1", "/", -1), "~0", "~", -1)
+		var next jsonutils.JSONObject
+		switch v := cur.(type) {
+		case *jsonutils.JSONDict:
+			next, _ = v.Get(seg)
+		case *jsonutils.JSONArray:
+			if idx, err := strconv.Atoi(seg); err == nil && idx >= 0 {
+				next, _ = v.GetAt(idx)
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("no such location /%s", strings.Join(segs[:i+1], "/"))
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+func newSchemaNode() *schemaNode {
+	return &schemaNode{
+		maxProperties: -1,
+		maxItems:      -1,
+		maxLength:     -1,
+	}
+}
+
+func (c *schemaCompiler) compileSub(json jsonutils.JSONObject, pointer string) (*schemaNode, error) {
+	node := newSchemaNode()
+	err := c.compile(json, pointer, node)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (c *schemaCompiler) compileList(json jsonutils.JSONObject, pointer string) ([]*schemaNode, error) {
+	arr, ok := json.(*jsonutils.JSONArray)
+	if !ok || arr.Length() == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array of schemas", pointer)
+	}
+	nodes := make([]*schemaNode, 0, arr.Length())
+	for i, sub := range arr.Value() {
+		node, err := c.compileSub(sub, fmt.Sprintf("%s/%d", pointer, i))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func getNumber(json jsonutils.JSONObject) (float64, bool) {
+	switch v := json.(type) {
+	case *jsonutils.JSONInt:
+		return float64(v.Value()), true
+	case *jsonutils.JSONFloat:
+		return v.Value(), true
+	}
+	return 0, false
+}
+
+func (c *schemaCompiler) compile(json jsonutils.JSONObject, pointer string, node *schemaNode) error {
+	if b, ok := json.(*jsonutils.JSONBool); ok {
+		node.reject = !b.Value()
+		return nil
+	}
+	dict, ok := json.(*jsonutils.JSONDict)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or a boolean", pointer)
+	}
+	for _, key := range dict.SortedKeys() {
+		val, _ := dict.Get(key)
+		kp := pointer + "/" + escapeToken(key)
+		var err error
+		switch key {
+		case "$ref":
+			ref, _ := val.GetString()
+			node.ref, err = c.compileRef(ref)
+		case "type":
+			switch v := val.(type) {
+			case *jsonutils.JSONString:
+				node.types = []string{v.Value()}
+			case *jsonutils.JSONArray:
+				node.types = v.GetStringArray()
+			default:
+				return fmt.Errorf("%s: must be a string or an array", kp)
+			}
+			for _, tp := range node.types {
+				if !inStrings(tp, schemaTypes) {
+					return fmt.Errorf("%s: unknown type %q", kp, tp)
+				}
+			}
+		case "enum":
+			arr, ok := val.(*jsonutils.JSONArray)
+			if !ok {
+				return fmt.Errorf("%s: must be an array", kp)
+			}
+			node.enum = arr.Value()
+		case "const":
+			node.konst = val
+		case "properties":
+			props, ok := val.(*jsonutils.JSONDict)
+			if !ok {
+				return fmt.Errorf("%s: must be an object", kp)
+			}
+			node.properties = make(map[string]*schemaNode)
+			for _, prop := range props.SortedKeys() {
+				sub, _ := props.Get(prop)
+				node.properties[prop], err = c.compileSub(sub, kp+"/"+escapeToken(prop))
+				if err != nil {
+					return err
+				}
+			}
+		case "required":
+			arr, ok := val.(*jsonutils.JSONArray)
+			if !ok {
+				return fmt.Errorf("%s: must be an array", kp)
+			}
+			node.required = arr.GetStringArray()
+		case "additionalProperties":
+			node.additionalProperties, err = c.compileSub(val, kp)
+		case "items":
+			if arr, ok := val.(*jsonutils.JSONArray); ok {
+				node.tupleItems, err = c.compileList(arr, kp)
+			} else {
+				node.items, err = c.compileSub(val, kp)
+			}
+		case "allOf":
+			node.allOf, err = c.compileList(val, kp)
+		case "anyOf":
+			node.anyOf, err = c.compileList(val, kp)
+		case "oneOf":
+			node.oneOf, err = c.compileList(val, kp)
+		case "not":
+			node.not, err = c.compileSub(val, kp)
+		case "pattern":
+			str, _ := val.GetString()
+			node.pattern, err = regexp.Compile(str)
+		case "uniqueItems":
+			node.uniqueItems, err = val.Bool()
+		case "minProperties", "maxProperties", "minItems", "maxItems", "minLength", "maxLength":
+			num, ok := getNumber(val)
+			if !ok || num < 0 || num != math.Trunc(num) {
+				return fmt.Errorf("%s: must be a non-negative integer", kp)
+			}
+			switch key {
+			case "minProperties":
+				node.minProperties = int(num)
+			case "maxProperties":
+				node.maxProperties = int(num)
+			case "minItems":
+				node.minItems = int(num)
+			case "maxItems":
+				node.maxItems = int(num)
+			case "minLength":
+				node.minLength = int(num)
+			case "maxLength":
+				node.maxLength = int(num)
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			num, ok := getNumber(val)
+			if !ok {
+				if _, isBool := val.(*jsonutils.JSONBool); isBool && strings.HasPrefix(key, "exclusive") {
+					// draft-04 boolean form is resolved below
+					continue
+				}
+				return fmt.Errorf("%s: must be a number", kp)
+			}
+			switch key {
+			case "minimum":
+				node.minimum = &num
+			case "maximum":
+				node.maximum = &num
+			case "exclusiveMinimum":
+				node.exclusiveMinimum = &num
+			case "exclusiveMaximum":
+				node.exclusiveMaximum = &num
+			case "multipleOf":
+				if num <= 0 {
+					return fmt.Errorf("%s: must be greater than 0", kp)
+				}
+				node.multipleOf = &num
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", kp, err)
+		}
+	}
+	// draft-04: "exclusiveMinimum": true turns minimum into an exclusive bound
+	if excl, _ := dict.Bool("exclusiveMinimum"); excl && node.minimum != nil {
+		node.exclusiveMinimum, node.minimum = node.minimum, nil
+	}
+	if excl, _ := dict.Bool("exclusiveMaximum"); excl && node.maximum != nil {
+		node.exclusiveMaximum, node.maximum = node.maximum, nil
+	}
+	return nil
+}
+
+func inStrings(str string, strs []string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks json against the schema, a nil json as null.  It returns
+// nil, or jsonutils.ValidationErrors listing every violation with its path
+func (s *Schema) Validate(json jsonutils.JSONObject) error {
+	if json == nil {
+		json = jsonutils.JSONNull
+	}
+	errs := jsonutils.ValidationErrors{}
+	s.root.validate(json, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *Schema) IsValid(json jsonutils.JSONObject) bool {
+	return s.Validate(json) == nil
+}
+
+func propPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func typeOf(json jsonutils.JSONObject) string {
+	switch v := json.(type) {
+	case *jsonutils.JSONDict:
+		return "object"
+	case *jsonutils.JSONArray:
+		return "array"
+	case *jsonutils.JSONString:
+		return "string"
+	case *jsonutils.JSONInt:
+		return "integer"
+	case *jsonutils.JSONFloat:
+		if v.Value() == math.Trunc(v.Value()) {
+			return "integer"
+		}
+		return "number"
+	case *jsonutils.JSONBool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func matchType(json jsonutils.JSONObject, types []string) bool {
+	tp := typeOf(json)
+	for _, t := range types {
+		if t == tp || (t == "number" && tp == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two values per JSON Schema, where numbers are equal
+// by value regardless of JSONInt or JSONFloat
+func jsonEqual(a, b jsonutils.JSONObject) bool {
+	na, okA := getNumber(a)
+	nb, okB := getNumber(b)
+	if okA || okB {
+		return okA && okB && na == nb
+	}
+	switch va := a.(type) {
+	case *jsonutils.JSONArray:
+		vb, ok := b.(*jsonutils.JSONArray)
+		if !ok || va.Length() != vb.Length() {
+			return false
+		}
+		arrB := vb.Value()
+		for i, elem := range va.Value() {
+			if !jsonEqual(elem, arrB[i]) {
+				return false
+			}
+		}
+		return true
+	case *jsonutils.JSONDict:
+		vb, ok := b.(*jsonutils.JSONDict)
+		if !ok || va.Length() != vb.Length() {
+			return false
+		}
+		mapB := vb.Value()
+		for k, elem := range va.Value() {
+			elemB, ok := mapB[k]
+			if !ok || !jsonEqual(elem, elemB) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equals(b)
+}
+
+func (node *schemaNode) validate(json jsonutils.JSONObject, path string, errs *jsonutils.ValidationErrors) {
+	addError := func(keyword string, msg string, params ...interface{}) {
+		*errs = append(*errs, &jsonutils.ValidationError{
+			Path: path,
+			Rule: keyword,
+			Msg:  fmt.Sprintf(msg, params...),
+		})
+	}
+	if node.reject {
+		addError("false", "no value is allowed")
+		return
+	}
+	if node.ref != nil {
+		node.ref.validate(json, path, errs)
+	}
+	if len(node.types) > 0 && !matchType(json, node.types) {
+		addError("type", "expect %s, got %s", strings.Join(node.types, " or "), typeOf(json))
+		return
+	}
+	if node.enum != nil {
+		found := false
+		for _, e := range node.enum {
+			if jsonEqual(json, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addError("enum", "%s is not one of the allowed values", json)
+		}
+	}
+	if node.konst != nil && !jsonEqual(json, node.konst) {
+		addError("const", "expect %s, got %s", node.konst, json)
+	}
+
+	switch v := json.(type) {
+	case *jsonutils.JSONDict:
+		node.validateObject(v, path, addError, errs)
+	case *jsonutils.JSONArray:
+		node.validateArray(v, path, addError, errs)
+	case *jsonutils.JSONString:
+		str := v.Value()
+		length := utf8.RuneCountInString(str)
+		if length < node.minLength {
+			addError("minLength", "length %d is less than %d", length, node.minLength)
+		}
+		if node.maxLength >= 0 && length > node.maxLength {
+			addError("maxLength", "length %d is greater than %d", length, node.maxLength)
+		}
+		if node.pattern != nil && !node.pattern.MatchString(str) {
+			addError("pattern", "%q does not match pattern %s", str, node.pattern)
+		}
+	case *jsonutils.JSONInt, *jsonutils.JSONFloat:
+		num, _ := getNumber(v)
+		if node.minimum != nil && num < *node.minimum {
+			addError("minimum", "%v is less than %v", num, *node.minimum)
+		}
+		if node.maximum != nil && num > *node.maximum {
+			addError("maximum", "%v is greater than %v", num, *node.maximum)
+		}
+		if node.exclusiveMinimum != nil && num <= *node.exclusiveMinimum {
+			addError("exclusiveMinimum", "%v is not greater than %v", num, *node.exclusiveMinimum)
+		}
+		if node.exclusiveMaximum != nil && num >= *node.exclusiveMaximum {
+			addError("exclusiveMaximum", "%v is not less than %v", num, *node.exclusiveMaximum)
+		}
+		if node.multipleOf != nil {
+			q := num / *node.multipleOf
+			if math.Abs(q-math.Round(q)) > 1e-9 {
+				addError("multipleOf", "%v is not a multiple of %v", num, *node.multipleOf)
+			}
+		}
+	}
+
+	for _, sub := range node.allOf {
+		sub.validate(json, path, errs)
+	}
+	if len(node.anyOf) > 0 {
+		matched := false
+		for _, sub := range node.anyOf {
+			if sub.matches(json, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			addError("anyOf", "does not match any of the schemas")
+		}
+	}
+	if len(node.oneOf) > 0 {
+		matched := 0
+		for _, sub := range node.oneOf {
+			if sub.matches(json, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			addError("oneOf", "matches %d of the schemas, expect exactly 1", matched)
+		}
+	}
+	if node.not != nil && node.not.matches(json, path) {
+		addError("not", "must not match the schema")
+	}
+}
+
+func (node *schemaNode) matches(json jsonutils.JSONObject, path string) bool {
+	errs := jsonutils.ValidationErrors{}
+	node.validate(json, path, &errs)
+	return len(errs) == 0
+}
+
+func (node *schemaNode) validateObject(dict *jsonutils.JSONDict, path string, addError func(string, string, ...interface{}), errs *jsonutils.ValidationErrors) {
+	if dict.Length() < node.minProperties {
+		addError("minProperties", "has %d properties, expect at least %d", dict.Length(), node.minProperties)
+	}
+	if node.maxProperties >= 0 && dict.Length() > node.maxProperties {
+		addError("maxProperties", "has %d properties, expect at most %d", dict.Length(), node.maxProperties)
+	}
+	for _, key := range node.required {
+		if !dict.Contains(key) {
+			addError("required", "missing required property %s", key)
+		}
+	}
+	for _, key := range dict.SortedKeys() {
+		val, _ := dict.Get(key)
+		if prop, ok := node.properties[key]; ok {
+			prop.validate(val, propPath(path, key), errs)
+		} else if node.additionalProperties != nil {
+			if node.additionalProperties.reject {
+				addError("additionalProperties", "property %s is not allowed", key)
+			} else {
+				node.additionalProperties.validate(val, propPath(path, key), errs)
+			}
+		}
+	}
+}
+
+func (node *schemaNode) validateArray(arr *jsonutils.JSONArray, path string, addError func(string, string, ...interface{}), errs *jsonutils.ValidationErrors) {
+	elems := arr.Value()
+	if len(elems) < node.minItems {
+		addError("minItems", "has %d items, expect at least %d", len(elems), node.minItems)
+	}
+	if node.maxItems >= 0 && len(elems) > node.maxItems {
+		addError("maxItems", "has %d items, expect at most %d", len(elems), node.maxItems)
+	}
+	for i, elem := range elems {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		if node.items != nil {
+			node.items.validate(elem, elemPath, errs)
+		} else if i < len(node.tupleItems) {
+			node.tupleItems[i].validate(elem, elemPath, errs)
+		}
+	}
+	if node.uniqueItems {
+		for i := 0; i < len(elems); i++ {
+			for j := i + 1; j < len(elems); j++ {
+				if jsonEqual(elems[i], elems[j]) {
+					addError("uniqueItems", "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
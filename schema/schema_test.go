@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"sort"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+const serverSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"definitions": {
+		"nic": {
+			"type": "object",
+			"properties": {
+				"mac": {"type": "string", "pattern": "^([0-9a-f]{2}:){5}[0-9a-f]{2}$"},
+				"ip": {"type": "string"},
+				"bw": {"type": "integer", "minimum": 1, "maximum": 10000}
+			},
+			"required": ["mac"],
+			"additionalProperties": false
+		},
+		"tree": {
+			"type": "object",
+			"properties": {
+				"name": {"type": "string"},
+				"children": {"type": "array", "items": {"$ref": "#/definitions/tree"}}
+			}
+		}
+	},
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 8},
+		"status": {"enum": ["running", "ready"]},
+		"cpu": {"type": "integer", "exclusiveMinimum": 0, "multipleOf": 2},
+		"ratio": {"type": "number", "maximum": 1.5},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+		"nics": {"type": "array", "items": {"$ref": "#/definitions/nic"}, "minItems": 1},
+		"disk": {"oneOf": [{"type": "integer"}, {"type": "string", "pattern": "^[0-9]+[mg]$"}]},
+		"owner": {"anyOf": [{"type": "null"}, {"type": "string"}]},
+		"policy": {"allOf": [{"type": "string"}, {"not": {"const": "deny"}}]},
+		"tree": {"$ref": "#/definitions/tree"}
+	},
+	"required": ["name", "nics"]
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := CompileString(serverSchema)
+	if err != nil {
+		t.Fatalf("compile fail %s", err)
+	}
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "valid",
+			in: `{"name": "vm1", "status": "ready", "cpu": 4, "ratio": 1, "tags": ["a", "b"],
+				"nics": [{"mac": "00:22:33:44:55:66", "ip": "10.0.0.1", "bw": 100}],
+				"disk": "10g", "owner": null, "policy": "allow",
+				"tree": {"name": "a", "children": [{"name": "b", "children": []}]}}`,
+		},
+		{
+			name: "missing required",
+			in:   `{"cpu": 2}`,
+			want: []string{":required", ":required"},
+		},
+		{
+			name: "invalid values",
+			in: `{"name": "a-very-long-name", "status": "stopped", "cpu": 3, "ratio": 2.5, "tags": ["a", "a"],
+				"nics": [{"ip": "10.0.0.1", "bw": 0, "vlan": 1}, {"mac": "xx"}],
+				"disk": 10.5, "owner": 1, "policy": "deny",
+				"tree": {"children": [{"name": 1}]}}`,
+			want: []string{
+				"name:maxLength",
+				"status:enum",
+				"cpu:multipleOf",
+				"ratio:maximum",
+				"tags:uniqueItems",
+				"nics[0]:required",
+				"nics[0].bw:minimum",
+				"nics[0]:additionalProperties",
+				"nics[1].mac:pattern",
+				"disk:oneOf",
+				"owner:anyOf",
+				"policy:not",
+				"tree.children[0].name:type",
+			},
+		},
+		{
+			name: "wrong type",
+			in:   `[]`,
+			want: []string{":type"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			json, err := jsonutils.ParseString(c.in)
+			if err != nil {
+				t.Fatalf("parse %s", err)
+			}
+			err = s.Validate(json)
+			got := []string{}
+			if err != nil {
+				for _, e := range err.(jsonutils.ValidationErrors) {
+					got = append(got, e.Path+":"+e.Rule)
+				}
+			}
+			want := append([]string{}, c.want...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if len(got) != len(want) {
+				t.Fatalf("want %v, got %v (%v)", want, got, err)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("want %v, got %v (%v)", want, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaCompileError(t *testing.T) {
+	cases := []string{
+		`{"type": "integers"}`,
+		`{"properties": []}`,
+		`{"$ref": "#/definitions/missing"}`,
+		`{"$ref": "http://example.com/schema.json"}`,
+		`{"minLength": -1}`,
+		`{"pattern": "(["}`,
+		`{"anyOf": []}`,
+		`{"items": [1]}`,
+		`{"$ref": "#"}`,
+		`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"$ref": "#/definitions/a"}}, "$ref": "#/definitions/a"}`,
+		`{"definitions": {"a": {"type": "object", "allOf": [{"not": {"$ref": "#/definitions/a"}}]}}, "properties": {"x": {"$ref": "#/definitions/a"}}}`,
+	}
+	for _, c := range cases {
+		if _, err := CompileString(c); err == nil {
+			t.Errorf("%s should fail", c)
+		} else {
+			t.Logf("%s: %s", c, err)
+		}
+	}
+}
+
+func TestSchemaBoolean(t *testing.T) {
+	s, err := CompileString(`{"type": "array", "items": [{"type": "integer"}, true, false]}`)
+	if err != nil {
+		t.Fatalf("compile fail %s", err)
+	}
+	json, _ := jsonutils.ParseString(`[1, "any"]`)
+	if !s.IsValid(json) {
+		t.Errorf("%s should be valid: %s", json, s.Validate(json))
+	}
+	json, _ = jsonutils.ParseString(`[1.0, "any", "none"]`)
+	if err := s.Validate(json); err == nil {
+		t.Errorf("%s should be invalid", json)
+	} else if err.Error() != "[2]: no value is allowed" {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestSchemaValidateNil(t *testing.T) {
+	for _, c := range []struct {
+		schema string
+		valid  bool
+	}{
+		{`{"enum": [1, null]}`, true},
+		{`{"enum": [1, 2]}`, false},
+		{`{"const": null}`, true},
+		{`{"const": "x"}`, false},
+		{`{"type": "object"}`, false},
+	} {
+		s, err := CompileString(c.schema)
+		if err != nil {
+			t.Fatalf("compile %s fail %s", c.schema, err)
+		}
+		if got := s.IsValid(nil); got != c.valid {
+			t.Errorf("%s: nil is valid %v, want %v", c.schema, got, c.valid)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"yunion.io/x/pkg/tristate"
+
+	"yunion.io/x/jsonutils"
+)
+
+type sNode struct {
+	Name     string
+	Weight   float64
+	Children []*sNode
+}
+
+type sServer struct {
+	Name    string `json:"name,allowempty"`
+	Cpu     int
+	MemMb   uint32
+	Enabled tristate.TriState
+	Created time.Time
+	Tags    []string
+	Disks   map[string]int
+	Nics    []struct {
+		Mac string
+		Ip  *string
+	}
+	Meta  jsonutils.JSONObject
+	Tree  *sNode
+	Ports []int `json:"ports,string"`
+}
+
+func TestSchemaOfMarshal(t *testing.T) {
+	ip := "10.0.0.1"
+	servers := []sServer{
+		{},
+		{
+			Name:    "vm1",
+			Cpu:     2,
+			MemMb:   1024,
+			Enabled: tristate.True,
+			Created: time.Now(),
+			Tags:    []string{"a"},
+			Disks:   map[string]int{"root": 10},
+			Nics: []struct {
+				Mac string
+				Ip  *string
+			}{{Mac: "00:11:22:33:44:55", Ip: &ip}, {}},
+			Meta:  jsonutils.NewDict(),
+			Tree:  &sNode{Name: "root", Children: []*sNode{{Name: "leaf"}, nil}},
+			Ports: []int{80, 443},
+		},
+	}
+	s, err := Compile(jsonutils.SchemaOf(reflect.TypeOf(sServer{})))
+	if err != nil {
+		t.Fatalf("compile SchemaOf fail %s", err)
+	}
+	for _, server := range servers {
+		json := jsonutils.Marshal(server)
+		if err := s.Validate(json); err != nil {
+			t.Errorf("%s: %s", json, err)
+		}
+	}
+	invalid, _ := jsonutils.ParseString(`{"name": 1, "cpu": "2", "extra": true}`)
+	if s.IsValid(invalid) {
+		t.Errorf("%s should be invalid", invalid)
+	}
+}
+
+type sLabeled struct {
+	jsonutils.JSONObject
+	A int
+}
+
+func TestSchemaOfEmbeddedJSONObject(t *testing.T) {
+	s, err := Compile(jsonutils.SchemaOf(reflect.TypeOf(sLabeled{})))
+	if err != nil {
+		t.Fatalf("compile SchemaOf fail %s", err)
+	}
+	labels, _ := jsonutils.ParseString(`{"x": 1}`)
+	for _, obj := range []sLabeled{{A: 2}, {JSONObject: labels, A: 2}} {
+		json := jsonutils.Marshal(obj)
+		if err := s.Validate(json); err != nil {
+			t.Errorf("%s: %s", json, err)
+		}
+	}
+	if json := jsonutils.Marshal(sLabeled{JSONObject: labels}); !json.Contains("json_object") {
+		t.Errorf("%s has no json_object", json)
+	}
+	invalid, _ := jsonutils.ParseString(`{"a": 2, "extra": true}`)
+	if s.IsValid(invalid) {
+		t.Errorf("%s should be invalid", invalid)
+	}
+}
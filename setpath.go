@@ -0,0 +1,238 @@
+package jsonutils
+
+/**
+jsonutils.SetPath
+
+Store values at paths like servers[0].nics[-].ip, creating the missing
+dicts and arrays on the way:
+
+	name          key of a dict
+	["a.b"] ['a'] quoted key, for keys containing . [ ] or quotes
+	[2]           element of an array; arrays are padded with null up to
+	              the index, by at most 65536 elements, and negative
+	              indices count from the end
+	[-]           a new element appended to an array
+
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type sPathSegment struct {
+	key    string
+	index  int
+	array  bool
+	append bool
+}
+
+func (seg sPathSegment) String() string {
+	switch {
+	case seg.append:
+		return "[-]"
+	case seg.array:
+		return fmt.Sprintf("[%d]", seg.index)
+	case strings.ContainsAny(seg.key, ".[]\"'") || len(seg.key) == 0:
+		return "[" + strconv.Quote(seg.key) + "]"
+	}
+	return seg.key
+}
+
+func formatPathSegments(segs []sPathSegment) string {
+	var buf strings.Builder
+	for i, seg := range segs {
+		str := seg.String()
+		if i > 0 && str[0] != '[' {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(str)
+	}
+	return buf.String()
+}
+
+func parsePathSegments(path string) ([]sPathSegment, error) {
+	segs := make([]sPathSegment, 0)
+	i := 0
+	for i < len(path) {
+		if path[i] == '[' {
+			end := i + 1
+			var seg sPathSegment
+			if end < len(path) && (path[end] == '"' || path[end] == '\'') {
+				quote := path[end]
+				var buf strings.Builder
+				end++
+				for end < len(path) && path[end] != quote {
+					if path[end] == '\\' && end+1 < len(path) {
+						end++
+					}
+					buf.WriteByte(path[end])
+					end++
+				}
+				if end >= len(path) {
+					return nil, fmt.Errorf("path %q: unterminated quoted key", path)
+				}
+				seg.key = buf.String()
+				end++
+			} else {
+				start := end
+				for end < len(path) && path[end] != ']' {
+					end++
+				}
+				token := path[start:end]
+				if token == "-" {
+					seg.append = true
+				} else {
+					idx, err := strconv.Atoi(token)
+					if err != nil {
+						return nil, fmt.Errorf("path %q: invalid index %q", path, token)
+					}
+					seg.index = idx
+				}
+				seg.array = true
+			}
+			if end >= len(path) || path[end] != ']' {
+				return nil, fmt.Errorf("path %q: missing ] at offset %d", path, end)
+			}
+			i = end + 1
+			segs = append(segs, seg)
+		} else {
+			if path[i] == '.' {
+				if len(segs) == 0 {
+					return nil, fmt.Errorf("path %q: empty key at offset %d", path, i)
+				}
+				i++
+			} else if len(segs) > 0 {
+				return nil, fmt.Errorf("path %q: expect . or [ at offset %d", path, i)
+			}
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				if path[end] == ']' {
+					return nil, fmt.Errorf("path %q: unexpected ] at offset %d", path, end)
+				}
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("path %q: empty key at offset %d", path, i)
+			}
+			segs = append(segs, sPathSegment{key: path[i:end]})
+			i = end
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return segs, nil
+}
+
+func newPathContainer(seg sPathSegment) JSONObject {
+	if seg.array {
+		return NewArray()
+	}
+	return NewDict()
+}
+
+// maxPathArrayPadding is the most nulls SetPath pads an array with
+const maxPathArrayPadding = 65536
+
+func setPath(root JSONObject, o JSONObject, path string) error {
+	segs, err := parsePathSegments(path)
+	if err != nil {
+		return err
+	}
+	// walk the path once without changing anything, so that a failing path
+	// leaves no half-built containers behind
+	if err := walkPath(root, o, segs, path, false); err != nil {
+		return err
+	}
+	return walkPath(root, o, segs, path, true)
+}
+
+// walkPath follows segs from root and stores o at the end if set is true.
+// Otherwise it only checks the path, walking the containers that would be
+// created without adding them to the tree.
+func walkPath(root JSONObject, o JSONObject, segs []sPathSegment, path string, set bool) error {
+	cur := root
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		if !seg.array {
+			dict, ok := cur.(*JSONDict)
+			if !ok {
+				return fmt.Errorf("path %s: %s is a %s, cannot set key %q", path, pathLocation(segs[:i]), jsonTypeName(cur), seg.key)
+			}
+			if last {
+				if set {
+					dict.Set(seg.key, o)
+				}
+				return nil
+			}
+			child, ok := dict.data[seg.key]
+			if !ok || child == JSONNull {
+				child = newPathContainer(segs[i+1])
+				if set {
+					dict.Set(seg.key, child)
+				}
+			}
+			cur = child
+			continue
+		}
+		arr, ok := cur.(*JSONArray)
+		if !ok {
+			return fmt.Errorf("path %s: %s is a %s, cannot set element %s", path, pathLocation(segs[:i]), jsonTypeName(cur), seg)
+		}
+		idx := seg.index
+		if seg.append {
+			idx = len(arr.data)
+		} else if idx < 0 {
+			idx += len(arr.data)
+			if idx < 0 {
+				return fmt.Errorf("path %s: Out of range %s (length %d)", path, seg, len(arr.data))
+			}
+		} else if idx-len(arr.data) > maxPathArrayPadding {
+			return fmt.Errorf("path %s: %s is too far past the end (length %d)", path, seg, len(arr.data))
+		}
+		if !set {
+			if last {
+				return nil
+			}
+			if idx < len(arr.data) && arr.data[idx] != JSONNull {
+				cur = arr.data[idx]
+			} else {
+				cur = newPathContainer(segs[i+1])
+			}
+			continue
+		}
+		for len(arr.data) <= idx {
+			arr.data = append(arr.data, JSONNull)
+		}
+		if last {
+			arr.data[idx] = o
+			return nil
+		}
+		if arr.data[idx] == JSONNull {
+			arr.data[idx] = newPathContainer(segs[i+1])
+		}
+		cur = arr.data[idx]
+	}
+	return nil
+}
+
+func pathLocation(segs []sPathSegment) string {
+	if len(segs) == 0 {
+		return "root"
+	}
+	return formatPathSegments(segs)
+}
+
+// SetPath stores o at path, e.g. servers[0].nics[-].ip.  Missing dicts
+// and arrays are created, and arrays are padded with JSONNull up to the
+// index set.  It fails if an existing value on the path is of another type,
+// and then leaves the dict as it was.
+func (this *JSONDict) SetPath(o JSONObject, path string) error {
+	return setPath(this, o, path)
+}
+
+func (this *JSONArray) SetPath(o JSONObject, path string) error {
+	return setPath(this, o, path)
+}
@@ -0,0 +1,132 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestSetPath(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		path string
+		val  JSONObject
+		want string
+	}{
+		{
+			name: "create dicts",
+			doc:  `{}`,
+			path: "spec.template.name",
+			val:  NewString("web"),
+			want: `{"spec": {"template": {"name": "web"}}}`,
+		},
+		{
+			name: "create array by index",
+			doc:  `{}`,
+			path: "servers[2].name",
+			val:  NewString("c"),
+			want: `{"servers": [null, null, {"name": "c"}]}`,
+		},
+		{
+			name: "append",
+			doc:  `{"servers": [{"name": "a"}]}`,
+			path: "servers[-].name",
+			val:  NewString("b"),
+			want: `{"servers": [{"name": "a"}, {"name": "b"}]}`,
+		},
+		{
+			name: "write into existing array element",
+			doc:  `{"servers": [{"name": "a", "nics": [{"ip": "1"}]}]}`,
+			path: "servers[0].nics[0].mac",
+			val:  NewString("m"),
+			want: `{"servers": [{"name": "a", "nics": [{"ip": "1", "mac": "m"}]}]}`,
+		},
+		{
+			name: "negative index",
+			doc:  `{"a": [1, 2, 3]}`,
+			path: "a[-1]",
+			val:  NewInt(4),
+			want: `{"a": [1, 2, 4]}`,
+		},
+		{
+			name: "nested arrays",
+			doc:  `{}`,
+			path: "matrix[1][-]",
+			val:  NewInt(1),
+			want: `{"matrix": [null, [1]]}`,
+		},
+		{
+			name: "quoted keys",
+			doc:  `{}`,
+			path: `labels["app.kubernetes.io/name"]['it\'s']`,
+			val:  JSONTrue,
+			want: `{"labels": {"app.kubernetes.io/name": {"it's": true}}}`,
+		},
+		{
+			name: "replace null",
+			doc:  `{"a": null}`,
+			path: "a.b",
+			val:  NewInt(1),
+			want: `{"a": {"b": 1}}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, _ := ParseString(c.doc)
+			want, _ := ParseString(c.want)
+			if err := doc.(*JSONDict).SetPath(c.val, c.path); err != nil {
+				t.Fatalf("SetPath fail %s", err)
+			}
+			if !doc.Equals(want) {
+				t.Errorf("want %s, got %s", want, doc)
+			}
+		})
+	}
+	arr := NewArray()
+	if err := arr.SetPath(NewString("x"), "[1].name"); err != nil {
+		t.Fatalf("SetPath fail %s", err)
+	}
+	if arr.String() != `[null,{"name":"x"}]` {
+		t.Errorf("unexpected %s", arr)
+	}
+}
+
+func TestSetPathErrors(t *testing.T) {
+	cases := []struct {
+		path string
+		err  string
+	}{
+		{"", "empty path"},
+		{"a..b", `path "a..b": empty key at offset 2`},
+		{"a[x]", `path "a[x]": invalid index "x"`},
+		{"a[0", `path "a[0": missing ] at offset 3`},
+		{"a[0]b", `path "a[0]b": expect . or [ at offset 4`},
+		{`a["b]`, `path "a[\"b]": unterminated quoted key`},
+		{"name.first", `path name.first: name is a JSONString, cannot set key "first"`},
+		{"servers.name", `path servers.name: servers is a JSONArray, cannot set key "name"`},
+		{"servers[0][0]", `path servers[0][0]: servers[0] is a JSONDict, cannot set element [0]`},
+		{"name[0]", `path name[0]: name is a JSONString, cannot set element [0]`},
+		{"servers[-3]", `path servers[-3]: Out of range [-3] (length 1)`},
+		{"a[-1]", `path a[-1]: Out of range [-1] (length 0)`},
+		{"a.b[0].c[-1]", `path a.b[0].c[-1]: Out of range [-1] (length 0)`},
+		{"servers[1000000000]", `path servers[1000000000]: [1000000000] is too far past the end (length 1)`},
+		{"a[0].b[65537]", `path a[0].b[65537]: [65537] is too far past the end (length 0)`},
+		{"servers[0].id.x", `path servers[0].id.x: servers[0].id is a JSONInt, cannot set key "x"`},
+	}
+	for _, c := range cases {
+		const json = `{"name": "x", "servers": [{"id": 1}]}`
+		doc, _ := ParseString(json)
+		err := doc.(*JSONDict).SetPath(JSONTrue, c.path)
+		if err == nil {
+			t.Errorf("%q should fail", c.path)
+		} else if err.Error() != c.err {
+			t.Errorf("%q: want error %s, got %s", c.path, c.err, err)
+		}
+		if want, _ := ParseString(json); !doc.Equals(want) {
+			t.Errorf("%q: failing path changed the dict to %s", c.path, doc)
+		}
+	}
+	arr := NewArray()
+	if err := arr.SetPath(JSONTrue, "a"); err == nil || err.Error() != `path a: root is a JSONArray, cannot set key "a"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
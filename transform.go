@@ -0,0 +1,1627 @@
+package jsonutils
+
+/**
+jsonutils.Transform
+
+Reshape JSONObject trees with a subset of the jq language, e.g.
+
+	tr, err := CompileTransform(`.servers | map(select(.status == "running") | {name, ip: .nics[0].ip})`)
+	out, err := tr.RunOne(resp)
+
+Supported syntax:
+
+	.  .name  ."name"  .[0]  .[-1]  .[1:3]  .[]  ..   path access and iteration
+	a | b   a, b   a // b                           pipe, comma and alternative
+	== != < <= > >=   and  or   + - * / %           operators
+	[exprs]   {name, "key": expr, (expr): expr}     array and object construction
+	"text \(expr)"                                  string interpolation
+	if c then a elif d then b else e end            conditional
+	expr?                                           suppress errors
+
+Builtins: length, keys, has(k), map(f), select(f), not, empty, type, add,
+tostring, tonumber, sort, sort_by(f), unique, min, max, first, last,
+join(s), split(s), test(re), startswith(s), endswith(s), ascii_downcase,
+ascii_upcase, to_entries, from_entries, with_entries(f).
+
+Like jq, an expression produces zero or more outputs for each input.
+
+*/
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type Transform struct {
+	expr string
+	root jqExpr
+}
+
+// CompileTransform parses a jq expression for repeated use
+func CompileTransform(expr string) (*Transform, error) {
+	p := &jqParser{expr: expr, regexps: &jqRegexpCache{res: make(map[string]*regexp.Regexp)}}
+	root, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return &Transform{expr: expr, root: root}, nil
+}
+
+func (t *Transform) String() string {
+	return t.expr
+}
+
+// Run applies the transformation to input and returns all its outputs
+func (t *Transform) Run(input JSONObject) ([]JSONObject, error) {
+	return t.root.eval(input)
+}
+
+// RunOne applies the transformation to input and returns its first output
+func (t *Transform) RunOne(input JSONObject) (JSONObject, error) {
+	outputs, err := t.root.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("transform %s produced no output", t.expr)
+	}
+	return outputs[0], nil
+}
+
+type jqExpr interface {
+	eval(input JSONObject) ([]JSONObject, error)
+}
+
+type jqIdentity struct{}
+
+func (e jqIdentity) eval(input JSONObject) ([]JSONObject, error) {
+	return []JSONObject{input}, nil
+}
+
+type jqRecurse struct{}
+
+func (e jqRecurse) eval(input JSONObject) ([]JSONObject, error) {
+	return jqDescendants(input, nil), nil
+}
+
+func jqDescendants(o JSONObject, out []JSONObject) []JSONObject {
+	out = append(out, o)
+	switch v := o.(type) {
+	case *JSONDict:
+		for _, k := range v.SortedKeys() {
+			out = jqDescendants(v.data[k], out)
+		}
+	case *JSONArray:
+		for _, e := range v.data {
+			out = jqDescendants(e, out)
+		}
+	}
+	return out
+}
+
+type jqLiteral struct{ val JSONObject }
+
+func (e jqLiteral) eval(input JSONObject) ([]JSONObject, error) {
+	return []JSONObject{e.val}, nil
+}
+
+type jqPipe struct{ left, right jqExpr }
+
+func (e jqPipe) eval(input JSONObject) ([]JSONObject, error) {
+	lefts, err := e.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0)
+	for _, l := range lefts {
+		rights, err := e.right.eval(l)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, rights...)
+	}
+	return outputs, nil
+}
+
+type jqComma struct{ left, right jqExpr }
+
+func (e jqComma) eval(input JSONObject) ([]JSONObject, error) {
+	lefts, err := e.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := e.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return append(lefts, rights...), nil
+}
+
+type jqAlternative struct{ left, right jqExpr }
+
+func (e jqAlternative) eval(input JSONObject) ([]JSONObject, error) {
+	lefts, _ := e.left.eval(input)
+	outputs := make([]JSONObject, 0)
+	for _, l := range lefts {
+		if jqTruthy(l) {
+			outputs = append(outputs, l)
+		}
+	}
+	if len(outputs) > 0 {
+		return outputs, nil
+	}
+	return e.right.eval(input)
+}
+
+type jqTry struct{ expr jqExpr }
+
+func (e jqTry) eval(input JSONObject) ([]JSONObject, error) {
+	outputs, err := e.expr.eval(input)
+	if err != nil {
+		return []JSONObject{}, nil
+	}
+	return outputs, nil
+}
+
+type jqField struct {
+	target jqExpr
+	name   string
+}
+
+func (e jqField) eval(input JSONObject) ([]JSONObject, error) {
+	targets, err := e.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0, len(targets))
+	for _, t := range targets {
+		val, err := jqIndex(t, NewString(e.name))
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, val)
+	}
+	return outputs, nil
+}
+
+type jqIndexExpr struct {
+	target, index jqExpr
+}
+
+func (e jqIndexExpr) eval(input JSONObject) ([]JSONObject, error) {
+	targets, err := e.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := e.index.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0)
+	for _, t := range targets {
+		for _, idx := range indices {
+			val, err := jqIndex(t, idx)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, val)
+		}
+	}
+	return outputs, nil
+}
+
+func jqIndex(target, index JSONObject) (JSONObject, error) {
+	if target == JSONNull {
+		return JSONNull, nil
+	}
+	switch v := target.(type) {
+	case *JSONDict:
+		if key, ok := index.(*JSONString); ok {
+			if val, ok := v.data[key.data]; ok {
+				return val, nil
+			}
+			return JSONNull, nil
+		}
+	case *JSONArray:
+		if n, ok := jsonNumber(index); ok {
+			idx := int(math.Floor(n))
+			if idx < 0 {
+				idx += len(v.data)
+			}
+			if idx < 0 || idx >= len(v.data) {
+				return JSONNull, nil
+			}
+			return v.data[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", jqTypeName(target), index)
+}
+
+type jqSlice struct {
+	target   jqExpr
+	from, to jqExpr
+}
+
+func (e jqSlice) eval(input JSONObject) ([]JSONObject, error) {
+	targets, err := e.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(expr jqExpr, def int, length int) (int, error) {
+		if expr == nil {
+			return def, nil
+		}
+		vals, err := expr.eval(input)
+		if err != nil {
+			return 0, err
+		}
+		if len(vals) == 0 || vals[0] == JSONNull {
+			return def, nil
+		}
+		n, ok := jsonNumber(vals[0])
+		if !ok {
+			return 0, fmt.Errorf("slice bound must be a number, got %s", jqTypeName(vals[0]))
+		}
+		i := int(math.Floor(n))
+		if i < 0 {
+			i += length
+		}
+		return minInt(maxInt(i, 0), length), nil
+	}
+	outputs := make([]JSONObject, 0, len(targets))
+	for _, t := range targets {
+		var length int
+		switch v := t.(type) {
+		case *JSONArray:
+			length = len(v.data)
+		case *JSONString:
+			length = utf8.RuneCountInString(v.data)
+		default:
+			if t == JSONNull {
+				outputs = append(outputs, JSONNull)
+				continue
+			}
+			return nil, fmt.Errorf("cannot slice %s", jqTypeName(t))
+		}
+		from, err := bound(e.from, 0, length)
+		if err != nil {
+			return nil, err
+		}
+		to, err := bound(e.to, length, length)
+		if err != nil {
+			return nil, err
+		}
+		if to < from {
+			to = from
+		}
+		switch v := t.(type) {
+		case *JSONArray:
+			outputs = append(outputs, NewArray(v.data[from:to]...))
+		case *JSONString:
+			outputs = append(outputs, NewString(string([]rune(v.data)[from:to])))
+		}
+	}
+	return outputs, nil
+}
+
+type jqIterate struct{ target jqExpr }
+
+func (e jqIterate) eval(input JSONObject) ([]JSONObject, error) {
+	targets, err := e.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0)
+	for _, t := range targets {
+		vals, err := jqValues(t)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, vals...)
+	}
+	return outputs, nil
+}
+
+func jqValues(o JSONObject) ([]JSONObject, error) {
+	switch v := o.(type) {
+	case *JSONArray:
+		return v.data, nil
+	case *JSONDict:
+		vals := make([]JSONObject, 0, len(v.data))
+		for _, k := range v.SortedKeys() {
+			vals = append(vals, v.data[k])
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", jqTypeName(o))
+}
+
+type jqArrayConstruct struct{ expr jqExpr }
+
+func (e jqArrayConstruct) eval(input JSONObject) ([]JSONObject, error) {
+	if e.expr == nil {
+		return []JSONObject{NewArray()}, nil
+	}
+	vals, err := e.expr.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return []JSONObject{NewArray(vals...)}, nil
+}
+
+type jqObjectEntry struct {
+	key, value jqExpr
+}
+
+type jqObjectConstruct struct{ entries []jqObjectEntry }
+
+func (e jqObjectConstruct) eval(input JSONObject) ([]JSONObject, error) {
+	results := []*JSONDict{NewDict()}
+	for _, entry := range e.entries {
+		keys, err := entry.key.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := entry.value.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]*JSONDict, 0, len(results)*len(keys)*len(vals))
+		for _, r := range results {
+			for _, k := range keys {
+				key, ok := k.(*JSONString)
+				if !ok {
+					return nil, fmt.Errorf("object keys must be strings, got %s", jqTypeName(k))
+				}
+				for _, v := range vals {
+					dict := r.Copy()
+					dict.Set(key.data, v)
+					next = append(next, dict)
+				}
+			}
+		}
+		results = next
+	}
+	outputs := make([]JSONObject, len(results))
+	for i := range results {
+		outputs[i] = results[i]
+	}
+	return outputs, nil
+}
+
+type jqInterpolation struct {
+	parts []jqExpr
+	// texts[i] precedes parts[i], the last text follows the last part
+	texts []string
+}
+
+func (e jqInterpolation) eval(input JSONObject) ([]JSONObject, error) {
+	results := []string{e.texts[0]}
+	for i, part := range e.parts {
+		vals, err := part.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]string, 0, len(results)*len(vals))
+		for _, r := range results {
+			for _, v := range vals {
+				next = append(next, r+jqToString(v)+e.texts[i+1])
+			}
+		}
+		results = next
+	}
+	outputs := make([]JSONObject, len(results))
+	for i := range results {
+		outputs[i] = NewString(results[i])
+	}
+	return outputs, nil
+}
+
+type jqIf struct {
+	cond, then, otherwise jqExpr
+}
+
+func (e jqIf) eval(input JSONObject) ([]JSONObject, error) {
+	conds, err := e.cond.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0)
+	for _, c := range conds {
+		branch := e.otherwise
+		if jqTruthy(c) {
+			branch = e.then
+		}
+		vals, err := branch.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, vals...)
+	}
+	return outputs, nil
+}
+
+type jqBinary struct {
+	op          string
+	left, right jqExpr
+}
+
+func (e jqBinary) eval(input JSONObject) ([]JSONObject, error) {
+	if e.op == "and" || e.op == "or" {
+		return e.evalLogic(input)
+	}
+	lefts, err := e.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := e.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0, len(lefts)*len(rights))
+	for _, r := range rights {
+		for _, l := range lefts {
+			val, err := jqOperate(e.op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, val)
+		}
+	}
+	return outputs, nil
+}
+
+func (e jqBinary) evalLogic(input JSONObject) ([]JSONObject, error) {
+	lefts, err := e.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0)
+	for _, l := range lefts {
+		if jqTruthy(l) == (e.op == "or") {
+			outputs = append(outputs, NewBool(jqTruthy(l)))
+			continue
+		}
+		rights, err := e.right.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rights {
+			outputs = append(outputs, NewBool(jqTruthy(r)))
+		}
+	}
+	return outputs, nil
+}
+
+func jqOperate(op string, l, r JSONObject) (JSONObject, error) {
+	switch op {
+	case "==":
+		return NewBool(jsonValueEquals(l, r)), nil
+	case "!=":
+		return NewBool(!jsonValueEquals(l, r)), nil
+	case "<":
+		return NewBool(jqCompare(l, r) < 0), nil
+	case "<=":
+		return NewBool(jqCompare(l, r) <= 0), nil
+	case ">":
+		return NewBool(jqCompare(l, r) > 0), nil
+	case ">=":
+		return NewBool(jqCompare(l, r) >= 0), nil
+	}
+	if op == "+" {
+		if l == JSONNull {
+			return r, nil
+		}
+		if r == JSONNull {
+			return l, nil
+		}
+	}
+	ln, lnum := jsonNumber(l)
+	rn, rnum := jsonNumber(r)
+	if lnum && rnum {
+		li, lint := l.(*JSONInt)
+		ri, rint := r.(*JSONInt)
+		bothInt := lint && rint
+		switch op {
+		case "+":
+			if bothInt {
+				return NewInt(li.data + ri.data), nil
+			}
+			return NewFloat(ln + rn), nil
+		case "-":
+			if bothInt {
+				return NewInt(li.data - ri.data), nil
+			}
+			return NewFloat(ln - rn), nil
+		case "*":
+			if bothInt {
+				return NewInt(li.data * ri.data), nil
+			}
+			return NewFloat(ln * rn), nil
+		case "/":
+			if rn == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", l, r)
+			}
+			if bothInt && li.data%ri.data == 0 {
+				return NewInt(li.data / ri.data), nil
+			}
+			return NewFloat(ln / rn), nil
+		case "%":
+			if int64(rn) == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", l, r)
+			}
+			return NewInt(int64(ln) % int64(rn)), nil
+		}
+	}
+	switch lv := l.(type) {
+	case *JSONString:
+		if rv, ok := r.(*JSONString); ok {
+			switch op {
+			case "+":
+				return NewString(lv.data + rv.data), nil
+			case "/":
+				return NewStringArray(strings.Split(lv.data, rv.data)), nil
+			}
+		}
+	case *JSONArray:
+		if rv, ok := r.(*JSONArray); ok {
+			switch op {
+			case "+":
+				arr := NewArray(lv.data...)
+				arr.Add(rv.data...)
+				return arr, nil
+			case "-":
+				arr := NewArray()
+				for _, e := range lv.data {
+					found := false
+					for _, e2 := range rv.data {
+						if jsonValueEquals(e, e2) {
+							found = true
+							break
+						}
+					}
+					if !found {
+						arr.Add(e)
+					}
+				}
+				return arr, nil
+			}
+		}
+	case *JSONDict:
+		if rv, ok := r.(*JSONDict); ok {
+			switch op {
+			case "+":
+				dict := lv.Copy()
+				dict.Update(rv)
+				return dict, nil
+			case "*":
+				return DeepMerge(lv, rv, nil)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s (%s) and %s (%s) cannot be operated with %s", jqTypeName(l), l, jqTypeName(r), r, op)
+}
+
+func jqTruthy(o JSONObject) bool {
+	return o != JSONNull && o != JSONFalse
+}
+
+func jqTypeName(o JSONObject) string {
+	switch o.(type) {
+	case *JSONDict:
+		return "object"
+	case *JSONArray:
+		return "array"
+	case *JSONString:
+		return "string"
+	case *JSONInt, *JSONFloat:
+		return "number"
+	case *JSONBool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func jqTypeOrder(o JSONObject) int {
+	switch o.(type) {
+	case *JSONBool:
+		if o == JSONTrue {
+			return 2
+		}
+		return 1
+	case *JSONInt, *JSONFloat:
+		return 3
+	case *JSONString:
+		return 4
+	case *JSONArray:
+		return 5
+	case *JSONDict:
+		return 6
+	}
+	return 0
+}
+
+// jqCompare orders values like jq: null < false < true < numbers <
+// strings < arrays < objects
+func jqCompare(a, b JSONObject) int {
+	ta, tb := jqTypeOrder(a), jqTypeOrder(b)
+	if ta != tb {
+		return ta - tb
+	}
+	switch va := a.(type) {
+	case *JSONInt, *JSONFloat:
+		na, _ := jsonNumber(a)
+		nb, _ := jsonNumber(b)
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+	case *JSONString:
+		return strings.Compare(va.data, b.(*JSONString).data)
+	case *JSONArray:
+		vb := b.(*JSONArray)
+		for i := 0; i < len(va.data) && i < len(vb.data); i++ {
+			if c := jqCompare(va.data[i], vb.data[i]); c != 0 {
+				return c
+			}
+		}
+		return len(va.data) - len(vb.data)
+	case *JSONDict:
+		vb := b.(*JSONDict)
+		ka, kb := NewStringArray(va.SortedKeys()), NewStringArray(vb.SortedKeys())
+		if c := jqCompare(ka, kb); c != 0 {
+			return c
+		}
+		for _, k := range va.SortedKeys() {
+			if c := jqCompare(va.data[k], vb.data[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func jqToString(o JSONObject) string {
+	switch v := o.(type) {
+	case *JSONString:
+		return v.data
+	case *JSONFloat:
+		return strconv.FormatFloat(v.data, 'f', -1, 64)
+	}
+	return o.String()
+}
+
+type jqCall struct {
+	name string
+	args []jqExpr
+	// re is the regular expression of test with a literal argument, and
+	// regexps caches those of the other arguments of test
+	re      *regexp.Regexp
+	regexps *jqRegexpCache
+}
+
+// jqRegexpCache holds the regular expressions compiled by a Transform, so
+// that test compiles each pattern once however many inputs it sees
+type jqRegexpCache struct {
+	lock sync.Mutex
+	res  map[string]*regexp.Regexp
+}
+
+func (c *jqRegexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if re, ok := c.res[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %s", pattern, err)
+	}
+	c.res[pattern] = re
+	return re, nil
+}
+
+var jqBuiltinArity = map[string]int{
+	"length": 0, "keys": 0, "has": 1, "map": 1, "select": 1, "not": 0,
+	"empty": 0, "type": 0, "add": 0, "tostring": 0, "tonumber": 0,
+	"sort": 0, "sort_by": 1, "unique": 0, "min": 0, "max": 0,
+	"first": 0, "last": 0, "join": 1, "split": 1, "test": 1,
+	"startswith": 1, "endswith": 1, "ascii_downcase": 0, "ascii_upcase": 0,
+	"to_entries": 0, "from_entries": 0, "with_entries": 1,
+}
+
+func (e jqCall) eval(input JSONObject) ([]JSONObject, error) {
+	switch e.name {
+	case "empty":
+		return []JSONObject{}, nil
+	case "select":
+		conds, err := e.args[0].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		outputs := make([]JSONObject, 0)
+		for _, c := range conds {
+			if jqTruthy(c) {
+				outputs = append(outputs, input)
+			}
+		}
+		return outputs, nil
+	case "map":
+		return jqArrayConstruct{expr: jqPipe{left: jqIterate{target: jqIdentity{}}, right: e.args[0]}}.eval(input)
+	case "sort_by":
+		arr, ok := input.(*JSONArray)
+		if !ok {
+			return nil, fmt.Errorf("%s cannot be sorted, as it is not an array", jqTypeName(input))
+		}
+		keys := make([]JSONObject, len(arr.data))
+		for i, elem := range arr.data {
+			vals, err := e.args[0].eval(elem)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = NewArray(vals...)
+		}
+		idx := make([]int, len(arr.data))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(i, j int) bool {
+			return jqCompare(keys[idx[i]], keys[idx[j]]) < 0
+		})
+		sorted := NewArray()
+		for _, i := range idx {
+			sorted.Add(arr.data[i])
+		}
+		return []JSONObject{sorted}, nil
+	case "test":
+		return e.evalTest(input)
+	case "with_entries":
+		return jqPipe{
+			left: jqCall{name: "to_entries"},
+			right: jqPipe{
+				left:  jqCall{name: "map", args: e.args},
+				right: jqCall{name: "from_entries"},
+			},
+		}.eval(input)
+	}
+	if len(e.args) == 0 {
+		val, err := jqCall0(e.name, input)
+		if err != nil {
+			return nil, err
+		}
+		return []JSONObject{val}, nil
+	}
+	args, err := e.args[0].eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0, len(args))
+	for _, arg := range args {
+		val, err := jqCall1(e.name, input, arg)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, val)
+	}
+	return outputs, nil
+}
+
+func (e jqCall) evalTest(input JSONObject) ([]JSONObject, error) {
+	s, ok := input.(*JSONString)
+	if e.re != nil {
+		if !ok {
+			return nil, fmt.Errorf("test cannot be applied to %s and string", jqTypeName(input))
+		}
+		return []JSONObject{NewBool(e.re.MatchString(s.data))}, nil
+	}
+	args, err := e.args[0].eval(input)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]JSONObject, 0, len(args))
+	for _, arg := range args {
+		a, aok := arg.(*JSONString)
+		if !ok || !aok {
+			return nil, fmt.Errorf("test cannot be applied to %s and %s", jqTypeName(input), jqTypeName(arg))
+		}
+		re, err := e.regexps.compile(a.data)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, NewBool(re.MatchString(s.data)))
+	}
+	return outputs, nil
+}
+
+func jqCall0(name string, input JSONObject) (JSONObject, error) {
+	switch name {
+	case "length":
+		switch v := input.(type) {
+		case *JSONString:
+			return NewInt(int64(utf8.RuneCountInString(v.data))), nil
+		case *JSONArray:
+			return NewInt(int64(len(v.data))), nil
+		case *JSONDict:
+			return NewInt(int64(len(v.data))), nil
+		case *JSONInt:
+			if v.data < 0 {
+				return NewInt(-v.data), nil
+			}
+			return v, nil
+		case *JSONFloat:
+			return NewFloat(math.Abs(v.data)), nil
+		}
+		if input == JSONNull {
+			return NewInt(0), nil
+		}
+	case "keys":
+		switch v := input.(type) {
+		case *JSONDict:
+			return NewStringArray(v.SortedKeys()), nil
+		case *JSONArray:
+			keys := NewArray()
+			for i := range v.data {
+				keys.Add(NewInt(int64(i)))
+			}
+			return keys, nil
+		}
+	case "not":
+		return NewBool(!jqTruthy(input)), nil
+	case "type":
+		return NewString(jqTypeName(input)), nil
+	case "add":
+		vals, err := jqValues(input)
+		if err != nil {
+			return nil, err
+		}
+		var sum JSONObject = JSONNull
+		for _, v := range vals {
+			sum, err = jqOperate("+", sum, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	case "tostring":
+		return NewString(jqToString(input)), nil
+	case "tonumber":
+		switch v := input.(type) {
+		case *JSONInt, *JSONFloat:
+			return input, nil
+		case *JSONString:
+			s := strings.TrimSpace(v.data)
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return NewInt(i), nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return NewFloat(f), nil
+			}
+			return nil, fmt.Errorf("cannot parse %q as a number", v.data)
+		}
+	case "sort", "unique", "min", "max":
+		arr, ok := input.(*JSONArray)
+		if !ok {
+			break
+		}
+		sorted := make([]JSONObject, len(arr.data))
+		copy(sorted, arr.data)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return jqCompare(sorted[i], sorted[j]) < 0
+		})
+		switch name {
+		case "sort":
+			return NewArray(sorted...), nil
+		case "unique":
+			unique := NewArray()
+			for i, v := range sorted {
+				if i == 0 || jqCompare(sorted[i-1], v) != 0 {
+					unique.Add(v)
+				}
+			}
+			return unique, nil
+		}
+		if len(sorted) == 0 {
+			return JSONNull, nil
+		}
+		if name == "min" {
+			return sorted[0], nil
+		}
+		return sorted[len(sorted)-1], nil
+	case "first":
+		return jqIndex(input, NewInt(0))
+	case "last":
+		return jqIndex(input, NewInt(-1))
+	case "ascii_downcase", "ascii_upcase":
+		if s, ok := input.(*JSONString); ok {
+			if name == "ascii_downcase" {
+				return NewString(strings.ToLower(s.data)), nil
+			}
+			return NewString(strings.ToUpper(s.data)), nil
+		}
+	case "to_entries":
+		dict, ok := input.(*JSONDict)
+		if !ok {
+			break
+		}
+		entries := NewArray()
+		for _, k := range dict.SortedKeys() {
+			entries.Add(NewDict(JSONPair{key: "key", val: NewString(k)}, JSONPair{key: "value", val: dict.data[k]}))
+		}
+		return entries, nil
+	case "from_entries":
+		arr, ok := input.(*JSONArray)
+		if !ok {
+			break
+		}
+		dict := NewDict()
+		for _, entry := range arr.data {
+			var key, val JSONObject = JSONNull, JSONNull
+			for _, k := range []string{"key", "k", "name", "Name", "Key", "K"} {
+				if v, _ := jqIndex(entry, NewString(k)); v != nil && jqTruthy(v) {
+					key = v
+					break
+				}
+			}
+			for _, k := range []string{"value", "v", "Value", "V"} {
+				if v, _ := jqIndex(entry, NewString(k)); v != nil && v != JSONNull {
+					val = v
+					break
+				}
+			}
+			switch key.(type) {
+			case *JSONString, *JSONInt, *JSONFloat, *JSONBool:
+				dict.Set(jqToString(key), val)
+			default:
+				return nil, fmt.Errorf("cannot use %s as object key", jqTypeName(key))
+			}
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("%s (%s) has no %s", jqTypeName(input), input, name)
+}
+
+func jqCall1(name string, input, arg JSONObject) (JSONObject, error) {
+	switch name {
+	case "has":
+		switch v := input.(type) {
+		case *JSONDict:
+			if key, ok := arg.(*JSONString); ok {
+				_, ok := v.data[key.data]
+				return NewBool(ok), nil
+			}
+		case *JSONArray:
+			if n, ok := jsonNumber(arg); ok {
+				return NewBool(n >= 0 && int(n) < len(v.data)), nil
+			}
+		}
+		return nil, fmt.Errorf("cannot check whether %s has a %s key", jqTypeName(input), jqTypeName(arg))
+	case "join":
+		vals, err := jqValues(input)
+		if err != nil {
+			return nil, err
+		}
+		sep, ok := arg.(*JSONString)
+		if !ok {
+			return nil, fmt.Errorf("join separator must be a string")
+		}
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			switch v.(type) {
+			case *JSONDict, *JSONArray:
+				return nil, fmt.Errorf("cannot join with %s", jqTypeName(v))
+			}
+			if v != JSONNull {
+				strs[i] = jqToString(v)
+			}
+		}
+		return NewString(strings.Join(strs, sep.data)), nil
+	}
+	s, ok := input.(*JSONString)
+	a, aok := arg.(*JSONString)
+	if !ok || !aok {
+		return nil, fmt.Errorf("%s cannot be applied to %s and %s", name, jqTypeName(input), jqTypeName(arg))
+	}
+	switch name {
+	case "split":
+		return NewStringArray(strings.Split(s.data, a.data)), nil
+	case "startswith":
+		return NewBool(strings.HasPrefix(s.data, a.data)), nil
+	case "endswith":
+		return NewBool(strings.HasSuffix(s.data, a.data)), nil
+	}
+	return nil, fmt.Errorf("unknown function %s/1", name)
+}
+
+type jqParser struct {
+	expr    string
+	pos     int
+	regexps *jqRegexpCache
+}
+
+func (p *jqParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("transform %q: %s at offset %d", p.expr, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *jqParser) skipSpace() {
+	for p.pos < len(p.expr) {
+		switch p.expr[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		case '#':
+			for p.pos < len(p.expr) && p.expr[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *jqParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *jqParser) consume(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func isJQIdentByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// keyword consumes the identifier kw if it is next
+func (p *jqParser) keyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if strings.HasPrefix(p.expr[p.pos:], kw) && (end >= len(p.expr) || !isJQIdentByte(p.expr[end], false)) {
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.expr) && isJQIdentByte(p.expr[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return p.expr[start:p.pos]
+}
+
+func (p *jqParser) parsePipe() (jqExpr, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = jqPipe{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseComma() (jqExpr, error) {
+	left, err := p.parseAlternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume(",") {
+		right, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		left = jqComma{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseAlternative() (jqExpr, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.consume("//") {
+		right, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		return jqAlternative{left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseOr() (jqExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseAnd() (jqExpr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseCompare() (jqExpr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return jqBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseAdditive() (jqExpr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: string(op), left: left, right: right}
+	}
+}
+
+func (p *jqParser) parseMultiplicative() (jqExpr, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		op := p.peek()
+		if (op != '*' && op != '/' && op != '%') || strings.HasPrefix(p.expr[p.pos:], "//") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: string(op), left: left, right: right}
+	}
+}
+
+func (p *jqParser) parsePostfix() (jqExpr, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		switch {
+		case p.peek() == '.' && p.pos+1 < len(p.expr) && p.expr[p.pos+1] != '.':
+			p.pos++
+			if p.peek() == '[' {
+				continue
+			}
+			name, err := p.parseFieldName()
+			if err != nil {
+				return nil, err
+			}
+			expr = jqField{target: expr, name: name}
+		case p.peek() == '[':
+			expr, err = p.parseBracketSuffix(expr)
+			if err != nil {
+				return nil, err
+			}
+		case p.peek() == '?':
+			p.pos++
+			expr = jqTry{expr: expr}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *jqParser) parseFieldName() (string, error) {
+	if p.peek() == '"' {
+		str, err := p.parseString()
+		if err != nil {
+			return "", err
+		}
+		lit, ok := str.(jqLiteral)
+		if !ok {
+			return "", p.errorf("field name cannot be interpolated")
+		}
+		return lit.val.(*JSONString).data, nil
+	}
+	if !isJQIdentByte(p.peek(), true) {
+		return "", p.errorf("expect a field name")
+	}
+	return p.ident(), nil
+}
+
+func (p *jqParser) parseBracketSuffix(target jqExpr) (jqExpr, error) {
+	p.pos++ // [
+	if p.consume("]") {
+		return jqIterate{target: target}, nil
+	}
+	var from jqExpr
+	if !p.consume(":") {
+		var err error
+		from, err = p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if p.consume("]") {
+			return jqIndexExpr{target: target, index: from}, nil
+		}
+		if !p.consume(":") {
+			return nil, p.errorf("expect ] or :")
+		}
+	}
+	slice := jqSlice{target: target, from: from}
+	if !p.consume("]") {
+		to, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume("]") {
+			return nil, p.errorf("expect ]")
+		}
+		slice.to = to
+	}
+	return slice, nil
+}
+
+func (p *jqParser) parsePrimary() (jqExpr, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end of expression")
+	case strings.HasPrefix(p.expr[p.pos:], ".."):
+		p.pos += 2
+		return jqRecurse{}, nil
+	case c == '.':
+		p.pos++
+		if p.peek() == '"' || isJQIdentByte(p.peek(), true) {
+			name, err := p.parseFieldName()
+			if err != nil {
+				return nil, err
+			}
+			return jqField{target: jqIdentity{}, name: name}, nil
+		}
+		return jqIdentity{}, nil
+	case c == '"':
+		return p.parseString()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c == '(':
+		p.pos++
+		expr, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("expect )")
+		}
+		return expr, nil
+	case c == '[':
+		p.pos++
+		if p.consume("]") {
+			return jqArrayConstruct{}, nil
+		}
+		expr, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume("]") {
+			return nil, p.errorf("expect ]")
+		}
+		return jqArrayConstruct{expr: expr}, nil
+	case c == '{':
+		return p.parseObject()
+	case isJQIdentByte(c, true):
+		return p.parseIdentifier()
+	}
+	return nil, p.errorf("unexpected %q", string(c))
+}
+
+func (p *jqParser) parseNumber() (jqExpr, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+		if c := p.peek(); c < '0' || c > '9' {
+			// unary minus of an expression
+			operand, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return jqBinary{op: "-", left: jqLiteral{val: NewInt(0)}, right: operand}, nil
+		}
+	}
+	for p.pos < len(p.expr) && ((p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9') || p.expr[p.pos] == '.') {
+		p.pos++
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	num := p.expr[start:p.pos]
+	if i, err := strconv.ParseInt(num, 10, 64); err == nil {
+		return jqLiteral{val: NewInt(i)}, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", num)
+	}
+	return jqLiteral{val: NewFloat(f)}, nil
+}
+
+// parseString parses a string literal, which may interpolate expressions
+// with \(expr)
+func (p *jqParser) parseString() (jqExpr, error) {
+	p.pos++ // "
+	interp := jqInterpolation{}
+	var buf strings.Builder
+	for {
+		if p.pos >= len(p.expr) {
+			return nil, p.errorf("unterminated string")
+		}
+		c := p.expr[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			interp.texts = append(interp.texts, buf.String())
+			if len(interp.parts) == 0 {
+				return jqLiteral{val: NewString(interp.texts[0])}, nil
+			}
+			return interp, nil
+		case '\\':
+			if p.pos >= len(p.expr) {
+				return nil, p.errorf("unterminated string")
+			}
+			c = p.expr[p.pos]
+			p.pos++
+			switch c {
+			case '(':
+				expr, err := p.parsePipe()
+				if err != nil {
+					return nil, err
+				}
+				if !p.consume(")") {
+					return nil, p.errorf("expect ) closing interpolation")
+				}
+				interp.texts = append(interp.texts, buf.String())
+				interp.parts = append(interp.parts, expr)
+				buf.Reset()
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case 'u':
+				if p.pos+4 > len(p.expr) {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.expr[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				p.pos += 4
+				buf.WriteRune(rune(r))
+			default:
+				buf.WriteByte(c)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func (p *jqParser) parseObject() (jqExpr, error) {
+	p.pos++ // {
+	obj := jqObjectConstruct{}
+	if p.consume("}") {
+		return obj, nil
+	}
+	for {
+		p.skipSpace()
+		var entry jqObjectEntry
+		var name string
+		switch c := p.peek(); {
+		case c == '"':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			entry.key = key
+			if lit, ok := key.(jqLiteral); ok {
+				name = lit.val.(*JSONString).data
+			}
+		case c == '(':
+			p.pos++
+			key, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if !p.consume(")") {
+				return nil, p.errorf("expect )")
+			}
+			entry.key = key
+		case isJQIdentByte(c, true):
+			name = p.ident()
+			entry.key = jqLiteral{val: NewString(name)}
+		default:
+			return nil, p.errorf("expect an object key")
+		}
+		if p.consume(":") {
+			// like jq, values may be piped but not separated by commas
+			value, err := p.parseAlternative()
+			if err != nil {
+				return nil, err
+			}
+			for p.consume("|") {
+				right, err := p.parseAlternative()
+				if err != nil {
+					return nil, err
+				}
+				value = jqPipe{left: value, right: right}
+			}
+			entry.value = value
+		} else if len(name) > 0 {
+			// {name} is short for {name: .name}
+			entry.value = jqField{target: jqIdentity{}, name: name}
+		} else {
+			return nil, p.errorf("expect :")
+		}
+		obj.entries = append(obj.entries, entry)
+		if p.consume("}") {
+			return obj, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expect , or }")
+		}
+	}
+}
+
+func (p *jqParser) parseIdentifier() (jqExpr, error) {
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "true":
+		return jqLiteral{val: JSONTrue}, nil
+	case "false":
+		return jqLiteral{val: JSONFalse}, nil
+	case "null":
+		return jqLiteral{val: JSONNull}, nil
+	case "if":
+		return p.parseIf()
+	}
+	arity, ok := jqBuiltinArity[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s", name)
+	}
+	call := jqCall{name: name}
+	if p.consume("(") {
+		for {
+			arg, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.consume(")") {
+				break
+			}
+			if !p.consume(";") {
+				return nil, p.errorf("expect ; or )")
+			}
+		}
+	}
+	if len(call.args) != arity {
+		p.pos = start
+		return nil, p.errorf("%s/%d is not defined", name, len(call.args))
+	}
+	if name == "test" {
+		call.regexps = p.regexps
+		if lit, ok := call.args[0].(jqLiteral); ok {
+			if pattern, ok := lit.val.(*JSONString); ok {
+				re, err := p.regexps.compile(pattern.data)
+				if err != nil {
+					p.pos = start
+					return nil, p.errorf("%s", err)
+				}
+				call.re = re
+			}
+		}
+	}
+	return call, nil
+}
+
+func (p *jqParser) parseIf() (jqExpr, error) {
+	cond, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("then") {
+		return nil, p.errorf("expect then")
+	}
+	then, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	expr := jqIf{cond: cond, then: then, otherwise: jqIdentity{}}
+	switch {
+	case p.keyword("elif"):
+		expr.otherwise, err = p.parseIf()
+		return expr, err
+	case p.keyword("else"):
+		expr.otherwise, err = p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !p.keyword("end") {
+		return nil, p.errorf("expect end")
+	}
+	return expr, nil
+}
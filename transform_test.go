@@ -0,0 +1,177 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	doc, _ := ParseString(`{
+		"name": "cluster",
+		"servers": [
+			{"name": "web1", "cpu": 2, "status": "running", "nics": [{"ip": "10.0.0.1"}], "tags": {"env": "prod"}},
+			{"name": "web2", "cpu": 4, "status": "stopped", "nics": []},
+			{"name": "db1", "cpu": 8.5, "status": "running", "nics": [{"ip": "10.0.0.3"}]}
+		]
+	}`)
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{`.name`, `"cluster"`},
+		{`."name"`, `"cluster"`},
+		{`.servers[0].name`, `"web1"`},
+		{`.servers[-1].name`, `"db1"`},
+		{`.servers[1:].[0].name`, `"web2"`},
+		{`.servers[].name`, `"web1" "web2" "db1"`},
+		{`.servers | length`, `3`},
+		{`.name | length`, `7`},
+		{`.missing.deep`, `null`},
+		{`.servers | map(.name)`, `["web1","web2","db1"]`},
+		{`[.servers[] | select(.status == "running") | .name]`, `["web1","db1"]`},
+		{`.servers | map(select(.cpu >= 4 and .status != "stopped") | {name, ip: .nics[0].ip})`, `[{"ip":"10.0.0.3","name":"db1"}]`},
+		{`.servers[0] | keys`, `["cpu","name","nics","status","tags"]`},
+		{`.servers[0] | {(.name): .cpu, "n-ics": (.nics | length)}`, `{"n-ics":1,"web1":2}`},
+		{`.servers[] | "\(.name) has \(.cpu) cpus"`, `"web1 has 2 cpus" "web2 has 4 cpus" "db1 has 8.5 cpus"`},
+		{`.servers | map(.cpu) | add`, `14.500000`},
+		{`.servers[0].cpu * 2 + 1`, `5`},
+		{`.servers[1].cpu / .servers[0].cpu`, `2`},
+		{`.servers[0].cpu / 4`, `0.500000`},
+		{`.name, .servers[0].name`, `"cluster" "web1"`},
+		{`.servers[0].missing // "default"`, `"default"`},
+		{`.servers | map(.tags.env // "none")`, `["prod","none","none"]`},
+		{`.servers | sort_by(-.cpu) | map(.name)`, `["db1","web2","web1"]`},
+		{`.servers | map(.status) | unique`, `["running","stopped"]`},
+		{`.servers | map(.name) | join(",")`, `"web1,web2,db1"`},
+		{`.servers[] | if .cpu > 4 then "big" elif .cpu > 2 then "medium" else "small" end`, `"small" "medium" "big"`},
+		{`.servers[0].tags | to_entries`, `[{"key":"env","value":"prod"}]`},
+		{`.servers[0].tags | with_entries({key: .key | ascii_upcase, value})`, `{"ENV":"prod"}`},
+		{`[.servers[].name | select(test("^web"))]`, `["web1","web2"]`},
+		{`.name | split("u")`, `["cl","ster"]`},
+		{`.servers[0].name.x?`, ""},
+		{`[.servers[0].nics[0].ip, .name] | map(type)`, `["string","string"]`},
+		{`[..|.ip? // empty]`, `["10.0.0.1","10.0.0.3"]`},
+		{`{a: 1} + {b: 2}`, `{"a":1,"b":2}`},
+		{`[1, 2, 3] - [2]`, `[1,3]`},
+		{`"1" | tonumber + 1`, `2`},
+		{`[.servers[].cpu] | max`, `8.500000`},
+		{`.servers | first | .name`, `"web1"`},
+		{`.servers[0] | has("tags"), has("x")`, `true false`},
+		{`(.servers | length) > 2 | not`, `false`},
+		{`[] | add`, `null`},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			tr, err := CompileTransform(c.expr)
+			if err != nil {
+				t.Fatalf("compile fail %s", err)
+			}
+			outputs, err := tr.Run(doc)
+			if err != nil {
+				t.Fatalf("run fail %s", err)
+			}
+			got := make([]string, len(outputs))
+			for i := range outputs {
+				got[i] = outputs[i].String()
+			}
+			if strings.Join(got, " ") != c.want {
+				t.Errorf("want %s, got %s", c.want, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestTransformRunMany(t *testing.T) {
+	tr, err := CompileTransform(`{id: .id, tags: (.tags // [] | length)}`)
+	if err != nil {
+		t.Fatalf("compile fail %s", err)
+	}
+	for _, c := range []struct {
+		in   string
+		want string
+	}{
+		{`{"id": 1, "tags": ["a", "b"]}`, `{"id":1,"tags":2}`},
+		{`{"id": 2}`, `{"id":2,"tags":0}`},
+	} {
+		in, _ := ParseString(c.in)
+		out, err := tr.RunOne(in)
+		if err != nil {
+			t.Fatalf("run fail %s", err)
+		}
+		if out.String() != c.want {
+			t.Errorf("want %s, got %s", c.want, out)
+		}
+	}
+	identity, _ := CompileTransform(".")
+	if out, _ := identity.RunOne(JSONTrue); out != JSONTrue {
+		t.Errorf(". should output the input")
+	}
+	empty, _ := CompileTransform("empty")
+	if _, err := empty.RunOne(JSONTrue); err == nil {
+		t.Errorf("RunOne without output should fail")
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		".a |",
+		".[",
+		"{a",
+		"{(1)}",
+		`"abc`,
+		"reverse",
+		"map",
+		"map(.a; .b)",
+		"if . then 1",
+		".a )",
+		`test("(")`,
+	} {
+		if _, err := CompileTransform(expr); err == nil {
+			t.Errorf("compiling %q should fail", expr)
+		}
+	}
+	doc, _ := ParseString(`{"a": "x", "n": [1]}`)
+	for _, expr := range []string{
+		".a[0]",
+		".a[]",
+		".n + 1",
+		".n.x",
+		"1 / 0",
+		`{(.n): 1}`,
+		".a | tonumber",
+		`.n | test("x")`,
+		`.a | test(.)?, test(1)`,
+	} {
+		tr, err := CompileTransform(expr)
+		if err != nil {
+			t.Fatalf("compile %q fail %s", expr, err)
+		}
+		if _, err := tr.Run(doc); err == nil {
+			t.Errorf("running %q should fail", expr)
+		}
+	}
+}
+
+func TestTransformTestRegexps(t *testing.T) {
+	tr, err := CompileTransform(`map(test("^a" + .))`)
+	if err != nil {
+		t.Fatalf("compile fail %s", err)
+	}
+	in, _ := ParseString(`["b", "b", "c", "b"]`)
+	out, err := tr.RunOne(in)
+	if err != nil || out.String() != `[false,false,false,false]` {
+		t.Fatalf("got %v %v", out, err)
+	}
+	cache := tr.root.(jqCall).args[0].(jqCall).regexps
+	if len(cache.res) != 2 {
+		t.Errorf("compiled %d patterns, want 2", len(cache.res))
+	}
+	literal, _ := CompileTransform(`test("^a")`)
+	if re := literal.root.(jqCall).re; re == nil || re.String() != "^a" {
+		t.Errorf("literal pattern not compiled with the transform")
+	}
+	if out, err := literal.RunOne(NewString("ab")); err != nil || out != JSONTrue {
+		t.Errorf("got %v %v", out, err)
+	}
+}
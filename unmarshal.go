@@ -27,11 +27,11 @@ func (this *JSONValue) Unmarshal(obj interface{}, keys ...string) error {
 }
 
 func (this *JSONArray) Unmarshal(obj interface{}, keys ...string) error {
-	return jsonUnmarshal(this, obj, keys)
+	return unwrapFieldError(jsonUnmarshal(this, obj, keys))
 }
 
 func (this *JSONDict) Unmarshal(obj interface{}, keys ...string) error {
-	return jsonUnmarshal(this, obj, keys)
+	return unwrapFieldError(jsonUnmarshal(this, obj, keys))
 }
 
 func jsonUnmarshal(jo JSONObject, o interface{}, keys []string) error {
@@ -46,6 +46,44 @@ func jsonUnmarshal(jo JSONObject, o interface{}, keys []string) error {
 	return jo.unmarshalValue(value)
 }
 
+// jsonFieldError is the error of unmarshaling the value at path.  It keeps
+// the message of err, and the path lets UnmarshalYAML tell the source line.
+type jsonFieldError struct {
+	path []string
+	err  error
+}
+
+func (e *jsonFieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e *jsonFieldError) Unwrap() error {
+	return e.err
+}
+
+func (e *jsonFieldError) Cause() error {
+	return e.err
+}
+
+// unwrapFieldError returns the error of unmarshaling a value without its
+// path, so that Unmarshal returns the errors as they are
+func unwrapFieldError(err error) error {
+	if ferr, ok := err.(*jsonFieldError); ok {
+		return ferr.err
+	}
+	return err
+}
+
+// wrapFieldError prepends the dict key or array index of a value to the
+// path of the error of unmarshaling it
+func wrapFieldError(key string, err error) error {
+	if ferr, ok := err.(*jsonFieldError); ok {
+		ferr.path = append([]string{key}, ferr.path...)
+		return ferr
+	}
+	return &jsonFieldError{path: []string{key}, err: err}
+}
+
 func (this *JSONValue) unmarshalValue(val reflect.Value) error {
 	if val.CanSet() {
 		zeroVal := reflect.New(val.Type()).Elem()
@@ -398,7 +436,7 @@ func (this *JSONArray) unmarshalValue(val reflect.Value) error {
 		for i, json := range this.data {
 			err := json.unmarshalValue(val.Index(i))
 			if err != nil {
-				return err
+				return wrapFieldError(strconv.Itoa(i), err)
 			}
 		}
 	default:
@@ -463,7 +501,7 @@ func (this *JSONDict) unmarshalMap(val reflect.Value) error {
 		err := v.unmarshalValue(valVal)
 		if err != nil {
 			log.Debugf("unmarshalMap field %s error %s", k, err)
-			return err
+			return wrapFieldError(k, err)
 		}
 		val.SetMapIndex(keyVal, valVal)
 	}
@@ -472,15 +510,27 @@ func (this *JSONDict) unmarshalMap(val reflect.Value) error {
 
 func (this *JSONDict) unmarshalStruct(val reflect.Value) error {
 	fieldValues := reflectutils.FetchStructFieldValueSetForWrite(val)
+	found := make([]bool, len(fieldValues))
 	for k, v := range this.data {
-		fieldValue, find := fieldValues.GetValue(k)
-		if find {
-			err := v.unmarshalValue(fieldValue)
+		idx := fieldValues.GetStructFieldIndex(k)
+		if idx >= 0 {
+			found[idx] = true
+			err := v.unmarshalValue(fieldValues[idx].Value)
 			if err != nil {
 				log.Debugf("unmarshalStruct field %s error %s", k, err)
-				return err
+				return wrapFieldError(k, err)
 			}
 		}
 	}
+	for i := range fieldValues {
+		if found[i] {
+			continue
+		}
+		err := fillFieldDefault(&fieldValues[i])
+		if err != nil {
+			log.Debugf("unmarshalStruct field %s default error %s", fieldValues[i].Info.FieldName, err)
+			return wrapFieldError(fieldValues[i].Info.MarshalName(), err)
+		}
+	}
 	return nil
 }
@@ -1,7 +1,9 @@
 package jsonutils
 
 import (
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -361,3 +363,29 @@ func TestUnmarshalInterface(t *testing.T) {
 	}
 	t.Logf("%s", meta)
 }
+
+func TestUnmarshalErrorCause(t *testing.T) {
+	type sPort struct {
+		Port int `json:"port"`
+	}
+	type sService struct {
+		Ports []sPort `json:"ports"`
+	}
+	dict, _ := ParseString(`{"ports": [{"port": 80}, {"port": "x"}]}`)
+	var svc sService
+	err := dict.Unmarshal(&svc)
+	if _, ok := err.(*strconv.NumError); !ok {
+		t.Errorf("JSONDict.Unmarshal: got %T %v", err, err)
+	}
+	arr, _ := ParseString(`[{"port": "x"}]`)
+	var ports []sPort
+	err = arr.Unmarshal(&ports)
+	if _, ok := err.(*strconv.NumError); !ok || !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("JSONArray.Unmarshal: got %T %v", err, err)
+	}
+	ferr := wrapFieldError("ports", wrapFieldError("1", err))
+	var nerr *strconv.NumError
+	if !errors.Is(ferr, strconv.ErrSyntax) || !errors.As(ferr, &nerr) || ferr.Error() != err.Error() {
+		t.Errorf("field error %v does not wrap %v", ferr, err)
+	}
+}
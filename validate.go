@@ -0,0 +1,356 @@
+package jsonutils
+
+/**
+jsonutils.Validate
+
+Check the field values of a struct against the rules declared in their
+validate tag, e.g.
+
+	type SServer struct {
+		Name   string   `validate:"nonempty,max=64,pattern=^[a-z][a-z0-9-]*$"`
+		Ip     string   `validate:"format=ipv4"`
+		Cpu    int      `validate:"min=1,max=64"`
+		Policy string   `validate:"enum=always|never"`
+		Disks  []SDisk  `validate:"min=1"`
+	}
+
+Supported rules:
+
+	nonempty    value must not be the zero value, nil or of zero length
+	min=N       numbers: value >= N; strings, slices and maps: length >= N
+	max=N       numbers: value <= N; strings, slices and maps: length <= N
+	len=N       strings, slices and maps: length == N
+	enum=a|b|c  value must be one of the listed values
+	format=F    string must be of format ipv4, cidr, uuid or email
+	pattern=RE  string must match the regular expression RE
+
+As a regular expression may contain commas, pattern must be the last rule of
+a tag, and backslashes in it have to be doubled as in any struct tag value.
+Empty strings are not checked against enum, format and pattern, use nonempty
+to require a value.
+
+Nested structs, and structs contained in slices, arrays and maps, are
+validated recursively.
+
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/reflectutils"
+	"yunion.io/x/pkg/util/regutils"
+)
+
+type ValidationError struct {
+	// Path of the failing field, e.g. servers[0].name
+	Path string
+	// Rule that failed, e.g. min
+	Rule string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type validateRule struct {
+	name string
+	arg  string
+}
+
+var validatePatterns sync.Map
+
+var validateFormats = map[string]func(string) bool{
+	"ipv4":  regutils.MatchIP4Addr,
+	"cidr":  regutils.MatchCIDR,
+	"uuid":  regutils.MatchUUIDExact,
+	"email": regutils.MatchEmail,
+}
+
+// Validate checks obj against its validate tags.  It returns nil if all
+// rules are satisfied, ValidationErrors listing every violation, or a
+// plain error if a tag is malformed.
+func Validate(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(obj))
+	errs := ValidationErrors{}
+	err := validateValue(value, "", &errs)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validatePath(prefix, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func validateValue(val reflect.Value, path string, errs *ValidationErrors) error {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return nil
+		}
+		return validateValue(val.Elem(), path, errs)
+	case reflect.Struct:
+		if val.Type() == gotypes.TimeType {
+			return nil
+		}
+		return validateStruct(val, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			err := validateValue(val.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range val.MapKeys() {
+			err := validateValue(val.MapIndex(key), validatePath(path, key.String()), errs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStruct(val reflect.Value, path string, errs *ValidationErrors) error {
+	fields := reflectutils.FetchStructFieldValueSet(val)
+	for i := range fields {
+		info := fields[i].Info
+		if info.Ignore {
+			continue
+		}
+		fieldPath := validatePath(path, info.MarshalName())
+		if tag, ok := info.Tags["validate"]; ok {
+			rules, err := parseValidateRules(tag)
+			if err != nil {
+				return fmt.Errorf("field %s: %s", info.FieldName, err)
+			}
+			for _, rule := range rules {
+				err := checkValidateRule(fields[i].Value, fieldPath, rule, errs)
+				if err != nil {
+					return fmt.Errorf("field %s: %s", info.FieldName, err)
+				}
+			}
+		}
+		if !fields[i].Value.CanInterface() {
+			continue
+		}
+		err := validateValue(fields[i].Value, fieldPath, errs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseValidateRules(tag string) ([]validateRule, error) {
+	rules := make([]validateRule, 0)
+	for len(tag) > 0 {
+		var part string
+		if strings.HasPrefix(tag, "pattern=") {
+			part = tag
+			tag = ""
+		} else if pos := strings.IndexByte(tag, ','); pos >= 0 {
+			part = tag[:pos]
+			tag = tag[pos+1:]
+		} else {
+			part = tag
+			tag = ""
+		}
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		rule := validateRule{name: part}
+		if pos := strings.IndexByte(part, '='); pos >= 0 {
+			rule.name = part[:pos]
+			rule.arg = part[pos+1:]
+		}
+		switch rule.name {
+		case "nonempty":
+		case "min", "max", "len":
+			if _, err := strconv.ParseFloat(rule.arg, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s rule argument %q", rule.name, rule.arg)
+			}
+		case "enum":
+			if len(rule.arg) == 0 {
+				return nil, fmt.Errorf("empty enum rule")
+			}
+		case "format":
+			if _, ok := validateFormats[rule.arg]; !ok {
+				return nil, fmt.Errorf("unsupported format %q", rule.arg)
+			}
+		case "pattern":
+			if _, err := validatePattern(rule.arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %s", rule.arg, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown validate rule %q", rule.name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func validatePattern(pattern string) (*regexp.Regexp, error) {
+	if reg, ok := validatePatterns.Load(pattern); ok {
+		return reg.(*regexp.Regexp), nil
+	}
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	validatePatterns.Store(pattern, reg)
+	return reg, nil
+}
+
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return true
+		}
+		if json, ok := val.Interface().(JSONObject); ok {
+			return json == JSONNull || json.IsZero()
+		}
+		return false
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return val.Len() == 0
+	}
+	return val.IsZero()
+}
+
+func checkValidateRule(val reflect.Value, path string, rule validateRule, errs *ValidationErrors) error {
+	addError := func(msg string, params ...interface{}) {
+		*errs = append(*errs, &ValidationError{
+			Path: path,
+			Rule: rule.name,
+			Msg:  fmt.Sprintf(msg, params...),
+		})
+	}
+	if rule.name == "nonempty" {
+		if isEmptyValue(val) {
+			addError("must not be empty")
+		}
+		return nil
+	}
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	switch rule.name {
+	case "min", "max", "len":
+		limit, _ := strconv.ParseFloat(rule.arg, 64)
+		var size float64
+		isLength := true
+		switch val.Kind() {
+		case reflect.String:
+			size = float64(utf8.RuneCountInString(val.String()))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			size = float64(val.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			size = float64(val.Int())
+			isLength = false
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			size = float64(val.Uint())
+			isLength = false
+		case reflect.Float32, reflect.Float64:
+			size = val.Float()
+			isLength = false
+		default:
+			return fmt.Errorf("rule %s not applicable to %s", rule.name, val.Type())
+		}
+		if !isLength && rule.name == "len" {
+			return fmt.Errorf("rule len not applicable to %s", val.Type())
+		}
+		what := "value"
+		if isLength {
+			what = "length"
+		}
+		switch {
+		case rule.name == "min" && size < limit:
+			addError("%s %v is less than %s", what, size, rule.arg)
+		case rule.name == "max" && size > limit:
+			addError("%s %v is greater than %s", what, size, rule.arg)
+		case rule.name == "len" && size != limit:
+			addError("length %v is not %s", size, rule.arg)
+		}
+	case "enum":
+		var str string
+		switch val.Kind() {
+		case reflect.String:
+			str = val.String()
+			if len(str) == 0 {
+				return nil
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.Bool:
+			str = fmt.Sprintf("%v", val.Interface())
+		default:
+			return fmt.Errorf("rule enum not applicable to %s", val.Type())
+		}
+		choices := strings.Split(rule.arg, "|")
+		for _, choice := range choices {
+			if choice == str {
+				return nil
+			}
+		}
+		addError("%q is not one of %s", str, strings.Join(choices, ", "))
+	case "format", "pattern":
+		if val.Kind() != reflect.String {
+			return fmt.Errorf("rule %s not applicable to %s", rule.name, val.Type())
+		}
+		str := val.String()
+		if len(str) == 0 {
+			return nil
+		}
+		if rule.name == "format" {
+			if !validateFormats[rule.arg](str) {
+				addError("%q is not a valid %s", str, rule.arg)
+			}
+		} else {
+			reg, _ := validatePattern(rule.arg)
+			if !reg.MatchString(str) {
+				addError("%q does not match pattern %s", str, rule.arg)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+type validateDisk struct {
+	Size   int    `validate:"min=1"`
+	Driver string `validate:"enum=virtio|scsi|ide"`
+}
+
+type validateServer struct {
+	Name    string            `validate:"nonempty,max=8,pattern=^[a-z][a-z0-9-]{0,}$"`
+	Ip      string            `validate:"format=ipv4"`
+	Network string            `validate:"format=cidr"`
+	Id      string            `validate:"format=uuid"`
+	Email   string            `validate:"format=email"`
+	Cpu     int               `validate:"min=1,max=64"`
+	Ratio   float64           `validate:"max=1.5"`
+	Code    string            `validate:"len=3"`
+	Disks   []validateDisk    `validate:"nonempty,max=4"`
+	Labels  map[string]string `validate:"max=2"`
+	Backup  *validateDisk
+}
+
+func TestValidate(t *testing.T) {
+	server := validateServer{
+		Name:    "web-1",
+		Ip:      "10.0.0.1",
+		Network: "10.0.0.0/24",
+		Id:      "9c8e6b0a-5c1d-4a8c-8f1e-0e3d5b1f2c3a",
+		Email:   "admin@example.com",
+		Cpu:     4,
+		Ratio:   1.0,
+		Code:    "abc",
+		Disks:   []validateDisk{{Size: 10, Driver: "virtio"}},
+	}
+	if err := Validate(&server); err != nil {
+		t.Fatalf("valid struct fails: %s", err)
+	}
+	server = validateServer{
+		Name:    "Web_1",
+		Ip:      "10.0.0.256",
+		Network: "10.0.0.0",
+		Id:      "abc",
+		Email:   "admin",
+		Cpu:     0,
+		Ratio:   2,
+		Code:    "ab",
+		Disks:   []validateDisk{{Size: 10, Driver: "virtio"}, {Size: 0, Driver: "floppy"}},
+		Labels:  map[string]string{"a": "1", "b": "2", "c": "3"},
+		Backup:  &validateDisk{Size: 0},
+	}
+	err := Validate(server)
+	if err == nil {
+		t.Fatalf("invalid struct passes")
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("want ValidationErrors, got %#v", err)
+	}
+	want := map[string]string{
+		"name":            "pattern",
+		"ip":              "format",
+		"network":         "format",
+		"id":              "format",
+		"email":           "format",
+		"cpu":             "min",
+		"ratio":           "max",
+		"code":            "len",
+		"disks[1].size":   "min",
+		"disks[1].driver": "enum",
+		"labels":          "max",
+		"backup.size":     "min",
+	}
+	got := make(map[string]string)
+	for _, e := range errs {
+		if _, ok := got[e.Path]; !ok {
+			got[e.Path] = e.Rule
+		}
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Errorf("path %s: want rule %s, got %q", path, rule, got[path])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected errors: %s", err)
+	}
+
+	empty := validateServer{}
+	errs = Validate(&empty).(ValidationErrors)
+	nonempty := 0
+	for _, e := range errs {
+		if e.Rule == "nonempty" {
+			nonempty++
+		}
+	}
+	if nonempty != 2 {
+		t.Errorf("want 2 nonempty errors, got %s", errs)
+	}
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	cases := []interface{}{
+		&struct {
+			A int `validate:"min=a"`
+		}{},
+		&struct {
+			A string `validate:"format=ipv5"`
+		}{},
+		&struct {
+			A string `validate:"unknown"`
+		}{},
+		&struct {
+			A bool `validate:"len=1"`
+		}{},
+	}
+	for _, c := range cases {
+		err := Validate(c)
+		if err == nil {
+			t.Errorf("%#v should fail", c)
+		} else if _, ok := err.(ValidationErrors); ok {
+			t.Errorf("%#v: malformed tag should not be a ValidationErrors: %s", c, err)
+		}
+	}
+}
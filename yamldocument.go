@@ -0,0 +1,489 @@
+package jsonutils
+
+/**
+jsonutils.YAMLDocument
+
+An editable YAML document, for rewriting configuration files.  Set and
+Delete change the value addressed by a JSON pointer by rewriting only the
+text of the nodes they touch: comments, blank lines, the order of keys and
+the style of the other scalars are kept, so that the rewritten file differs
+from the original in the edited lines only.
+
+A string replacing a quoted scalar keeps its quotes, new keys and sequence
+items are added after the last entry of their collection, and values inside
+flow collections are written in flow style.  An edit that cannot be made
+without changing other values, like one through an alias, is refused.
+
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type YAMLDocument struct {
+	src   string
+	p     *yamlParser
+	root  *yamlNode
+	value JSONObject
+	// bom and crlf restore the byte order mark and the line breaks that
+	// the parser normalizes
+	bom  bool
+	crlf bool
+}
+
+// yamlEditTarget is the node addressed by a JSON pointer and the
+// collection holding it
+type yamlEditTarget struct {
+	parent *yamlNode
+	// index counts the key value pairs of a mapping as one
+	index int
+	key   string
+	// node is nil for a new key or the end of a sequence
+	node *yamlNode
+}
+
+// ParseYAMLDocument parses a single YAML document for editing
+func ParseYAMLDocument(str string) (*YAMLDocument, error) {
+	doc := &YAMLDocument{bom: strings.HasPrefix(str, "\ufeff"), crlf: strings.Contains(str, "\r\n")}
+	if err := doc.load(str); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (this *YAMLDocument) load(str string) error {
+	p, docs, err := parseYAMLStream(str)
+	if err != nil {
+		return err
+	}
+	if len(docs) > 1 {
+		return p.errorf(docs[1].start, "expected a single document")
+	}
+	var root *yamlNode
+	var value JSONObject = JSONNull
+	if len(docs) == 1 {
+		root = docs[0].root
+		value, err = newYAMLConverter(p, nil).convert(root)
+		if err != nil {
+			return err
+		}
+	}
+	this.src, this.p, this.root, this.value = p.src, p, root, value
+	return nil
+}
+
+// String returns the text of the document
+func (this *YAMLDocument) String() string {
+	str := this.src
+	if this.crlf {
+		str = strings.Replace(str, "\n", "\r\n", -1)
+	}
+	if this.bom {
+		str = "\ufeff" + str
+	}
+	return str
+}
+
+// Value returns a copy of the content of the document
+func (this *YAMLDocument) Value() JSONObject {
+	return DeepCopy(this.value)
+}
+
+// Get returns a copy of the value addressed by the JSON pointer ptr
+func (this *YAMLDocument) Get(ptr string) (JSONObject, error) {
+	val, err := pointerGetString(this.value, ptr)
+	if err != nil {
+		return nil, err
+	}
+	return DeepCopy(val), nil
+}
+
+// Set stores val at the JSON pointer ptr like SetPointer: an existing value
+// is replaced, a new key or an element at the end of an array is added.
+// The empty pointer replaces the whole document.
+func (this *YAMLDocument) Set(ptr string, val JSONObject) error {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return err
+	}
+	_, lines := yamlEditLines(nil, val)
+	// the value as it reads back from its YAML
+	stored, err := ParseYAML(strings.Join(lines, "\n") + "\n")
+	if err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	want := stored
+	if len(tokens) > 0 {
+		want = DeepCopy(this.value)
+		if err := pointerSet(want, tokens, stored, false); err != nil {
+			return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+		}
+	}
+	src, err := this.setText(tokens, val)
+	if err == nil {
+		err = this.update(src, want)
+	}
+	if err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	return nil
+}
+
+// Delete removes the key or the array element addressed by the JSON
+// pointer ptr like RemovePointer
+func (this *YAMLDocument) Delete(ptr string) error {
+	tokens, err := ParseJSONPointer(ptr)
+	if err != nil {
+		return err
+	}
+	want := DeepCopy(this.value)
+	if _, err := pointerRemove(want, tokens); err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	src, err := this.deleteText(tokens)
+	if err == nil {
+		err = this.update(src, want)
+	}
+	if err != nil {
+		return fmt.Errorf("JSON pointer %s: %s", ptr, err)
+	}
+	return nil
+}
+
+// update replaces the document with the edited text src, if that reads
+// back as want
+func (this *YAMLDocument) update(src string, want JSONObject) error {
+	edited := &YAMLDocument{bom: this.bom, crlf: this.crlf}
+	if err := edited.load(src); err != nil || !edited.value.Equals(want) {
+		return fmt.Errorf("cannot be edited without changing other values")
+	}
+	*this = *edited
+	return nil
+}
+
+// locate finds the node addressed by tokens, which address a value or the
+// place of a new one in the converted document
+func (this *YAMLDocument) locate(tokens []string) (yamlEditTarget, error) {
+	target := yamlEditTarget{node: this.root}
+	for i, token := range tokens {
+		parent := target.node
+		if parent == nil {
+			return target, fmt.Errorf("%s is merged from another mapping", pointerLocation(tokens[:i]))
+		}
+		target = yamlEditTarget{parent: parent, index: -1, key: token}
+		switch parent.kind {
+		case yamlMappingNode:
+			for j := 0; j < len(parent.children); j += 2 {
+				key := parent.children[j]
+				if key.kind == yamlScalarNode && key.value == token && !isYAMLMergeKey(key) {
+					target.index, target.node = j/2, parent.children[j+1]
+				}
+			}
+		case yamlSequenceNode:
+			target.index = len(parent.children)
+			if token != "-" {
+				target.index, _ = strconv.Atoi(token)
+			}
+			if target.index < len(parent.children) {
+				target.node = parent.children[target.index]
+			}
+		case yamlAliasNode:
+			return target, fmt.Errorf("%s is an alias of &%s", pointerLocation(tokens[:i]), parent.value)
+		}
+	}
+	return target, nil
+}
+
+func (this *YAMLDocument) setText(tokens []string, val JSONObject) (string, error) {
+	target, err := this.locate(tokens)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case target.node != nil:
+		return this.replaceText(target, val), nil
+	case target.parent != nil:
+		return this.addText(target, val), nil
+	}
+	// an empty document
+	_, lines := yamlEditLines(nil, val)
+	src := this.src
+	if len(src) > 0 && !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+	return src + strings.Join(lines, "\n") + "\n", nil
+}
+
+func (this *YAMLDocument) replaceText(target yamlEditTarget, val JSONObject) string {
+	node, parent := target.node, target.parent
+	if parent != nil && parent.flow {
+		return this.replaceInline(node, yamlEditFlow(node, val))
+	}
+	inline, lines := yamlEditLines(node, val)
+	indent := this.editIndent(target)
+	start := node.contentStart()
+	if (node.kind == yamlMappingNode || node.kind == yamlSequenceNode) && !node.flow && this.ownsLine(start) {
+		// a block collection on the lines following its key or dash
+		switch {
+		case !inline:
+			col := this.p.column(start)
+			if _, ok := val.(*JSONArray); !ok && parent != nil && parent.kind == yamlMappingNode && col <= indent {
+				// only a sequence may be indented like its key
+				col = indent + 2
+			}
+			return this.splice(this.p.lineStart(start), node.end, yamlIndent(lines, col))
+		case parent == nil:
+			return this.splice(this.p.lineStart(start), node.end, yamlIndent(lines, 0))
+		}
+		// move the value up to its key or dash, keeping a comment there
+		head := this.headEnd(target)
+		comment := strings.TrimRight(this.src[head:this.lineEnd(head)], " ")
+		return this.src[:head] + " " + lines[0] + comment + yamlContinue(lines[1:], indent) + this.src[node.end:]
+	}
+	switch {
+	case inline:
+		return this.replaceInline(node, lines[0]+yamlContinue(lines[1:], indent))
+	case parent != nil && parent.kind == yamlSequenceNode:
+		// a compact collection following the dash
+		return this.replaceInline(node, lines[0]+yamlContinue(lines[1:], indent+2))
+	case parent == nil && this.p.column(start) == 0:
+		return this.splice(start, node.end, yamlIndent(lines, 0))
+	}
+	// move the value down to the lines following its key
+	col := indent + 2
+	if parent == nil {
+		col = 0
+	}
+	end := this.lineEnd(node.end)
+	rest := this.src[node.end:end]
+	if len(rest) > 0 && rest[0] != ' ' {
+		rest = " " + rest
+	}
+	return strings.TrimRight(this.src[:start], " ") + rest + "\n" + yamlIndent(lines, col) + this.src[end:]
+}
+
+// replaceInline replaces the content of node by text, keeping the node
+// properties and a comment following it on its line
+func (this *YAMLDocument) replaceInline(node *yamlNode, text string) string {
+	start := node.contentStart()
+	if start == node.end {
+		// keep an empty node apart from the properties or comment around it
+		if c := this.p.peekAt(start - 1); c != ' ' && c != '\n' && c != 0 {
+			text = " " + text
+		}
+	}
+	end := this.lineEnd(node.end)
+	comment := this.src[node.end:end]
+	if !strings.HasPrefix(strings.TrimLeft(comment, " "), "#") {
+		return this.splice(start, node.end, text)
+	}
+	if comment[0] == '#' {
+		comment = " " + comment
+	}
+	// the comment follows the first line, like a block scalar header
+	first, rest := text, ""
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		first, rest = text[:i], text[i:]
+	}
+	return this.src[:start] + first + comment + rest + this.src[end:]
+}
+
+func (this *YAMLDocument) addText(target yamlEditTarget, val JSONObject) string {
+	parent := target.parent
+	if parent.flow {
+		item := yamlEditFlow(nil, val)
+		if parent.kind == yamlMappingNode {
+			item = yamlFlowQuote(target.key) + ": " + item
+		}
+		if len(parent.children) == 0 {
+			return this.splice(parent.end-1, parent.end-1, item)
+		}
+		last := parent.children[len(parent.children)-1].end
+		return this.splice(last, last, ", "+item)
+	}
+	inline, lines := yamlEditLines(nil, val)
+	indent := this.p.column(parent.start)
+	var entry string
+	switch {
+	case parent.kind == yamlSequenceNode && inline:
+		entry = "- " + lines[0] + yamlContinue(lines[1:], indent)
+	case parent.kind == yamlSequenceNode:
+		entry = "- " + lines[0] + yamlContinue(lines[1:], indent+2)
+	case inline:
+		entry = yamlQuote(target.key) + ": " + lines[0] + yamlContinue(lines[1:], indent)
+	default:
+		entry = yamlQuote(target.key) + ":\n" + yamlIndent(lines, indent+2)
+	}
+	at := this.lineEnd(parent.end)
+	return this.splice(at, at, "\n"+strings.Repeat(" ", indent)+entry)
+}
+
+func (this *YAMLDocument) deleteText(tokens []string) (string, error) {
+	target, err := this.locate(tokens)
+	if err != nil {
+		return "", err
+	}
+	if target.node == nil {
+		return "", fmt.Errorf("%s is merged from another mapping", JSONPointer(tokens...))
+	}
+	parent, i := target.parent, target.index
+	count := len(parent.children)
+	start := func(i int) int { return parent.children[i].start }
+	end := func(i int) int { return parent.children[i].end }
+	if parent.kind == yamlMappingNode {
+		count /= 2
+		start = func(i int) int { return parent.children[2*i].start }
+		end = func(i int) int {
+			key, value := parent.children[2*i], parent.children[2*i+1]
+			if value.end > key.end {
+				return value.end
+			}
+			return key.end
+		}
+	} else if !parent.flow {
+		start = func(i int) int { return parent.dashes[i] }
+	}
+	if parent.flow {
+		switch {
+		case count == 1:
+			return this.splice(start(i), end(i), ""), nil
+		case i < count-1:
+			return this.splice(start(i), start(i+1), ""), nil
+		}
+		return this.splice(end(i-1), end(i), ""), nil
+	}
+	if count == 1 {
+		// leave an empty collection
+		var empty JSONObject = NewArray()
+		if parent.kind == yamlMappingNode {
+			empty = NewDict()
+		}
+		return this.setText(tokens[:len(tokens)-1], empty)
+	}
+	if !this.ownsLine(start(i)) && i < count-1 {
+		// the first entry of a compact collection following a dash
+		return this.splice(start(i), start(i+1), ""), nil
+	}
+	lineEnd := this.lineEnd(end(i))
+	if lineEnd < len(this.src) {
+		lineEnd++
+	}
+	return this.splice(this.p.lineStart(start(i)), lineEnd, ""), nil
+}
+
+// editIndent returns the column of the key or the dash of the target
+func (this *YAMLDocument) editIndent(target yamlEditTarget) int {
+	switch {
+	case target.parent == nil:
+		return 0
+	case target.parent.kind == yamlMappingNode:
+		return this.p.column(target.parent.children[2*target.index].start)
+	}
+	return this.p.column(target.parent.dashes[target.index])
+}
+
+// headEnd returns the offset following the : or - that the target node
+// follows, or its properties
+func (this *YAMLDocument) headEnd(target yamlEditTarget) int {
+	node := target.node
+	if node.start < node.contentStart() {
+		i := node.start
+		for {
+			for !this.p.isBlankAt(i) {
+				i++
+			}
+			end := i
+			for this.p.peekAt(i) == ' ' {
+				i++
+			}
+			if c := this.p.peekAt(i); c != '&' && c != '!' {
+				return end
+			}
+		}
+	}
+	if target.parent.kind == yamlSequenceNode {
+		return target.parent.dashes[target.index] + 1
+	}
+	i := target.parent.children[2*target.index].end
+	for this.src[i] != ':' {
+		i++
+	}
+	return i + 1
+}
+
+func (this *YAMLDocument) lineEnd(offset int) int {
+	if i := strings.IndexByte(this.src[offset:], '\n'); i >= 0 {
+		return offset + i
+	}
+	return len(this.src)
+}
+
+// ownsLine reports whether only spaces precede offset on its line
+func (this *YAMLDocument) ownsLine(offset int) bool {
+	return len(strings.TrimLeft(this.src[this.p.lineStart(offset):offset], " ")) == 0
+}
+
+func (this *YAMLDocument) splice(start, end int, text string) string {
+	return this.src[:start] + text + this.src[end:]
+}
+
+// yamlEditLines returns the lines of val written in place of the node old,
+// which may be nil.  inline is set if the first line follows the key or
+// the dash, the other lines are indented relative to them.
+func yamlEditLines(old *yamlNode, val JSONObject) (bool, []string) {
+	if quoted, ok := yamlQuoteLike(old, val); ok {
+		return true, []string{quoted}
+	}
+	lines := val.yamlLines()
+	if str, ok := val.(*JSONString); ok && str.isYAMLBlock() {
+		return true, append(lines[:1], indentLines(lines[1:], false)...)
+	}
+	switch {
+	case len(lines) > 1, val.isCompond() && lines[0] != "{}" && lines[0] != "[]":
+		return false, lines
+	}
+	return true, lines
+}
+
+// yamlEditFlow returns val written in place of the node old inside a flow
+// collection
+func yamlEditFlow(old *yamlNode, val JSONObject) string {
+	if quoted, ok := yamlQuoteLike(old, val); ok {
+		return quoted
+	}
+	return yamlFlowString(val, false)
+}
+
+// yamlQuoteLike quotes a string replacing a quoted scalar the same way
+func yamlQuoteLike(old *yamlNode, val JSONObject) (string, bool) {
+	str, ok := val.(*JSONString)
+	if !ok || old == nil || old.kind != yamlScalarNode {
+		return "", false
+	}
+	switch {
+	case old.style == yamlDoubleQuotedStyle:
+		return yamlDoubleQuote(str.data), true
+	case old.style == yamlSingleQuotedStyle && !yamlNeedsDoubleQuote(str.data):
+		return yamlSingleQuote(str.data), true
+	}
+	return "", false
+}
+
+// yamlIndent joins lines, indented by indent spaces
+func yamlIndent(lines []string, indent int) string {
+	return strings.TrimPrefix(yamlContinue(lines, indent), "\n")
+}
+
+// yamlContinue returns lines as the lines following another one, indented
+// by indent spaces
+func yamlContinue(lines []string, indent int) string {
+	var buf strings.Builder
+	for _, line := range lines {
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(" ", indent))
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
@@ -0,0 +1,242 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLDocumentEdit(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		edit func(doc *YAMLDocument) error
+		want string
+	}{
+		{
+			name: "replace scalar keeping comment",
+			yaml: "# app\nname: web   # the name\nport: 80\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/name", NewString("api")) },
+			want: "# app\nname: api   # the name\nport: 80\n",
+		},
+		{
+			name: "keep quoting style",
+			yaml: "a: 'x'\nb: \"y\"\n",
+			edit: func(doc *YAMLDocument) error {
+				if err := doc.Set("/a", NewString("it's")); err != nil {
+					return err
+				}
+				return doc.Set("/b", NewString("tab\there"))
+			},
+			want: "a: 'it''s'\nb: \"tab\\there\"\n",
+		},
+		{
+			name: "add key",
+			yaml: "spec:\n  replicas: 1\n\nstatus: {}\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/spec/paused", JSONTrue) },
+			want: "spec:\n  replicas: 1\n  paused: true\n\nstatus: {}\n",
+		},
+		{
+			name: "append to sequence",
+			yaml: "ports:\n- 80\n- 443 # tls\nname: x\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/ports/-", NewInt(8080)) },
+			want: "ports:\n- 80\n- 443 # tls\n- 8080\nname: x\n",
+		},
+		{
+			name: "append mapping to sequence",
+			yaml: "env:\n  - name: A\n    value: \"1\"\n",
+			edit: func(doc *YAMLDocument) error {
+				return doc.Set("/env/-", NewDict(JSONPair{key: "name", val: NewString("B")}, JSONPair{key: "value", val: NewString("2")}))
+			},
+			want: "env:\n  - name: A\n    value: \"1\"\n  - name: B\n    value: '2'\n",
+		},
+		{
+			name: "flow collections",
+			yaml: "labels: {app: web, tier: front}\nports: [80, 443]\n",
+			edit: func(doc *YAMLDocument) error {
+				if err := doc.Set("/labels/zone", NewString("a,b")); err != nil {
+					return err
+				}
+				if err := doc.Delete("/labels/app"); err != nil {
+					return err
+				}
+				return doc.Delete("/ports/1")
+			},
+			want: "labels: {tier: front, zone: 'a,b'}\nports: [80]\n",
+		},
+		{
+			name: "delete entries",
+			yaml: "a: 1\n# about b\nb:\n  c: 2\n  d: 3\ne: 4\n",
+			edit: func(doc *YAMLDocument) error {
+				if err := doc.Delete("/b/c"); err != nil {
+					return err
+				}
+				return doc.Delete("/e")
+			},
+			want: "a: 1\n# about b\nb:\n  d: 3\n",
+		},
+		{
+			name: "delete last entry",
+			yaml: "a:\n  b: 1\nc: [x]\n",
+			edit: func(doc *YAMLDocument) error {
+				if err := doc.Delete("/a/b"); err != nil {
+					return err
+				}
+				return doc.Delete("/c/0")
+			},
+			want: "a: {}\nc: []\n",
+		},
+		{
+			name: "compact collections",
+			yaml: "- name: a\n  id: 1\n- - x\n  - y\n",
+			edit: func(doc *YAMLDocument) error {
+				if err := doc.Delete("/0/name"); err != nil {
+					return err
+				}
+				return doc.Delete("/1/0")
+			},
+			want: "- id: 1\n- - y\n",
+		},
+		{
+			name: "scalar to collection",
+			yaml: "a: 1 # one\nb: 2\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a", NewArray(NewInt(1), NewInt(2))) },
+			want: "a: # one\n  - 1\n  - 2\nb: 2\n",
+		},
+		{
+			name: "collection to scalar",
+			yaml: "a: # list\n  - 1\n  - 2\nb: 2\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a", NewString("none")) },
+			want: "a: none # list\nb: 2\n",
+		},
+		{
+			name: "block scalar",
+			yaml: "a: # script\nb: 1\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a", NewString("echo a\necho b\n")) },
+			want: "a: | # script\n  echo a\n  echo b\nb: 1\n",
+		},
+		{
+			name: "block scalar keeping comment",
+			yaml: "a: x # c\nb: 1\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a", NewString("multi\nline\n")) },
+			want: "a: | # c\n  multi\n  line\nb: 1\n",
+		},
+		{
+			name: "block scalar in sequence keeping comment",
+			yaml: "a:\n  - x # c\n  - y\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a/0", NewString("multi\nline\n")) },
+			want: "a:\n  - | # c\n    multi\n    line\n  - y\n",
+		},
+		{
+			name: "compact mapping keeping comment",
+			yaml: "- x # c\n- y\n",
+			edit: func(doc *YAMLDocument) error {
+				return doc.Set("/0", NewDict(JSONPair{key: "a", val: NewInt(1)}, JSONPair{key: "b", val: NewInt(2)}))
+			},
+			want: "- a: 1 # c\n  b: 2\n- y\n",
+		},
+		{
+			name: "keep anchor",
+			yaml: "a: &x\n  k: 1\nc: 2\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/a", NewInt(2)) },
+			want: "a: &x 2\nc: 2\n",
+		},
+		{
+			name: "empty document",
+			yaml: "# empty\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("", NewDict(JSONPair{key: "a", val: NewInt(1)})) },
+			want: "# empty\na: 1\n",
+		},
+		{
+			name: "crlf",
+			yaml: "a: 1\r\nb: 2\r\n",
+			edit: func(doc *YAMLDocument) error { return doc.Set("/c", NewInt(3)) },
+			want: "a: 1\r\nb: 2\r\nc: 3\r\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := ParseYAMLDocument(c.yaml)
+			if err != nil {
+				t.Fatalf("ParseYAMLDocument: %v", err)
+			}
+			if err := c.edit(doc); err != nil {
+				t.Fatalf("edit: %v", err)
+			}
+			if got := doc.String(); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+			want, err := ParseYAML(c.want)
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			if !doc.Value().Equals(want) {
+				t.Errorf("Value() = %s, want %s", doc.Value(), want)
+			}
+		})
+	}
+}
+
+func TestYAMLDocumentMinimalDiff(t *testing.T) {
+	yaml := `# deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web  # keep this
+  labels: {app: web}
+
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.19"
+          args: [--port, '80']
+`
+	doc, err := ParseYAMLDocument(yaml)
+	if err != nil {
+		t.Fatalf("ParseYAMLDocument: %v", err)
+	}
+	if err := doc.Set("/spec/template/spec/containers/0/image", NewString("nginx:1.21")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	before, after := strings.Split(yaml, "\n"), strings.Split(doc.String(), "\n")
+	if len(before) != len(after) {
+		t.Fatalf("got %q", doc.String())
+	}
+	for i := range before {
+		if before[i] != after[i] && after[i] != `          image: "nginx:1.21"` {
+			t.Errorf("line %d changed to %q", i+1, after[i])
+		}
+	}
+	if val, _ := doc.Get("/spec/template/spec/containers/0/image"); val == nil || !val.Equals(NewString("nginx:1.21")) {
+		t.Errorf("Get = %v", val)
+	}
+}
+
+func TestYAMLDocumentEditError(t *testing.T) {
+	cases := []struct {
+		yaml string
+		edit func(doc *YAMLDocument) error
+		err  string
+	}{
+		{"a: 1\n", func(doc *YAMLDocument) error { return doc.Delete("") }, "JSON pointer : cannot remove the root"},
+		{"a: 1\n", func(doc *YAMLDocument) error { return doc.Delete("/b") }, `JSON pointer /b: no such key "b" at /b`},
+		{"a: 1\n", func(doc *YAMLDocument) error { return doc.Set("/a/b", JSONTrue) }, `JSON pointer /a/b: /a is a JSONInt, cannot descend into "b"`},
+		{"a: &x {k: 1}\nb: *x\n", func(doc *YAMLDocument) error { return doc.Set("/b/k", NewInt(2)) }, "JSON pointer /b/k: /b is an alias of &x"},
+		{"a: &x 1\nb: *x\n", func(doc *YAMLDocument) error { return doc.Set("/a", NewInt(2)) }, "JSON pointer /a: cannot be edited without changing other values"},
+		{"base: &b {k: 1}\nc:\n  <<: *b\n", func(doc *YAMLDocument) error { return doc.Delete("/c/k") }, "JSON pointer /c/k: /c/k is merged from another mapping"},
+	}
+	for _, c := range cases {
+		doc, err := ParseYAMLDocument(c.yaml)
+		if err != nil {
+			t.Fatalf("ParseYAMLDocument(%q): %v", c.yaml, err)
+		}
+		if err := c.edit(doc); err == nil || err.Error() != c.err {
+			t.Errorf("%q: want error %s, got %v", c.yaml, c.err, err)
+		}
+		if doc.String() != c.yaml {
+			t.Errorf("%q: changed to %q", c.yaml, doc.String())
+		}
+	}
+}
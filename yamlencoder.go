@@ -0,0 +1,231 @@
+package jsonutils
+
+/**
+jsonutils.YAMLStringWithOptions
+
+Writes YAML in the style set by YAMLEncodeOptions, for output that has to
+pass the YAML linters of other tools: the indentation width, sequences
+indented below their key or written at its indentation, flow style for
+short collections of scalars, folding of long strings at a line width and
+sorted keys.  With the default options the output is that of YAMLString.
+
+	key:              key:            key: [a, b]
+	  - a             - a
+	  - b             - b
+
+*/
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type YAMLEncodeOptions struct {
+	// Indent is the number of spaces per nesting level, from 2 to 9.  0
+	// means 2.
+	Indent int
+
+	// CompactSequences writes a sequence that is the value of a key at the
+	// indentation of the key instead of indenting it
+	CompactSequences bool
+
+	// MaxFlowWidth writes the collections holding no other collection in
+	// flow style, like [a, b] and {k: v}, if that is at most MaxFlowWidth
+	// characters long.  0 writes all non-empty collections in block style.
+	MaxFlowWidth int
+
+	// LineWidth folds plain and quoted strings at spaces, so that their
+	// lines are at most LineWidth characters long where possible.  Literal
+	// block scalars are not folded.  0 means no limit.
+	LineWidth int
+
+	// SortKeys writes dict keys in sorted order instead of the order of
+	// Keys()
+	SortKeys bool
+}
+
+type yamlEncoder struct {
+	opts YAMLEncodeOptions
+}
+
+// YAMLStringWithOptions returns the YAML of o.  opts may be nil for the
+// default options.
+func YAMLStringWithOptions(o JSONObject, opts *YAMLEncodeOptions) string {
+	e := &yamlEncoder{}
+	if opts != nil {
+		e.opts = *opts
+	}
+	switch {
+	case e.opts.Indent < 2:
+		e.opts.Indent = 2
+	case e.opts.Indent > 9:
+		e.opts.Indent = 9
+	}
+	var lines []string
+	if e.isBlock(o) {
+		lines = e.collectionLines(o, 0)
+	} else {
+		lines = e.inlineLines(o, 0, 0)
+	}
+	yaml := strings.Join(lines, "\n")
+	if yamlEndsWithLineBreak(o) {
+		yaml += "\n"
+	}
+	return yaml
+}
+
+// isBlock reports whether o is a collection written in block style
+func (e *yamlEncoder) isBlock(o JSONObject) bool {
+	switch o.(type) {
+	case *JSONDict, *JSONArray:
+		_, flow := e.flowString(o)
+		return !flow
+	}
+	return false
+}
+
+// flowString returns the flow style of the empty collections and of the
+// short collections of scalars
+func (e *yamlEncoder) flowString(o JSONObject) (string, bool) {
+	var items []JSONObject
+	switch v := o.(type) {
+	case *JSONDict:
+		for _, val := range v.data {
+			items = append(items, val)
+		}
+		if len(v.data) == 0 {
+			return "{}", true
+		}
+	case *JSONArray:
+		items = v.data
+		if len(v.data) == 0 {
+			return "[]", true
+		}
+	default:
+		return "", false
+	}
+	if e.opts.MaxFlowWidth <= 0 {
+		return "", false
+	}
+	for _, item := range items {
+		if item.isCompond() {
+			return "", false
+		}
+	}
+	flow := yamlFlowString(o, e.opts.SortKeys)
+	return flow, len(flow) <= e.opts.MaxFlowWidth
+}
+
+// collectionLines returns the lines of a block collection at column col,
+// indented relative to col
+func (e *yamlEncoder) collectionLines(o JSONObject, col int) []string {
+	if arr, ok := o.(*JSONArray); ok {
+		return e.arrayLines(arr, col)
+	}
+	return e.dictLines(o.(*JSONDict), col)
+}
+
+func (e *yamlEncoder) arrayLines(arr *JSONArray, col int) []string {
+	ret := make([]string, 0)
+	for _, item := range arr.data {
+		var lines []string
+		if e.isBlock(item) {
+			lines = e.collectionLines(item, col+2)
+			lines = append(lines[:1], e.indent(lines[1:], 2)...)
+		} else {
+			lines = e.inlineLines(item, col, col+2)
+		}
+		ret = append(ret, "- "+lines[0])
+		ret = append(ret, lines[1:]...)
+	}
+	return ret
+}
+
+func (e *yamlEncoder) dictLines(dict *JSONDict, col int) []string {
+	ret := make([]string, 0)
+	keys := dict.Keys()
+	if e.opts.SortKeys {
+		keys = append([]string{}, keys...)
+		sort.Strings(keys)
+	}
+	for _, key := range keys {
+		val := dict.data[key]
+		k := yamlQuote(key)
+		if !e.isBlock(val) {
+			lines := e.inlineLines(val, col, col+len(k)+2)
+			ret = append(ret, k+": "+lines[0])
+			ret = append(ret, lines[1:]...)
+			continue
+		}
+		ret = append(ret, k+":")
+		if arr, ok := val.(*JSONArray); ok && e.opts.CompactSequences {
+			ret = append(ret, e.arrayLines(arr, col)...)
+		} else {
+			ret = append(ret, e.indent(e.collectionLines(val, col+e.opts.Indent), e.opts.Indent)...)
+		}
+	}
+	return ret
+}
+
+// inlineLines returns the lines of a scalar or flow collection following a
+// key or a dash at column col, its first line starting at column start.
+// The following lines are indented relative to col.
+func (e *yamlEncoder) inlineLines(o JSONObject, col, start int) []string {
+	if flow, ok := e.flowString(o); ok {
+		return []string{flow}
+	}
+	str, ok := o.(*JSONString)
+	if !ok {
+		return o.yamlLines()
+	}
+	if str.isYAMLBlock() {
+		indicator, lines := yamlBlockLines(str.data)
+		indicator = strings.Replace(indicator, "2", strconv.Itoa(e.opts.Indent), 1)
+		return append([]string{indicator}, e.indent(lines, e.opts.Indent)...)
+	}
+	lines := e.fold(yamlQuote(str.data), start, col+e.opts.Indent)
+	return append(lines[:1], e.indent(lines[1:], e.opts.Indent)...)
+}
+
+// fold breaks a plain or quoted scalar starting at column start into lines
+// continued at column indent.  It breaks at single spaces between other
+// characters, which read back as spaces, and not before the indicators
+// that cannot start a line of a plain scalar.
+func (e *yamlEncoder) fold(text string, start, indent int) []string {
+	width := e.opts.LineWidth
+	if width <= 0 || start+len(text) <= width {
+		return []string{text}
+	}
+	plain := text[0] != '\'' && text[0] != '"'
+	lines := make([]string, 0)
+	col := start
+	from, last := 0, 0
+	for i := 1; i < len(text)-1; i++ {
+		if text[i] != ' ' || text[i-1] == ' ' || text[i+1] == ' ' {
+			continue
+		}
+		if plain && strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", text[i+1]) >= 0 {
+			continue
+		}
+		if col+i-from > width && last > from {
+			lines = append(lines, text[from:last])
+			from, col = last+1, indent
+		}
+		last = i
+	}
+	if col+len(text)-from > width && last > from {
+		lines = append(lines, text[from:last])
+		from = last + 1
+	}
+	return append(lines, text[from:])
+}
+
+func (e *yamlEncoder) indent(lines []string, n int) []string {
+	prefix := strings.Repeat(" ", n)
+	ret := make([]string, len(lines))
+	for i, line := range lines {
+		ret[i] = prefix + line
+	}
+	return ret
+}
@@ -0,0 +1,140 @@
+package jsonutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLStringWithOptions(t *testing.T) {
+	obj, _ := ParseString(`{"name": "web", "ports": [80, 443], "labels": {"tier": "front", "app": "web"}, "env": [{"name": "A", "value": "1"}], "script": "echo a\necho b\n"}`)
+	cases := []struct {
+		name string
+		opts YAMLEncodeOptions
+		want string
+	}{
+		{
+			name: "indent",
+			opts: YAMLEncodeOptions{Indent: 4},
+			want: "env:\n    - name: A\n      value: '1'\nlabels:\n    app: web\n    tier: front\nname: web\nports:\n    - 80\n    - 443\nscript: |\n    echo a\n    echo b\n",
+		},
+		{
+			name: "compact sequences",
+			opts: YAMLEncodeOptions{CompactSequences: true},
+			want: "env:\n- name: A\n  value: '1'\nlabels:\n  app: web\n  tier: front\nname: web\nports:\n- 80\n- 443\nscript: |\n  echo a\n  echo b\n",
+		},
+		{
+			name: "flow leaves",
+			opts: YAMLEncodeOptions{MaxFlowWidth: 30},
+			want: "env:\n  - {name: A, value: '1'}\nlabels: {app: web, tier: front}\nname: web\nports: [80, 443]\nscript: |\n  echo a\n  echo b\n",
+		},
+		{
+			name: "flow width",
+			opts: YAMLEncodeOptions{MaxFlowWidth: 10},
+			want: "env:\n  - name: A\n    value: '1'\nlabels:\n  app: web\n  tier: front\nname: web\nports: [80, 443]\nscript: |\n  echo a\n  echo b\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := YAMLStringWithOptions(obj, &c.opts)
+			if got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+			back, err := ParseYAML(got)
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			if !back.Equals(obj) {
+				t.Errorf("read back %s", back)
+			}
+		})
+	}
+}
+
+func TestYAMLStringWithOptionsDefault(t *testing.T) {
+	for _, str := range []string{
+		`{"a": [1, {"b": [[], {}, "x\n"]}], "c": {"d": " lead\ntext"}, "e": "it's", "f": null}`,
+		`[[1, [2]], "two\nlines\n", "keep\n\n"]`,
+	} {
+		obj, _ := ParseString(str)
+		if got, want := YAMLStringWithOptions(obj, nil), obj.YAMLString(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	str := NewString(" indented\nblock\n")
+	if got, want := YAMLStringWithOptions(str, nil), str.YAMLString(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := YAMLStringWithOptions(str, &YAMLEncodeOptions{Indent: 4}); got != "|4\n     indented\n    block\n" {
+		t.Errorf("indentation indicator: got %q", got)
+	}
+}
+
+func TestYAMLStringWithOptionsSortKeys(t *testing.T) {
+	obj := NewDict()
+	obj.ordered = true
+	obj.Set("zeta", NewInt(1))
+	obj.Set("alpha", NewInt(2))
+	if got := YAMLStringWithOptions(obj, nil); got != "zeta: 1\nalpha: 2" {
+		t.Errorf("insertion order: got %q", got)
+	}
+	if got := YAMLStringWithOptions(obj, &YAMLEncodeOptions{SortKeys: true}); got != "alpha: 2\nzeta: 1" {
+		t.Errorf("sorted: got %q", got)
+	}
+	nested := NewOrderedDict(JSONPair{key: "z", val: obj})
+	if got := YAMLStringWithOptions(nested, &YAMLEncodeOptions{SortKeys: true, MaxFlowWidth: 80}); got != "z: {alpha: 2, zeta: 1}" {
+		t.Errorf("sorted flow: got %q", got)
+	}
+	if got := YAMLStringWithOptions(nested, &YAMLEncodeOptions{MaxFlowWidth: 80}); got != "z: {zeta: 1, alpha: 2}" {
+		t.Errorf("insertion order flow: got %q", got)
+	}
+}
+
+func TestYAMLStringWithOptionsEmptyValues(t *testing.T) {
+	cases := []struct {
+		json string
+		opts YAMLEncodeOptions
+		want string
+	}{
+		{`[{"a": ""}]`, YAMLEncodeOptions{}, "- a: ''"},
+		{`[{"a": ""}]`, YAMLEncodeOptions{MaxFlowWidth: 20}, "- {a: ''}"},
+		{`{"x": [{"b": null, "c": []}]}`, YAMLEncodeOptions{}, "x:\n  - b: null\n    c: []"},
+		{`{"x": [{"b": null, "c": []}]}`, YAMLEncodeOptions{CompactSequences: true}, "x:\n- b: null\n  c: []"},
+		{`{"x": [{"b": null, "c": []}]}`, YAMLEncodeOptions{MaxFlowWidth: 20}, "x:\n  - b: null\n    c: []"},
+	}
+	for _, c := range cases {
+		obj, _ := ParseString(c.json)
+		got := YAMLStringWithOptions(obj, &c.opts)
+		if got != c.want {
+			t.Errorf("%s %+v: got %q, want %q", c.json, c.opts, got, c.want)
+		}
+		if back, err := ParseYAML(got); err != nil || !back.Equals(obj) {
+			t.Errorf("%s %+v: read back %v, %v", c.json, c.opts, back, err)
+		}
+	}
+}
+
+func TestYAMLStringWithOptionsLineWidth(t *testing.T) {
+	long := "the quick brown fox jumps over the lazy dog and keeps on running"
+	cases := []struct {
+		val  string
+		want string
+	}{
+		{long, "text: the quick brown fox jumps over the\n  lazy dog and keeps on running"},
+		{"it's " + long, "text: it's the quick brown fox jumps\n  over the lazy dog and keeps on running"},
+		{"tab\t" + long, "text: 'tab\tthe quick brown fox jumps\n  over the lazy dog and keeps on\n  running'"},
+		{"run the program with the flags -v -x", "text: run the program with the\n  flags -v -x"},
+		{"ctl\x01 " + long, "text: \"ctl\\x01 the quick brown fox jumps\n  over the lazy dog and keeps on\n  running\""},
+		{strings.Replace(long, " ", "  ", -1), "text: " + strings.Replace(long, " ", "  ", -1)},
+	}
+	for _, c := range cases {
+		obj := NewDict(JSONPair{key: "text", val: NewString(c.val)})
+		got := YAMLStringWithOptions(obj, &YAMLEncodeOptions{LineWidth: 40})
+		if got != c.want {
+			t.Errorf("%q: got %q, want %q", c.val, got, c.want)
+		}
+		back, err := ParseYAML(got)
+		if err != nil || !back.Equals(obj) {
+			t.Errorf("%q: read back %v, %v", c.val, back, err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package jsonutils
+
+/**
+jsonutils.UnmarshalYAML, jsonutils.MarshalYAML
+
+Convert between YAML and Go values with the reflection of Unmarshal and
+Marshal.  The yaml tag of a struct field names it in YAML and takes
+priority over its json tag, and yaml:"-" leaves the field out of YAML:
+
+	type SService struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas" yaml:"replica_count"`
+		Token    string `yaml:"-"`
+	}
+
+The errors of UnmarshalYAML are of type *YAMLError, at the line of the
+field that cannot be unmarshaled.
+
+*/
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/reflectutils"
+)
+
+// UnmarshalYAML fills obj with the value of a YAML document
+func UnmarshalYAML(str string, obj interface{}) error {
+	p, docs, err := parseYAMLStream(str)
+	if err != nil {
+		return err
+	}
+	if len(docs) > 1 {
+		return p.errorf(docs[1].start, "expected a single document")
+	}
+	var value JSONObject = JSONNull
+	namer := &yamlFieldNamer{
+		offsets:      make(map[string]int),
+		fieldOffsets: make(map[string]int),
+		fieldPaths:   make(map[string]string),
+	}
+	if len(docs) == 1 {
+		root := docs[0].root
+		value, err = newYAMLConverter(p, nil).convert(root)
+		if err != nil {
+			return err
+		}
+		namer.offsets[""] = root.start
+		newYAMLPathWalker(func(path []string, key, val *yamlNode) {
+			if key != nil {
+				// report dict values at the line of their key
+				val = key
+			}
+			namer.offsets[JSONPointer(path...)] = val.start
+		}).walk(root, nil, false)
+	}
+	if obj != nil {
+		value = namer.rename(value, reflect.TypeOf(obj), nil, nil)
+	}
+	err = jsonUnmarshal(value, obj, nil)
+	if err == nil {
+		return nil
+	}
+	var path []string
+	if ferr, ok := err.(*jsonFieldError); ok {
+		path, err = ferr.path, ferr.err
+	}
+	offset, ptr := namer.locate(path)
+	if len(path) == 0 {
+		return p.errorf(offset, "%s", err)
+	}
+	return p.errorf(offset, "%s: %s", ptr, err)
+}
+
+// MarshalYAML returns the YAML of obj
+func MarshalYAML(obj interface{}) string {
+	json := Marshal(obj)
+	if obj != nil {
+		json = (&yamlFieldNamer{toYAML: true}).rename(json, reflect.TypeOf(obj), nil, nil)
+	}
+	return json.YAMLString()
+}
+
+type yamlStructField struct {
+	info reflectutils.SStructFieldInfo
+	typ  reflect.Type
+	// name is the name of the yaml tag, if any
+	name   string
+	ignore bool
+}
+
+// yamlName returns the dict key of the field in YAML
+func (f *yamlStructField) yamlName() string {
+	if len(f.name) > 0 {
+		return f.name
+	}
+	return f.info.MarshalName()
+}
+
+// yamlStructFields returns the fields of a struct type in the order of
+// reflectutils.FetchStructFieldValueSet, with the fields of embedded
+// structs in place of them
+func yamlStructFields(typ reflect.Type) []yamlStructField {
+	fields := make([]yamlStructField, 0)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !gotypes.IsFieldExportable(sf.Name) {
+			continue
+		}
+		if sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && sf.Type != gotypes.TimeType {
+				fields = append(fields, yamlStructFields(embedded)...)
+				continue
+			}
+		}
+		field := yamlStructField{info: reflectutils.ParseStructFieldJsonInfo(sf), typ: sf.Type}
+		if tag, ok := sf.Tag.Lookup("yaml"); ok {
+			opts := strings.Split(tag, ",")
+			if opts[0] == "-" && len(opts) == 1 {
+				field.ignore = true
+			} else {
+				field.name = opts[0]
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// yamlFieldNamer renames the dict keys of struct fields between the names
+// used by Marshal and Unmarshal and their names in YAML, following the Go
+// type of the value
+type yamlFieldNamer struct {
+	toYAML bool
+	// offsets are the source offsets of the values of a document by their
+	// path in YAML, including the values copied by aliases and merge keys.
+	// fieldOffsets and fieldPaths are their offsets and YAML paths by the
+	// renamed paths.
+	offsets      map[string]int
+	fieldOffsets map[string]int
+	fieldPaths   map[string]string
+}
+
+func (n *yamlFieldNamer) rename(obj JSONObject, typ reflect.Type, yamlPath, path []string) JSONObject {
+	if n.offsets != nil {
+		ptr := JSONPointer(yamlPath...)
+		if offset, ok := n.offsets[ptr]; ok {
+			n.fieldOffsets[JSONPointer(path...)] = offset
+			n.fieldPaths[JSONPointer(path...)] = ptr
+		}
+	}
+	for typ.Kind() == reflect.Ptr && !typ.Implements(JSONObjectType) {
+		typ = typ.Elem()
+	}
+	if typ.Implements(JSONObjectType) || reflect.PtrTo(typ).Implements(JSONObjectType) {
+		return obj
+	}
+	switch v := obj.(type) {
+	case *JSONArray:
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			for i, item := range v.data {
+				idx := strconv.Itoa(i)
+				v.data[i] = n.rename(item, typ.Elem(), append(yamlPath, idx), append(path, idx))
+			}
+		}
+	case *JSONDict:
+		switch {
+		case typ.Kind() == reflect.Map:
+			for _, k := range v.Keys() {
+				v.data[k] = n.rename(v.data[k], typ.Elem(), append(yamlPath, k), append(path, k))
+			}
+		case typ.Kind() == reflect.Struct && typ != gotypes.TimeType:
+			return n.renameFields(v, typ, yamlPath, path)
+		}
+	}
+	return obj
+}
+
+func (n *yamlFieldNamer) renameFields(dict *JSONDict, typ reflect.Type, yamlPath, path []string) JSONObject {
+	fields := yamlStructFields(typ)
+	ret := NewDict()
+	for _, k := range dict.Keys() {
+		var field *yamlStructField
+		if n.toYAML {
+			field = yamlFieldByJSONName(fields, k)
+		} else {
+			field = yamlFieldByYAMLName(fields, k)
+		}
+		switch {
+		case field == nil:
+			ret.Set(k, dict.data[k])
+		case field.ignore:
+		case n.toYAML:
+			name := field.yamlName()
+			ret.Set(name, n.rename(dict.data[k], field.typ, append(yamlPath, name), append(path, k)))
+		default:
+			name := field.info.MarshalName()
+			ret.Set(name, n.rename(dict.data[k], field.typ, append(yamlPath, k), append(path, name)))
+		}
+	}
+	return ret
+}
+
+// yamlFieldByJSONName returns the field of a dict key written by Marshal
+func yamlFieldByJSONName(fields []yamlStructField, key string) *yamlStructField {
+	for i := range fields {
+		if fields[i].info.MarshalName() == key {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// yamlFieldByYAMLName returns the field of a YAML dict key, which matches
+// the yaml tag name of a field, or the names Unmarshal matches if the
+// field has no yaml tag name.  The keys of the fields left out of YAML, or
+// renamed by their yaml tag, return an ignored field, and the keys of no
+// field nil.
+func yamlFieldByYAMLName(fields []yamlStructField, key string) *yamlStructField {
+	for i := range fields {
+		if !fields[i].ignore && fields[i].name == key {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		set := reflectutils.SStructFieldValueSet{{Info: fields[i].info}}
+		if set.GetStructFieldIndex(key) < 0 {
+			continue
+		}
+		if len(fields[i].name) > 0 {
+			return &yamlStructField{ignore: true}
+		}
+		return &fields[i]
+	}
+	return nil
+}
+
+// locate returns the source offset and the YAML path of the value at path,
+// or of its closest parent in the source
+func (n *yamlFieldNamer) locate(path []string) (int, string) {
+	for i := len(path); i >= 0; i-- {
+		ptr := JSONPointer(path[:i]...)
+		if offset, ok := n.fieldOffsets[ptr]; ok {
+			return offset, n.fieldPaths[ptr] + JSONPointer(path[i:]...)
+		}
+	}
+	return n.offsets[""], JSONPointer(path...)
+}
@@ -0,0 +1,110 @@
+package jsonutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+type YAMLTestMeta struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type yamlTestPort struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol" default:"TCP"`
+}
+
+type yamlTestService struct {
+	YAMLTestMeta
+	Name     string                  `json:"name"`
+	Replicas int                     `json:"replicas" yaml:"replica_count"`
+	Token    string                  `json:"token" yaml:"-"`
+	Ports    []yamlTestPort          `json:"ports"`
+	Backends map[string]yamlTestPort `json:"backends" yaml:"upstreams"`
+	Extra    JSONObject              `json:"extra" yaml:"x-extra"`
+	Parent   *yamlTestService        `json:"parent"`
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	yaml := `name: web
+replica_count: 3
+replicas: 5
+token: secret
+labels: {app: web}
+ports:
+  - port: 80
+  - port: 53
+    protocol: UDP
+upstreams:
+  api: {port: 8080}
+x-extra: {replica_count: 1}
+parent:
+  name: base
+  replica_count: 1
+`
+	var svc yamlTestService
+	if err := UnmarshalYAML(yaml, &svc); err != nil {
+		t.Fatalf("UnmarshalYAML: %v", err)
+	}
+	want := yamlTestService{
+		YAMLTestMeta: YAMLTestMeta{Labels: map[string]string{"app": "web"}},
+		Name:         "web",
+		Replicas:     3,
+		Ports:        []yamlTestPort{{Port: 80, Protocol: "TCP"}, {Port: 53, Protocol: "UDP"}},
+		Backends:     map[string]yamlTestPort{"api": {Port: 8080, Protocol: "TCP"}},
+		Extra:        NewDict(JSONPair{key: "replica_count", val: NewInt(1)}),
+		Parent:       &yamlTestService{Name: "base", Replicas: 1},
+	}
+	if !reflect.DeepEqual(svc, want) {
+		t.Errorf("got %#v, want %#v", svc, want)
+	}
+}
+
+func TestUnmarshalYAMLError(t *testing.T) {
+	cases := []struct {
+		yaml string
+		err  string
+	}{
+		{"name: web\nreplica_count: many\n", `yaml: line 2 column 1: /replica_count: strconv.ParseInt: parsing "many": invalid syntax`},
+		{"ports:\n  - port: 80\n  - port: [8080]\n", "yaml: line 3 column 5: /ports/1/port: JSONArray type mismatch: int"},
+		{"upstreams:\n  api:\n    port: x\n", `yaml: line 3 column 5: /upstreams/api/port: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"- a\n", "yaml: line 1 column 1: JSONArray type mismatch: jsonutils.yamlTestService"},
+		{"a: 1\n---\nb: 2\n", "yaml: line 2 column 1: expected a single document"},
+	}
+	for _, c := range cases {
+		var svc yamlTestService
+		err := UnmarshalYAML(c.yaml, &svc)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%q: want error %s, got %v", c.yaml, c.err, err)
+		}
+		if _, ok := err.(*YAMLError); !ok {
+			t.Errorf("%q: got %T", c.yaml, err)
+		}
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	svc := yamlTestService{
+		Name:     "web",
+		Replicas: 2,
+		Token:    "secret",
+		Ports:    []yamlTestPort{{Port: 80, Protocol: "TCP"}},
+		Backends: map[string]yamlTestPort{"api": {Port: 8080, Protocol: "TCP"}},
+	}
+	want := "name: web\nports:\n  - port: 80\n    protocol: TCP\nreplica_count: 2\nupstreams:\n  api:\n    port: 8080\n    protocol: TCP"
+	got := MarshalYAML(svc)
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var back yamlTestService
+	if err := UnmarshalYAML(got, &back); err != nil {
+		t.Fatalf("UnmarshalYAML: %v", err)
+	}
+	svc.Token = ""
+	if !reflect.DeepEqual(back, svc) {
+		t.Errorf("read back %#v", back)
+	}
+	if got := MarshalYAML(&svc); got != want {
+		t.Errorf("pointer: got %q", got)
+	}
+}
@@ -0,0 +1,1474 @@
+package jsonutils
+
+/**
+jsonutils.ParseYAML
+
+A YAML 1.2 parser.  The text is first parsed into a tree of yamlNode,
+which keeps the style and the source offsets of every node, and the tree is
+then converted to JSONObject.
+
+Supported are block and flow collections, plain, single and double quoted
+scalars, literal and folded block scalars with chomping and indentation
+indicators, comments, explicit ? keys, anchors, aliases and tags.  Errors
+are reported as *YAMLError with the line and column of the offending
+character.  ParseYAMLDocuments parses streams of several documents.
+Mappings become unordered dicts, or ordered dicts keeping the source order
+of their keys with YAMLParseOptions.Ordered.
+
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type YAMLError struct {
+	// Line and Column are 1-based, Column counts bytes
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *YAMLError) Error() string {
+	return fmt.Sprintf("yaml: line %d column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+type yamlNodeKind int
+
+const (
+	yamlScalarNode yamlNodeKind = iota
+	yamlMappingNode
+	yamlSequenceNode
+	yamlAliasNode
+)
+
+type yamlScalarStyle int
+
+const (
+	yamlPlainStyle yamlScalarStyle = iota
+	yamlSingleQuotedStyle
+	yamlDoubleQuotedStyle
+	yamlLiteralStyle
+	yamlFoldedStyle
+)
+
+type yamlNode struct {
+	kind  yamlNodeKind
+	style yamlScalarStyle
+	// flow is set for collections written as [...] or {...}
+	flow   bool
+	tag    string
+	anchor string
+	// value is the content of a scalar or the name of an alias
+	value string
+	// children are the items of a sequence, or the keys and values of a
+	// mapping in turn
+	children []*yamlNode
+	// start and end are the byte offsets of the node in the source
+	start int
+	end   int
+	// content is the offset following the anchor and tag of the node, if
+	// it has any
+	content int
+	// dashes are the offsets of the - indicators of a block sequence
+	dashes []int
+}
+
+// contentStart returns the offset of the node without its properties
+func (node *yamlNode) contentStart() int {
+	if node.content > node.start {
+		return node.content
+	}
+	return node.start
+}
+
+func (node *yamlNode) isEmpty() bool {
+	return node.kind == yamlScalarNode && node.style == yamlPlainStyle && len(node.value) == 0
+}
+
+type yamlParser struct {
+	src        string
+	pos        int
+	lineStarts []int
+}
+
+func newYAMLParser(str string) *yamlParser {
+	str = strings.TrimPrefix(str, "\ufeff")
+	str = strings.Replace(str, "\r\n", "\n", -1)
+	p := &yamlParser{src: str, lineStarts: []int{0}}
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\n' {
+			p.lineStarts = append(p.lineStarts, i+1)
+		}
+	}
+	return p
+}
+
+// position returns the 1-based line and column of offset
+func (p *yamlParser) position(offset int) (int, int) {
+	line := sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset })
+	return line, offset - p.lineStarts[line-1] + 1
+}
+
+func (p *yamlParser) errorf(offset int, format string, args ...interface{}) error {
+	line, col := p.position(offset)
+	return &YAMLError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *yamlParser) lineStart(offset int) int {
+	line, _ := p.position(offset)
+	return p.lineStarts[line-1]
+}
+
+func (p *yamlParser) column(offset int) int {
+	return offset - p.lineStart(offset)
+}
+
+func (p *yamlParser) peekAt(offset int) byte {
+	if offset < 0 || offset >= len(p.src) {
+		return 0
+	}
+	return p.src[offset]
+}
+
+func (p *yamlParser) peek() byte {
+	return p.peekAt(p.pos)
+}
+
+// isBlankAt reports whether offset is a space, a tab, a line break or the
+// end of the input
+func (p *yamlParser) isBlankAt(offset int) bool {
+	switch p.peekAt(offset) {
+	case 0, ' ', '\t', '\n':
+		return true
+	}
+	return false
+}
+
+func isYAMLFlowIndicator(c byte) bool {
+	switch c {
+	case ',', '[', ']', '{', '}':
+		return true
+	}
+	return false
+}
+
+func (p *yamlParser) isDocumentMarker(offset int) bool {
+	if offset+3 > len(p.src) || p.column(offset) != 0 {
+		return false
+	}
+	marker := p.src[offset : offset+3]
+	return (marker == "---" || marker == "...") && p.isBlankAt(offset+3)
+}
+
+func (p *yamlParser) isSeqEntry(offset int) bool {
+	return p.peekAt(offset) == '-' && p.isBlankAt(offset+1)
+}
+
+func (p *yamlParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) atComment() bool {
+	return p.peek() == '#' && (p.pos == 0 || p.isBlankAt(p.pos-1))
+}
+
+// atLineEnd reports whether only blanks and a comment are left on the line
+func (p *yamlParser) atLineEnd() bool {
+	p.skipSpaces()
+	return p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.atComment()
+}
+
+// endLine consumes the rest of the line, which may hold only blanks and a
+// comment
+func (p *yamlParser) endLine() error {
+	if !p.atLineEnd() {
+		if p.peek() == ':' {
+			return p.errorf(p.pos, "mapping values are not allowed in this context")
+		}
+		return p.errorf(p.pos, "unexpected character %q", p.peek())
+	}
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos < len(p.src) {
+		p.pos++
+	}
+	return nil
+}
+
+// nextContent moves from the start of a line, or from the first character of
+// an indented line, to the first character of the next line with content,
+// skipping blank and comment lines.  It returns the column of that character
+// and false at the end of the input or at a document marker.
+func (p *yamlParser) nextContent() (int, bool, error) {
+	for p.pos < len(p.src) {
+		start := p.lineStart(p.pos)
+		i := start
+		for i < len(p.src) && p.src[i] == ' ' {
+			i++
+		}
+		j := i
+		for j < len(p.src) && (p.src[j] == ' ' || p.src[j] == '\t') {
+			j++
+		}
+		if j >= len(p.src) {
+			p.pos = len(p.src)
+			break
+		}
+		if p.src[j] == '\n' || p.src[j] == '#' {
+			p.pos = j
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			p.pos++
+			continue
+		}
+		if j > i {
+			return 0, false, p.errorf(i, "found a tab character that violates indentation")
+		}
+		p.pos = i
+		if p.isDocumentMarker(i) {
+			return 0, false, nil
+		}
+		return i - start, true, nil
+	}
+	if p.pos > len(p.src) {
+		p.pos = len(p.src)
+	}
+	return 0, false, nil
+}
+
+func (p *yamlParser) emptyNode(offset int) *yamlNode {
+	return &yamlNode{kind: yamlScalarNode, start: offset, end: offset}
+}
+
+func (p *yamlParser) setProperties(node *yamlNode, anchor, tag string, offset int) error {
+	if len(anchor) > 0 {
+		if len(node.anchor) > 0 {
+			return p.errorf(offset, "a node can have only one anchor")
+		}
+		node.anchor = anchor
+	}
+	if len(tag) > 0 {
+		if len(node.tag) > 0 {
+			return p.errorf(offset, "a node can have only one tag")
+		}
+		node.tag = tag
+	}
+	if (len(anchor) > 0 || len(tag) > 0) && offset < node.start {
+		node.content = node.start
+		node.start = offset
+	}
+	return nil
+}
+
+// parseProperties parses the anchor and tag preceding a node
+func (p *yamlParser) parseProperties() (string, string, error) {
+	anchor, tag := "", ""
+	for {
+		start := p.pos
+		switch p.peek() {
+		case '&':
+			if len(anchor) > 0 {
+				return "", "", p.errorf(start, "a node can have only one anchor")
+			}
+			p.pos++
+			anchor = p.scanName()
+			if len(anchor) == 0 {
+				return "", "", p.errorf(start, "anchor name is empty")
+			}
+		case '!':
+			if len(tag) > 0 {
+				return "", "", p.errorf(start, "a node can have only one tag")
+			}
+			if p.peekAt(p.pos+1) == '<' {
+				end := strings.IndexByte(p.src[p.pos:], '>')
+				if end < 0 {
+					return "", "", p.errorf(start, "did not find the expected '>' of a verbatim tag")
+				}
+				tag = p.src[p.pos : p.pos+end+1]
+				p.pos += end + 1
+			} else {
+				for !p.isBlankAt(p.pos) && !isYAMLFlowIndicator(p.src[p.pos]) {
+					p.pos++
+				}
+				tag = p.src[start:p.pos]
+			}
+		default:
+			return anchor, tag, nil
+		}
+		if !p.isBlankAt(p.pos) && !isYAMLFlowIndicator(p.peek()) {
+			return "", "", p.errorf(p.pos, "unexpected character %q after node properties", p.peek())
+		}
+		p.skipSpaces()
+	}
+}
+
+// scanName scans an anchor or alias name
+func (p *yamlParser) scanName() string {
+	start := p.pos
+	for !p.isBlankAt(p.pos) && !isYAMLFlowIndicator(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseBlockNode parses a node in block context whose lines are indented
+// more than indent.  inline is set for a value following "key:" on the same
+// line, where block collections cannot start.  seqAtIndent allows a
+// sequence at indent itself, as the value of a mapping key.  On return the
+// parser is at the start of the line following the node.
+func (p *yamlParser) parseBlockNode(indent int, inline, seqAtIndent bool) (*yamlNode, error) {
+	p.skipSpaces()
+	start := p.pos
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	if p.atLineEnd() {
+		content := p.pos
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+		col, ok, err := p.nextContent()
+		if err != nil {
+			return nil, err
+		}
+		if ok && (col > indent || seqAtIndent && col == indent && p.isSeqEntry(p.pos)) {
+			node, err := p.parseBlockNode(indent, false, false)
+			if err != nil {
+				return nil, err
+			}
+			return node, p.setProperties(node, anchor, tag, start)
+		}
+		node := p.emptyNode(content)
+		return node, p.setProperties(node, anchor, tag, start)
+	}
+	col := p.column(p.pos)
+	var node *yamlNode
+	switch c := p.peek(); {
+	case c == '-' && p.isBlankAt(p.pos+1):
+		if inline {
+			return nil, p.errorf(p.pos, "block sequence entries are not allowed in this context")
+		}
+		node, err = p.parseBlockSequence(col)
+	case c == '?' && p.isBlankAt(p.pos+1):
+		if inline {
+			return nil, p.errorf(p.pos, "mapping keys are not allowed in this context")
+		}
+		node, err = p.parseBlockMapping(col, nil)
+	case c == '|' || c == '>':
+		node, err = p.parseBlockScalar(indent)
+	default:
+		node, err = p.parseInlineNode()
+		if err != nil {
+			return nil, err
+		}
+		end := p.pos
+		p.skipSpaces()
+		if p.isMappingColon(node) {
+			if inline {
+				return nil, p.errorf(p.pos, "mapping values are not allowed in this context")
+			}
+			if err := p.setProperties(node, anchor, tag, start); err != nil {
+				return nil, err
+			}
+			return p.parseBlockMapping(col, node)
+		}
+		p.pos = end
+		if node.kind == yamlScalarNode && node.style == yamlPlainStyle {
+			p.continuePlain(node, indent)
+		}
+		err = p.endLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, p.setProperties(node, anchor, tag, start)
+}
+
+// isMappingColon reports whether the parser is at the : following the key
+// node.  After a quoted or flow key the : needs no following blank.
+func (p *yamlParser) isMappingColon(key *yamlNode) bool {
+	if p.peek() != ':' {
+		return false
+	}
+	if p.isBlankAt(p.pos + 1) {
+		return true
+	}
+	jsonLike := key.flow || key.style == yamlSingleQuotedStyle || key.style == yamlDoubleQuotedStyle
+	return jsonLike && key.end == p.pos
+}
+
+func (p *yamlParser) parseBlockMapping(indent int, key *yamlNode) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMappingNode, start: p.pos}
+	if key != nil {
+		node.start = key.start
+	}
+	for {
+		var value *yamlNode
+		var err error
+		if key == nil && p.peek() == '?' && p.isBlankAt(p.pos+1) {
+			p.pos++
+			key, err = p.parseBlockNode(indent, false, false)
+			if err != nil {
+				return nil, err
+			}
+			col, ok, err := p.nextContent()
+			if err != nil {
+				return nil, err
+			}
+			if ok && col == indent && p.peek() == ':' && p.isBlankAt(p.pos+1) {
+				p.pos++
+				value, err = p.parseBlockNode(indent, false, false)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				value = p.emptyNode(key.end)
+			}
+		} else {
+			if key == nil {
+				start := p.pos
+				anchor, tag, err := p.parseProperties()
+				if err != nil {
+					return nil, err
+				}
+				key, err = p.parseInlineNode()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.setProperties(key, anchor, tag, start); err != nil {
+					return nil, err
+				}
+				p.skipSpaces()
+				if !p.isMappingColon(key) {
+					return nil, p.errorf(p.pos, "could not find expected ':'")
+				}
+			}
+			p.pos++
+			value, err = p.parseBlockNode(indent, true, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		node.children = append(node.children, key, value)
+		node.end = key.end
+		if value.end > node.end {
+			node.end = value.end
+		}
+		key = nil
+		col, ok, err := p.nextContent()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || col < indent {
+			return node, nil
+		}
+		if col > indent {
+			return nil, p.errorf(p.pos, "bad indentation of a mapping entry")
+		}
+	}
+}
+
+func (p *yamlParser) parseBlockSequence(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSequenceNode, start: p.pos}
+	for {
+		node.dashes = append(node.dashes, p.pos)
+		p.pos++
+		node.end = p.pos
+		item, err := p.parseBlockNode(indent, false, false)
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, item)
+		if item.end > node.end {
+			node.end = item.end
+		}
+		col, ok, err := p.nextContent()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || col < indent {
+			return node, nil
+		}
+		if col > indent {
+			return nil, p.errorf(p.pos, "bad indentation of a sequence entry")
+		}
+		if !p.isSeqEntry(p.pos) {
+			// the next key of a mapping holding the sequence
+			return node, nil
+		}
+	}
+}
+
+// parseInlineNode parses a node that starts and, except for flow
+// collections and quoted scalars, ends on the current line
+func (p *yamlParser) parseInlineNode() (*yamlNode, error) {
+	switch c := p.peek(); c {
+	case '[', '{':
+		return p.parseFlowCollection()
+	case '"':
+		return p.parseDoubleQuoted()
+	case '\'':
+		return p.parseSingleQuoted()
+	case '*':
+		return p.parseAlias()
+	default:
+		if !p.canStartPlain(false) {
+			return nil, p.errorf(p.pos, "found character %q that cannot start any token", c)
+		}
+	}
+	start := p.pos
+	value := p.scanPlainLine(false)
+	return &yamlNode{kind: yamlScalarNode, value: value, start: start, end: p.pos}, nil
+}
+
+func (p *yamlParser) parseAlias() (*yamlNode, error) {
+	start := p.pos
+	p.pos++
+	name := p.scanName()
+	if len(name) == 0 {
+		return nil, p.errorf(start, "alias name is empty")
+	}
+	return &yamlNode{kind: yamlAliasNode, value: name, start: start, end: p.pos}, nil
+}
+
+func (p *yamlParser) canStartPlain(flow bool) bool {
+	c := p.peek()
+	switch c {
+	case 0, ' ', '\t', '\n', ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`':
+		return false
+	case '-', '?', ':':
+		next := p.peekAt(p.pos + 1)
+		return !p.isBlankAt(p.pos+1) && !(flow && isYAMLFlowIndicator(next))
+	}
+	return true
+}
+
+// scanPlainLine scans the part of a plain scalar on the current line, up to
+// a ": ", a comment or, in flow context, a flow indicator.  Trailing blanks
+// are left unconsumed.
+func (p *yamlParser) scanPlainLine(flow bool) string {
+	start, end := p.pos, p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\n' {
+			break
+		}
+		if c == ':' && (p.isBlankAt(p.pos+1) || flow && isYAMLFlowIndicator(p.peekAt(p.pos+1))) {
+			break
+		}
+		if c == '#' && p.pos > start && p.isBlankAt(p.pos-1) {
+			break
+		}
+		if flow && isYAMLFlowIndicator(c) {
+			break
+		}
+		p.pos++
+		if c != ' ' && c != '\t' {
+			end = p.pos
+		}
+	}
+	p.pos = end
+	return p.src[start:end]
+}
+
+// skipLineBreaks skips the blanks and line breaks from offset and returns
+// the offset of the next other character and the number of line breaks
+func (p *yamlParser) skipLineBreaks(offset int) (int, int) {
+	breaks := 0
+	for offset < len(p.src) {
+		switch p.src[offset] {
+		case '\n':
+			breaks++
+		case ' ', '\t':
+		default:
+			return offset, breaks
+		}
+		offset++
+	}
+	return offset, breaks
+}
+
+func foldLineBreaks(breaks int) string {
+	if breaks == 1 {
+		return " "
+	}
+	return strings.Repeat("\n", breaks-1)
+}
+
+// continuePlain appends the continuation lines of a multi-line plain
+// scalar in block context, which must be indented more than indent
+func (p *yamlParser) continuePlain(node *yamlNode, indent int) {
+	var buf strings.Builder
+	buf.WriteString(node.value)
+	for {
+		next, breaks := p.skipLineBreaks(p.pos)
+		if breaks == 0 || next >= len(p.src) {
+			break
+		}
+		start := p.lineStart(next)
+		spaces := 0
+		for p.src[start+spaces] == ' ' {
+			spaces++
+		}
+		if spaces <= indent || p.src[next] == '#' || p.isDocumentMarker(next) {
+			break
+		}
+		save := p.pos
+		p.pos = next
+		text := p.scanPlainLine(false)
+		if len(text) == 0 {
+			p.pos = save
+			break
+		}
+		buf.WriteString(foldLineBreaks(breaks))
+		buf.WriteString(text)
+		node.end = p.pos
+	}
+	node.value = buf.String()
+}
+
+func (p *yamlParser) scanPlainFlow() *yamlNode {
+	node := &yamlNode{kind: yamlScalarNode, start: p.pos}
+	var buf strings.Builder
+	buf.WriteString(p.scanPlainLine(true))
+	node.end = p.pos
+	for {
+		next, breaks := p.skipLineBreaks(p.pos)
+		if breaks == 0 || next >= len(p.src) {
+			break
+		}
+		c := p.src[next]
+		if c == '#' || c == ':' || isYAMLFlowIndicator(c) || p.isDocumentMarker(next) {
+			break
+		}
+		p.pos = next
+		buf.WriteString(foldLineBreaks(breaks))
+		buf.WriteString(p.scanPlainLine(true))
+		node.end = p.pos
+	}
+	node.value = buf.String()
+	return node
+}
+
+// flowSkip skips blanks, line breaks and comments inside flow collections
+func (p *yamlParser) flowSkip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			p.pos++
+		case p.atComment():
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *yamlParser) parseFlowNode() (*yamlNode, error) {
+	start := p.pos
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	p.flowSkip()
+	var node *yamlNode
+	switch c := p.peek(); c {
+	case '[', '{':
+		node, err = p.parseFlowCollection()
+	case '"':
+		node, err = p.parseDoubleQuoted()
+	case '\'':
+		node, err = p.parseSingleQuoted()
+	case '*':
+		node, err = p.parseAlias()
+	case ',', ']', '}', ':':
+		if len(anchor) == 0 && len(tag) == 0 {
+			return nil, p.errorf(p.pos, "did not find expected node content")
+		}
+		node = p.emptyNode(p.pos)
+	default:
+		if !p.canStartPlain(true) {
+			return nil, p.errorf(p.pos, "found character %q that cannot start any token", c)
+		}
+		node = p.scanPlainFlow()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, p.setProperties(node, anchor, tag, start)
+}
+
+// isFlowColon reports whether the parser is at the : following the key of
+// a flow mapping entry
+func (p *yamlParser) isFlowColon(key *yamlNode) bool {
+	return p.isMappingColon(key) || p.peek() == ':' && isYAMLFlowIndicator(p.peekAt(p.pos+1))
+}
+
+// parseFlowPair parses the value of a key: value pair in a flow collection
+func (p *yamlParser) parseFlowPairValue(closing byte) (*yamlNode, error) {
+	p.pos++
+	p.flowSkip()
+	if c := p.peek(); c == ',' || c == closing {
+		return p.emptyNode(p.pos), nil
+	}
+	return p.parseFlowNode()
+}
+
+func (p *yamlParser) parseFlowCollection() (*yamlNode, error) {
+	opening := p.peek()
+	closing := byte(']')
+	node := &yamlNode{kind: yamlSequenceNode, flow: true, start: p.pos}
+	if opening == '{' {
+		closing = '}'
+		node.kind = yamlMappingNode
+	}
+	p.pos++
+	for {
+		p.flowSkip()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf(node.start, "did not find expected ',' or '%c'", closing)
+		}
+		if p.peek() == closing {
+			p.pos++
+			node.end = p.pos
+			return node, nil
+		}
+		explicit := p.peek() == '?' && p.isBlankAt(p.pos+1)
+		if explicit {
+			p.pos++
+			p.flowSkip()
+		}
+		var key, value *yamlNode
+		var err error
+		if p.peek() == ':' && (p.isBlankAt(p.pos+1) || isYAMLFlowIndicator(p.peekAt(p.pos+1))) {
+			key = p.emptyNode(p.pos)
+		} else if explicit && (p.peek() == ',' || p.peek() == closing) {
+			key = p.emptyNode(p.pos)
+		} else {
+			key, err = p.parseFlowNode()
+			if err != nil {
+				return nil, err
+			}
+		}
+		p.flowSkip()
+		if p.isFlowColon(key) {
+			value, err = p.parseFlowPairValue(closing)
+			if err != nil {
+				return nil, err
+			}
+			p.flowSkip()
+		} else if explicit || node.kind == yamlMappingNode {
+			value = p.emptyNode(key.end)
+		}
+		if node.kind == yamlMappingNode {
+			node.children = append(node.children, key, value)
+		} else if value != nil {
+			pair := &yamlNode{kind: yamlMappingNode, flow: true, start: key.start, end: value.end}
+			pair.children = []*yamlNode{key, value}
+			node.children = append(node.children, pair)
+		} else {
+			node.children = append(node.children, key)
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case closing:
+		default:
+			return nil, p.errorf(p.pos, "did not find expected ',' or '%c'", closing)
+		}
+	}
+}
+
+// foldQuotedBreak handles a line break inside a quoted scalar: the blanks
+// around it are dropped and the breaks are folded
+func (p *yamlParser) foldQuotedBreak(buf []byte, keep int) []byte {
+	for len(buf) > keep && (buf[len(buf)-1] == ' ' || buf[len(buf)-1] == '\t') {
+		buf = buf[:len(buf)-1]
+	}
+	next, breaks := p.skipLineBreaks(p.pos)
+	p.pos = next
+	return append(buf, foldLineBreaks(breaks)...)
+}
+
+func (p *yamlParser) parseSingleQuoted() (*yamlNode, error) {
+	start := p.pos
+	p.pos++
+	buf := make([]byte, 0)
+	keep := 0
+	for {
+		if p.pos >= len(p.src) {
+			return nil, p.errorf(start, "found unexpected end of stream while scanning a quoted scalar")
+		}
+		switch c := p.src[p.pos]; c {
+		case '\'':
+			if p.peekAt(p.pos+1) != '\'' {
+				p.pos++
+				return &yamlNode{kind: yamlScalarNode, style: yamlSingleQuotedStyle, value: string(buf), start: start, end: p.pos}, nil
+			}
+			buf = append(buf, '\'')
+			p.pos += 2
+		case '\n':
+			buf = p.foldQuotedBreak(buf, keep)
+			keep = len(buf)
+		default:
+			buf = append(buf, c)
+			p.pos++
+		}
+	}
+}
+
+var yamlEscapes = map[byte]string{
+	'0':  "\x00",
+	'a':  "\a",
+	'b':  "\b",
+	't':  "\t",
+	'\t': "\t",
+	'n':  "\n",
+	'v':  "\v",
+	'f':  "\f",
+	'r':  "\r",
+	'e':  "\x1b",
+	' ':  " ",
+	'"':  "\"",
+	'/':  "/",
+	'\\': "\\",
+	'N':  "\u0085",
+	'_':  "\u00a0",
+	'L':  "\u2028",
+	'P':  "\u2029",
+}
+
+var yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+func (p *yamlParser) parseDoubleQuoted() (*yamlNode, error) {
+	start := p.pos
+	p.pos++
+	buf := make([]byte, 0)
+	keep := 0
+	for {
+		if p.pos >= len(p.src) {
+			return nil, p.errorf(start, "found unexpected end of stream while scanning a quoted scalar")
+		}
+		switch c := p.src[p.pos]; c {
+		case '"':
+			p.pos++
+			return &yamlNode{kind: yamlScalarNode, style: yamlDoubleQuotedStyle, value: string(buf), start: start, end: p.pos}, nil
+		case '\\':
+			e := p.peekAt(p.pos + 1)
+			if e == '\n' {
+				// an escaped line break joins the lines without a space
+				p.pos += 2
+				p.skipSpaces()
+			} else if s, ok := yamlEscapes[e]; ok {
+				buf = append(buf, s...)
+				p.pos += 2
+			} else if n, ok := yamlHexEscapes[e]; ok {
+				if p.pos+2+n > len(p.src) {
+					return nil, p.errorf(p.pos, "found unexpected end of stream while scanning a quoted scalar")
+				}
+				code, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return nil, p.errorf(p.pos, "invalid escape sequence %s", p.src[p.pos:p.pos+2+n])
+				}
+				buf = append(buf, string(rune(code))...)
+				p.pos += 2 + n
+			} else {
+				return nil, p.errorf(p.pos, "found unknown escape character %q", e)
+			}
+			keep = len(buf)
+		case '\n':
+			buf = p.foldQuotedBreak(buf, keep)
+			keep = len(buf)
+		default:
+			buf = append(buf, c)
+			p.pos++
+		}
+	}
+}
+
+// parseBlockScalar parses a literal | or folded > scalar whose lines are
+// indented more than indent
+func (p *yamlParser) parseBlockScalar(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlScalarNode, style: yamlLiteralStyle, start: p.pos}
+	if p.peek() == '>' {
+		node.style = yamlFoldedStyle
+	}
+	p.pos++
+	chomp := byte(0)
+	increment := 0
+	for i := 0; i < 2; i++ {
+		switch c := p.peek(); {
+		case (c == '+' || c == '-') && chomp == 0:
+			chomp = c
+			p.pos++
+		case c >= '1' && c <= '9' && increment == 0:
+			increment = int(c - '0')
+			p.pos++
+		case c == '0':
+			return nil, p.errorf(p.pos, "found an indentation indicator equal to 0")
+		}
+	}
+	node.end = p.pos
+	if !p.isBlankAt(p.pos) {
+		return nil, p.errorf(p.pos, "did not find expected comment or line break")
+	}
+	if err := p.endLine(); err != nil {
+		return nil, err
+	}
+	contentIndent := -1
+	if increment > 0 {
+		contentIndent = indent + increment
+		if indent < 0 {
+			// as if the top level node were indented by 0
+			contentIndent = increment
+		}
+	}
+	lines := make([]string, 0)
+	lastBreak := false
+	for p.pos < len(p.src) {
+		start := p.pos
+		end := strings.IndexByte(p.src[start:], '\n')
+		if end < 0 {
+			end = len(p.src)
+		} else {
+			end += start
+		}
+		spaces := 0
+		for start+spaces < end && p.src[start+spaces] == ' ' {
+			spaces++
+		}
+		blank := start+spaces == end
+		if blank && (contentIndent < 0 || spaces <= contentIndent) {
+			if end == len(p.src) {
+				break
+			}
+			lines = append(lines, "")
+			p.pos = end + 1
+			continue
+		}
+		if contentIndent < 0 {
+			contentIndent = spaces
+			if contentIndent <= indent {
+				contentIndent = indent + 1
+			}
+		}
+		if spaces < contentIndent || p.isDocumentMarker(start) {
+			break
+		}
+		lines = append(lines, p.src[start+contentIndent:end])
+		node.end = end
+		lastBreak = end < len(p.src)
+		p.pos = end
+		if lastBreak {
+			p.pos++
+		}
+	}
+	n := len(lines)
+	for n > 0 && len(lines[n-1]) == 0 {
+		n--
+	}
+	trailing := len(lines) - n
+	var text string
+	if node.style == yamlLiteralStyle {
+		text = strings.Join(lines[:n], "\n")
+	} else {
+		text = foldBlockLines(lines[:n])
+	}
+	switch {
+	case chomp == '-':
+	case chomp == '+':
+		if n > 0 && lastBreak {
+			text += "\n"
+		}
+		text += strings.Repeat("\n", trailing)
+	case n > 0 && lastBreak:
+		text += "\n"
+	}
+	node.value = text
+	return node, nil
+}
+
+// foldBlockLines joins the lines of a folded scalar: a single line break
+// between two lines becomes a space, unless one of them is more indented
+func foldBlockLines(lines []string) string {
+	var buf strings.Builder
+	breaks := 0
+	first := true
+	prevMore := false
+	for _, line := range lines {
+		if len(line) == 0 {
+			breaks++
+			continue
+		}
+		more := line[0] == ' ' || line[0] == '\t'
+		switch {
+		case first:
+			buf.WriteString(strings.Repeat("\n", breaks))
+		case breaks == 0 && !more && !prevMore:
+			buf.WriteByte(' ')
+		case !more && !prevMore:
+			buf.WriteString(strings.Repeat("\n", breaks))
+		default:
+			buf.WriteString(strings.Repeat("\n", breaks+1))
+		}
+		buf.WriteString(line)
+		breaks = 0
+		first = false
+		prevMore = more
+	}
+	return buf.String()
+}
+
+type yamlStreamDocument struct {
+	root *yamlNode
+	// start is the offset of the document, at its --- marker if any
+	start int
+}
+
+// parseStream parses the documents of a YAML stream.  Directives are
+// skipped.
+func (p *yamlParser) parseStream() ([]yamlStreamDocument, error) {
+	docs := make([]yamlStreamDocument, 0)
+	for {
+		directive := -1
+		_, ok, err := p.nextContent()
+		for err == nil && ok && p.peek() == '%' {
+			directive = p.pos
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			if p.pos < len(p.src) {
+				p.pos++
+			}
+			_, ok, err = p.nextContent()
+		}
+		if err != nil {
+			return nil, err
+		}
+		explicit := p.isDocumentMarker(p.pos) && p.src[p.pos] == '-'
+		if !ok && !explicit {
+			if directive >= 0 {
+				return nil, p.errorf(directive, "did not find expected document start after directive")
+			}
+			if p.pos >= len(p.src) {
+				return docs, nil
+			}
+			// a ... marker without document
+			p.pos += 3
+			if err := p.endLine(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if directive >= 0 && !explicit {
+			return nil, p.errorf(p.pos, "did not find expected document start after directive")
+		}
+		doc := yamlStreamDocument{start: p.pos}
+		if explicit {
+			p.pos += 3
+			doc.root, err = p.parseBlockNode(-1, true, false)
+		} else {
+			doc.root, err = p.parseBlockNode(-1, false, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+		_, ok, err = p.nextContent()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, p.errorf(p.pos, "did not find expected end of document")
+		}
+		if p.isDocumentMarker(p.pos) && p.src[p.pos] == '.' {
+			p.pos += 3
+			if err := p.endLine(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+type yamlAnchor struct {
+	obj  JSONObject
+	size int
+}
+
+type yamlConverter struct {
+	parser  *yamlParser
+	opts    YAMLParseOptions
+	anchors map[string]yamlAnchor
+	// expanded counts the nodes copied by aliases
+	expanded int
+}
+
+func newYAMLConverter(p *yamlParser, opts *YAMLParseOptions) *yamlConverter {
+	c := &yamlConverter{parser: p, anchors: make(map[string]yamlAnchor)}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.MaxAliasExpansion == 0 {
+		c.opts.MaxAliasExpansion = YAMLDefaultMaxAliasExpansion
+	}
+	return c
+}
+
+func (c *yamlConverter) convert(node *yamlNode) (JSONObject, error) {
+	var obj JSONObject
+	var err error
+	switch node.kind {
+	case yamlMappingNode:
+		obj, err = c.convertMapping(node)
+	case yamlSequenceNode:
+		array := NewArray()
+		for _, child := range node.children {
+			val, err := c.convert(child)
+			if err != nil {
+				return nil, err
+			}
+			array.Add(val)
+		}
+		obj = array
+	case yamlAliasNode:
+		return c.alias(node)
+	default:
+		obj, err = c.resolveScalar(node)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(node.anchor) > 0 {
+		// a later anchor of the same name overrides the earlier one
+		c.anchors[node.anchor] = yamlAnchor{obj: obj, size: jsonNodeCount(obj)}
+	}
+	return obj, nil
+}
+
+// alias returns a copy of the value of the anchor referenced by node
+func (c *yamlConverter) alias(node *yamlNode) (JSONObject, error) {
+	if len(node.anchor) > 0 || len(node.tag) > 0 {
+		return nil, c.parser.errorf(node.start, "an alias cannot have an anchor or a tag")
+	}
+	anchor, ok := c.anchors[node.value]
+	if !ok {
+		return nil, c.parser.errorf(node.start, "unknown anchor %q referenced", node.value)
+	}
+	c.expanded += anchor.size
+	if c.opts.MaxAliasExpansion > 0 && c.expanded > c.opts.MaxAliasExpansion {
+		return nil, c.parser.errorf(node.start, "aliases expand to more than %d nodes", c.opts.MaxAliasExpansion)
+	}
+	return DeepCopy(anchor.obj), nil
+}
+
+func jsonNodeCount(o JSONObject) int {
+	count := 1
+	switch v := o.(type) {
+	case *JSONDict:
+		for _, val := range v.data {
+			count += jsonNodeCount(val)
+		}
+	case *JSONArray:
+		for _, val := range v.data {
+			count += jsonNodeCount(val)
+		}
+	}
+	return count
+}
+
+func isYAMLMergeKey(node *yamlNode) bool {
+	if node.kind != yamlScalarNode {
+		return false
+	}
+	if len(node.tag) > 0 {
+		return yamlShortTag(node.tag) == "!!merge"
+	}
+	return node.style == yamlPlainStyle && node.value == "<<"
+}
+
+// convertMapping converts a mapping to a JSONDict.  The keys of the
+// mappings merged by << keys are added unless the mapping has them itself,
+// and earlier merged mappings take precedence over later ones.
+func (c *yamlConverter) convertMapping(node *yamlNode) (JSONObject, error) {
+	dict := NewDict()
+	if c.opts.Ordered {
+		dict = NewOrderedDict()
+	}
+	merges := make([]*JSONDict, 0)
+	for i := 0; i < len(node.children); i += 2 {
+		keyNode, valNode := node.children[i], node.children[i+1]
+		if isYAMLMergeKey(keyNode) {
+			val, err := c.convert(valNode)
+			if err != nil {
+				return nil, err
+			}
+			dicts, ok := yamlMergeDicts(val)
+			if !ok {
+				return nil, c.parser.errorf(valNode.start, "map merge requires a mapping or a sequence of mappings")
+			}
+			merges = append(merges, dicts...)
+			continue
+		}
+		key, err := c.mappingKey(keyNode)
+		if err != nil {
+			return nil, err
+		}
+		if dict.Contains(key) {
+			return nil, c.parser.errorf(keyNode.start, "duplicate key %q", key)
+		}
+		val, err := c.convert(valNode)
+		if err != nil {
+			return nil, err
+		}
+		dict.Set(key, val)
+	}
+	for _, merge := range merges {
+		for _, k := range merge.Keys() {
+			if !dict.Contains(k) {
+				dict.Set(k, merge.data[k])
+			}
+		}
+	}
+	return dict, nil
+}
+
+func yamlMergeDicts(val JSONObject) ([]*JSONDict, bool) {
+	switch v := val.(type) {
+	case *JSONDict:
+		return []*JSONDict{v}, true
+	case *JSONArray:
+		dicts := make([]*JSONDict, 0, len(v.data))
+		for _, elem := range v.data {
+			dict, ok := elem.(*JSONDict)
+			if !ok {
+				return nil, false
+			}
+			dicts = append(dicts, dict)
+		}
+		return dicts, true
+	}
+	return nil, false
+}
+
+// mappingKey returns the dict key of a mapping key node.  Collections are
+// used as keys in their JSON form.
+func (c *yamlConverter) mappingKey(node *yamlNode) (string, error) {
+	if node.kind == yamlScalarNode {
+		_, err := c.convert(node)
+		return node.value, err
+	}
+	key, err := c.convert(node)
+	if err != nil {
+		return "", err
+	}
+	if str, ok := key.(*JSONString); ok {
+		return str.data, nil
+	}
+	return key.String(), nil
+}
+
+// yamlPathWalker visits the nodes of a converted document by the path of
+// their value in the JSONObject.  It follows aliases and merge keys like
+// yamlConverter, so that the values copied from anchors are visited at the
+// nodes they are copied from.
+type yamlPathWalker struct {
+	anchors map[string]*yamlNode
+	// visit is called with the key node of dict values, and nil for array
+	// items
+	visit func(path []string, key, val *yamlNode)
+}
+
+func newYAMLPathWalker(visit func(path []string, key, val *yamlNode)) *yamlPathWalker {
+	return &yamlPathWalker{anchors: make(map[string]*yamlNode), visit: visit}
+}
+
+// walk visits the values under node.  copied is set for the content of an
+// anchor visited through an alias or a merge key, whose anchors are not
+// defined again.
+func (w *yamlPathWalker) walk(node *yamlNode, path []string, copied bool) {
+	switch node.kind {
+	case yamlAliasNode:
+		if anchor, ok := w.anchors[node.value]; ok {
+			w.walk(anchor, path, true)
+		}
+		return
+	case yamlMappingNode:
+		keys := make(map[string]bool)
+		merges := make([]*yamlNode, 0)
+		for i := 0; i < len(node.children); i += 2 {
+			keyNode, valNode := node.children[i], node.children[i+1]
+			if isYAMLMergeKey(keyNode) {
+				if !copied {
+					w.define(valNode)
+				}
+				merges = append(merges, w.mergedMappings(valNode)...)
+				continue
+			}
+			if keyNode.kind != yamlScalarNode {
+				continue
+			}
+			keys[keyNode.value] = true
+			keyPath := append(path, keyNode.value)
+			w.visit(keyPath, keyNode, valNode)
+			w.walk(valNode, keyPath, copied)
+		}
+		for _, merge := range merges {
+			w.walkMerged(merge, path, keys)
+		}
+	case yamlSequenceNode:
+		for i, child := range node.children {
+			itemPath := append(path, strconv.Itoa(i))
+			w.visit(itemPath, nil, child)
+			w.walk(child, itemPath, copied)
+		}
+	}
+	if len(node.anchor) > 0 && !copied {
+		w.anchors[node.anchor] = node
+	}
+}
+
+// walkMerged visits the values of a merged mapping whose keys are not in
+// keys yet, adding them to keys
+func (w *yamlPathWalker) walkMerged(node *yamlNode, path []string, keys map[string]bool) {
+	merges := make([]*yamlNode, 0)
+	for i := 0; i < len(node.children); i += 2 {
+		keyNode, valNode := node.children[i], node.children[i+1]
+		if isYAMLMergeKey(keyNode) {
+			merges = append(merges, w.mergedMappings(valNode)...)
+			continue
+		}
+		if keyNode.kind != yamlScalarNode || keys[keyNode.value] {
+			continue
+		}
+		keys[keyNode.value] = true
+		keyPath := append(path, keyNode.value)
+		w.visit(keyPath, keyNode, valNode)
+		w.walk(valNode, keyPath, true)
+	}
+	for _, merge := range merges {
+		w.walkMerged(merge, path, keys)
+	}
+}
+
+// mergedMappings returns the mappings merged by the value of a << key
+func (w *yamlPathWalker) mergedMappings(node *yamlNode) []*yamlNode {
+	if node.kind == yamlAliasNode {
+		node = w.anchors[node.value]
+	}
+	switch {
+	case node == nil:
+		return nil
+	case node.kind == yamlSequenceNode:
+		ret := make([]*yamlNode, 0, len(node.children))
+		for _, child := range node.children {
+			ret = append(ret, w.mergedMappings(child)...)
+		}
+		return ret
+	case node.kind == yamlMappingNode:
+		return []*yamlNode{node}
+	}
+	return nil
+}
+
+// define defines the anchors under node without visiting it
+func (w *yamlPathWalker) define(node *yamlNode) {
+	for _, child := range node.children {
+		w.define(child)
+	}
+	if len(node.anchor) > 0 && node.kind != yamlAliasNode {
+		w.anchors[node.anchor] = node
+	}
+}
+
+func parseYAMLStream(str string) (*yamlParser, []yamlStreamDocument, error) {
+	p := newYAMLParser(str)
+	docs, err := p.parseStream()
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, docs, nil
+}
+
+type YAMLParseOptions struct {
+	// YAML11 additionally resolves the YAML 1.1 forms used by older
+	// configurations: yes/no/on/off booleans, octal integers with a leading
+	// 0, 0b binary integers and _ digit separators
+	YAML11 bool
+
+	// MaxAliasExpansion limits the number of nodes copied by aliases in a
+	// document, to guard against documents whose aliases nest into huge
+	// trees.  0 means YAMLDefaultMaxAliasExpansion and a negative value
+	// means no limit.
+	MaxAliasExpansion int
+
+	// SpecialFloats resolves the plain scalars .inf, -.inf and .nan to
+	// JSONFloat infinities and NaN.  They have no JSON form and their
+	// String() is not valid JSON, so by default they are strings, and
+	// !!float rejects them.
+	SpecialFloats bool
+
+	// Ordered builds ordered dicts, like ParseOrderedString, that keep the
+	// keys in their source order.  The keys added by << merge keys follow
+	// the keys of the mapping itself.
+	Ordered bool
+}
+
+const YAMLDefaultMaxAliasExpansion = 100000
+
+// ParseYAML parses a YAML document, resolving plain scalars with the YAML
+// 1.2 core schema.  Aliases are replaced by copies of the anchored values
+// and << keys merge mappings.  Errors are of type *YAMLError.
+func ParseYAML(str string) (JSONObject, error) {
+	return ParseYAMLWithOptions(str, nil)
+}
+
+// ParseYAMLWithOptions parses a YAML document.  opts may be nil for the
+// default options.
+func ParseYAMLWithOptions(str string, opts *YAMLParseOptions) (JSONObject, error) {
+	p, docs, err := parseYAMLStream(str)
+	if err != nil {
+		return nil, err
+	}
+	switch len(docs) {
+	case 0:
+		return JSONNull, nil
+	case 1:
+		return newYAMLConverter(p, opts).convert(docs[0].root)
+	}
+	return nil, p.errorf(docs[1].start, "expected a single document, use ParseYAMLDocuments for streams")
+}
+
+// ParseYAMLDocuments parses a YAML stream of documents separated by ---
+// and ... markers
+func ParseYAMLDocuments(str string) ([]JSONObject, error) {
+	return ParseYAMLDocumentsWithOptions(str, nil)
+}
+
+// ParseYAMLDocumentsWithOptions parses a YAML stream.  Anchors are local to
+// their document, and the alias expansion limit applies to each document.
+func ParseYAMLDocumentsWithOptions(str string, opts *YAMLParseOptions) ([]JSONObject, error) {
+	p, docs, err := parseYAMLStream(str)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]JSONObject, 0, len(docs))
+	for _, doc := range docs {
+		obj, err := newYAMLConverter(p, opts).convert(doc.root)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
@@ -0,0 +1,363 @@
+package jsonutils
+
+import (
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "nested block collections",
+			yaml: "a:\n  b: x\n  c:\n  - 1\n  - 2\nd:\n  - e: f\n    g: h\n  - - i\n",
+			want: `{"a": {"b": "x", "c": [1, 2]}, "d": [{"e": "f", "g": "h"}, ["i"]]}`,
+		},
+		{
+			name: "flow collections",
+			yaml: "a: {x: 1, y: [1, 'two', {z: w}]}\nb: [\n  p,\n  q, # comment\n]\nc: []\nd: {}\n",
+			want: `{"a": {"x": 1, "y": [1, "two", {"z": "w"}]}, "b": ["p", "q"], "c": [], "d": {}}`,
+		},
+		{
+			name: "single pair in flow sequence",
+			yaml: "[a: b, c]",
+			want: `[{"a": "b"}, "c"]`,
+		},
+		{
+			name: "quoted scalars",
+			yaml: "a: 'it''s # not a comment'\nb: \"tab\\t\\u00e9\\x41\"\nc: \"folded\n  line\n\n  break\"\n\"d e\": 1\n",
+			want: `{"a": "it's # not a comment", "b": "tab\téA", "c": "folded line\nbreak", "d e": 1}`,
+		},
+		{
+			name: "escaped line break",
+			yaml: "a: \"one \\\n  two\"",
+			want: `{"a": "one two"}`,
+		},
+		{
+			name: "literal chomping",
+			yaml: "clip: |\n  x\n\n\nstrip: |-\n  x\n\nkeep: |+\n  x\n\n\nend: 1\n",
+			want: `{"clip": "x\n", "strip": "x", "keep": "x\n\n\n", "end": 1}`,
+		},
+		{
+			name: "folded",
+			yaml: "a: >\n  one\n  two\n\n  three\n    more\n  four\nb: >-\n  x\n  y\n",
+			want: `{"a": "one two\nthree\n  more\nfour\n", "b": "x y"}`,
+		},
+		{
+			name: "indentation indicator",
+			yaml: "a: |2\n    leading\n  b\n",
+			want: `{"a": "  leading\nb\n"}`,
+		},
+		{
+			name: "comments",
+			yaml: "# head\na: 1 # one\nb: 'x' # two\nc:\n  # inside\n  - 1 # item\nd: e#f\n",
+			want: `{"a": 1, "b": "x", "c": [1], "d": "e#f"}`,
+		},
+		{
+			name: "tabs as separation",
+			yaml: "a:\tb\nc: [1,\t2]\n",
+			want: `{"a": "b", "c": [1, 2]}`,
+		},
+		{
+			name: "multi-line plain scalar",
+			yaml: "a: this is\n  a long\n\n  value\nb: c\n",
+			want: `{"a": "this is a long\nvalue", "b": "c"}`,
+		},
+		{
+			name: "colons and dashes in plain scalars",
+			yaml: "url: http://example.com:80/a?b=c#d\nkey:value: x\nn: -1\n",
+			want: `{"url": "http://example.com:80/a?b=c#d", "key:value": "x", "n": -1}`,
+		},
+		{
+			name: "empty values",
+			yaml: "a:\nb: ''\nc:\n- \n",
+			want: `{"a": null, "b": "", "c": [null]}`,
+		},
+		{
+			name: "explicit keys",
+			yaml: "? a\n: 1\n? b\n",
+			want: `{"a": 1, "b": null}`,
+		},
+		{
+			name: "document markers",
+			yaml: "---\na: 1\n...\n",
+			want: `{"a": 1}`,
+		},
+		{
+			name: "crlf",
+			yaml: "a: 1\r\nb:\r\n  - x\r\n",
+			want: `{"a": 1, "b": ["x"]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseYAML(c.yaml)
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			want, err := ParseString(c.want)
+			if err != nil {
+				t.Fatalf("ParseString(%s): %v", c.want, err)
+			}
+			if !got.Equals(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLScalarDocument(t *testing.T) {
+	cases := []struct {
+		yaml string
+		want JSONObject
+	}{
+		{"--- |\n  text\n", NewString("text\n")},
+		{"plain\n  folded\n", NewString("plain folded")},
+		{"# nothing\n", JSONNull},
+		{"", JSONNull},
+	}
+	for _, c := range cases {
+		got, err := ParseYAML(c.yaml)
+		if err != nil {
+			t.Errorf("ParseYAML(%q): %v", c.yaml, err)
+		} else if !got.Equals(c.want) {
+			t.Errorf("ParseYAML(%q) = %s, want %s", c.yaml, got, c.want)
+		}
+	}
+}
+
+func TestParseYAMLError(t *testing.T) {
+	cases := []struct {
+		name   string
+		yaml   string
+		line   int
+		column int
+	}{
+		{"bad indentation", "a:\n  b: 1\n   c: 2\n", 3, 5},
+		{"mapping in inline value", "a: b: c\n", 1, 5},
+		{"tab indentation", "a:\n\tb: 1\n", 2, 1},
+		{"unterminated quote", "a: 1\nb: \"abc\n", 2, 4},
+		{"unclosed flow", "a: [1, 2\n", 2, 1},
+		{"duplicate key", "a: 1\na: 2\n", 2, 1},
+		{"missing colon", "a: 1\nb\n", 2, 2},
+		{"two documents", "a: 1\n---\nb: 2\n", 2, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseYAML(c.yaml)
+			yerr, ok := err.(*YAMLError)
+			if !ok {
+				t.Fatalf("want *YAMLError, got %v", err)
+			}
+			if yerr.Line != c.line || yerr.Column != c.column {
+				t.Errorf("got %s, want line %d column %d", yerr, c.line, c.column)
+			}
+		})
+	}
+}
+
+func TestYAMLBlockStringRoundTrip(t *testing.T) {
+	for _, s := range []string{"a\nb", "a\nb\n", "a\n\n", "#\n\nx\ty\n\n"} {
+		dict := NewDict()
+		dict.Set("s", NewString(s))
+		dict.Set("z", NewString("end"))
+		got, err := ParseYAML(dict.YAMLString())
+		if err != nil {
+			t.Fatalf("ParseYAML(%q): %v", dict.YAMLString(), err)
+		}
+		if !got.Equals(dict) {
+			t.Errorf("%q: got %s", s, got)
+		}
+	}
+}
+
+func TestYAMLAnchors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "alias of a mapping",
+			yaml: "defaults: &defaults\n  cpu: 1\n  mem: 512\nweb: *defaults\n",
+			want: `{"defaults": {"cpu": 1, "mem": 512}, "web": {"cpu": 1, "mem": 512}}`,
+		},
+		{
+			name: "alias of a scalar and in flow",
+			yaml: "a: &x hello\nb: [*x, *x]\n",
+			want: `{"a": "hello", "b": ["hello", "hello"]}`,
+		},
+		{
+			name: "merge key",
+			yaml: "base: &base\n  cpu: 1\n  mem: 512\nweb:\n  <<: *base\n  mem: 1024\n",
+			want: `{"base": {"cpu": 1, "mem": 512}, "web": {"cpu": 1, "mem": 1024}}`,
+		},
+		{
+			name: "explicit keys win over merged keys in any order",
+			yaml: "base: &base {a: 1, b: 2}\nx:\n  b: 3\n  <<: *base\n",
+			want: `{"base": {"a": 1, "b": 2}, "x": {"a": 1, "b": 3}}`,
+		},
+		{
+			name: "earlier merged mappings take precedence",
+			yaml: "one: &one {a: 1}\ntwo: &two {a: 2, b: 2}\nx:\n  <<: [*one, *two]\n  c: 3\n",
+			want: `{"one": {"a": 1}, "two": {"a": 2, "b": 2}, "x": {"a": 1, "b": 2, "c": 3}}`,
+		},
+		{
+			name: "inline merge",
+			yaml: "x:\n  <<: {a: 1}\n",
+			want: `{"x": {"a": 1}}`,
+		},
+		{
+			name: "quoted << is a plain key",
+			yaml: "x:\n  '<<': {a: 1}\n",
+			want: `{"x": {"<<": {"a": 1}}}`,
+		},
+		{
+			name: "anchor redefined",
+			yaml: "- &a 1\n- *a\n- &a 2\n- *a\n",
+			want: `[1, 1, 2, 2]`,
+		},
+		{
+			name: "anchor on a nested block collection",
+			yaml: "a: &list\n  - 1\n  - 2\nb: *list\n",
+			want: `{"a": [1, 2], "b": [1, 2]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseYAML(c.yaml)
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			want, _ := ParseString(c.want)
+			if !got.Equals(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestYAMLAliasCopies(t *testing.T) {
+	got, err := ParseYAML("a: &a {x: 1}\nb: *a\n")
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	dict := got.(*JSONDict)
+	dict.data["b"].(*JSONDict).Set("x", NewInt(2))
+	if !dict.data["a"].Equals(NewDict(JSONPair{key: "x", val: NewInt(1)})) {
+		t.Errorf("changing an alias changed the anchor: %s", got)
+	}
+}
+
+func TestParseYAMLOrdered(t *testing.T) {
+	yaml := `base: &base {z: 1, a: 2}
+web:
+  <<: *base
+  name: web
+  b: {y: 1, x: 2}
+  a: 3
+`
+	obj, err := ParseYAMLWithOptions(yaml, &YAMLParseOptions{Ordered: true})
+	if err != nil {
+		t.Fatalf("ParseYAMLWithOptions: %v", err)
+	}
+	want := `{"base":{"z":1,"a":2},"web":{"name":"web","b":{"y":1,"x":2},"a":3,"z":1}}`
+	if got := obj.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	obj, _ = ParseYAML(yaml)
+	if got := obj.String(); got != `{"base":{"a":2,"z":1},"web":{"a":3,"b":{"x":2,"y":1},"name":"web","z":1}}` {
+		t.Errorf("unordered got %s", got)
+	}
+}
+
+func TestYAMLAliasErrors(t *testing.T) {
+	laughs := "a: &a [x, x, x, x, x, x, x, x, x, x]\n" +
+		"b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]\n" +
+		"c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]\n" +
+		"d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]\n"
+	cases := []struct {
+		name string
+		yaml string
+		opts *YAMLParseOptions
+		line int
+	}{
+		{"unknown anchor", "a: *nope\n", nil, 1},
+		{"merge of a scalar", "a: &a 1\nb:\n  <<: *a\n", nil, 3},
+		{"expansion limit", laughs, &YAMLParseOptions{MaxAliasExpansion: 1000}, 3},
+		{"recursive alias", "a: &a [*a]\n", nil, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseYAMLWithOptions(c.yaml, c.opts)
+			if yerr, ok := err.(*YAMLError); !ok || yerr.Line != c.line {
+				t.Errorf("got error %v, want line %d", err, c.line)
+			}
+		})
+	}
+	if _, err := ParseYAMLWithOptions(laughs, &YAMLParseOptions{MaxAliasExpansion: -1}); err != nil {
+		t.Errorf("without limit: %v", err)
+	}
+}
+
+func TestParseYAMLDocuments(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{"single bare document", "a: 1\n", []string{`{"a": 1}`}},
+		{"empty stream", "# nothing\n", []string{}},
+		{"separated documents", "a: 1\n---\nb: 2\n", []string{`{"a": 1}`, `{"b": 2}`}},
+		{"leading marker", "---\na: 1\n---\n- x\n", []string{`{"a": 1}`, `["x"]`}},
+		{"document end markers", "a: 1\n...\n---\nb: 2\n...\n", []string{`{"a": 1}`, `{"b": 2}`}},
+		{"bare document after end marker", "a: 1\n...\nb: 2\n", []string{`{"a": 1}`, `{"b": 2}`}},
+		{"empty documents", "---\n---\na: 1\n", []string{`null`, `{"a": 1}`}},
+		{"directives", "%YAML 1.2\n%TAG ! tag:example.com,2000:\n---\na: 1\n...\n%YAML 1.2\n---\nb: 2\n", []string{`{"a": 1}`, `{"b": 2}`}},
+		{"content on marker line", "--- [1, 2]\n--- text\n", []string{`[1, 2]`, `"text"`}},
+		{"anchors are local to a document", "a: &x 1\n---\n&x b: *x\n", []string{`{"a": 1}`, `{"b": "b"}`}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			docs, err := ParseYAMLDocuments(c.yaml)
+			if err != nil {
+				t.Fatalf("ParseYAMLDocuments: %v", err)
+			}
+			if len(docs) != len(c.want) {
+				t.Fatalf("got %d documents %s, want %d", len(docs), docs, len(c.want))
+			}
+			for i, want := range c.want {
+				wantObj, err := ParseYAML(want)
+				if err != nil {
+					t.Fatalf("ParseYAML(%s): %v", want, err)
+				}
+				if !docs[i].Equals(wantObj) {
+					t.Errorf("document %d: got %s, want %s", i, docs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseYAMLDocumentsError(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		line int
+	}{
+		{"directive without document start", "%YAML 1.2\na: 1\n", 2},
+		{"directive at end", "a: 1\n...\n%YAML 1.2\n", 3},
+		{"content after document end", "a: 1\n... x\n", 2},
+		{"error in second document", "a: 1\n---\nb: [\n", 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseYAMLDocuments(c.yaml)
+			if yerr, ok := err.(*YAMLError); !ok || yerr.Line != c.line {
+				t.Errorf("got error %v, want line %d", err, c.line)
+			}
+		})
+	}
+}
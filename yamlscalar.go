@@ -0,0 +1,183 @@
+package jsonutils
+
+/**
+Resolution of YAML scalars
+
+Plain scalars are resolved with the YAML 1.2 core schema:
+
+	null     ~ null Null NULL and the empty scalar
+	bool     true True TRUE false False FALSE
+	int      [-+]?[0-9]+, 0o17 octal, 0xff hexadecimal
+	float    1.5 -.5 1e3 6.02E+23
+
+The infinities and NaN .inf -.Inf .nan have no JSON form, so they are
+strings unless YAMLParseOptions.SpecialFloats makes them JSONFloat values,
+whose String() is then not valid JSON.
+
+Quoted and block scalars are strings.  The tags !!str, !!int, !!float,
+!!bool and !!null force the type of a scalar, and the non-specific tag !
+makes it a string.
+
+*/
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	yamlIntPattern     = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctPattern     = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHexPattern     = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloatPattern   = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlInfPattern     = regexp.MustCompile(`^[-+]?\.(inf|Inf|INF)$`)
+	yamlNaNPattern     = regexp.MustCompile(`^\.(nan|NaN|NAN)$`)
+	yaml11IntPattern   = regexp.MustCompile(`^[-+]?(0b[01_]+|0[0-7_]+|[1-9][0-9_]*|0x[0-9a-fA-F_]+|0)$`)
+	yaml11FloatPattern = regexp.MustCompile(`^[-+]?([0-9][0-9_]*)?\.[0-9_]*([eE][-+]?[0-9]+)?$`)
+	yaml11BoolValues   = map[string]bool{"yes": true, "Yes": true, "YES": true, "on": true, "On": true, "ON": true, "no": false, "No": false, "NO": false, "off": false, "Off": false, "OFF": false}
+	yamlCoreBoolValues = map[string]bool{"true": true, "True": true, "TRUE": true, "false": false, "False": false, "FALSE": false}
+	yamlCoreNullValues = map[string]bool{"": true, "~": true, "null": true, "Null": true, "NULL": true}
+)
+
+const yamlStandardTagBase = "tag:yaml.org,2002:"
+
+// yamlShortTag returns the !! form of the tags of the YAML tag repository
+func yamlShortTag(tag string) string {
+	if strings.HasPrefix(tag, "!<") && strings.HasSuffix(tag, ">") {
+		tag = tag[2 : len(tag)-1]
+	}
+	if strings.HasPrefix(tag, yamlStandardTagBase) {
+		return "!!" + tag[len(yamlStandardTagBase):]
+	}
+	return tag
+}
+
+// resolveYAMLPlain returns the JSONObject of a plain scalar
+func resolveYAMLPlain(value string, opts YAMLParseOptions) JSONObject {
+	if yamlCoreNullValues[value] {
+		return JSONNull
+	}
+	if b, ok := yamlCoreBoolValues[value]; ok {
+		return NewBool(b)
+	}
+	if opts.YAML11 {
+		if b, ok := yaml11BoolValues[value]; ok {
+			return NewBool(b)
+		}
+		if obj := resolveYAML11Number(value); obj != nil {
+			return obj
+		}
+	}
+	if obj := resolveYAMLNumber(value, opts.SpecialFloats); obj != nil {
+		return obj
+	}
+	return NewString(value)
+}
+
+func resolveYAMLNumber(value string, specialFloats bool) JSONObject {
+	switch {
+	case yamlIntPattern.MatchString(value):
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return NewInt(i)
+		}
+	case yamlOctPattern.MatchString(value):
+		if i, err := strconv.ParseInt(value[2:], 8, 64); err == nil {
+			return NewInt(i)
+		}
+		return nil
+	case yamlHexPattern.MatchString(value):
+		if i, err := strconv.ParseInt(value[2:], 16, 64); err == nil {
+			return NewInt(i)
+		}
+		return nil
+	case yamlInfPattern.MatchString(value):
+		if !specialFloats {
+			return nil
+		}
+		if value[0] == '-' {
+			return NewFloat(math.Inf(-1))
+		}
+		return NewFloat(math.Inf(1))
+	case yamlNaNPattern.MatchString(value):
+		if !specialFloats {
+			return nil
+		}
+		return NewFloat(math.NaN())
+	case !yamlFloatPattern.MatchString(value):
+		return nil
+	}
+	// floats and integers out of the range of int64
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return NewFloat(f)
+}
+
+func resolveYAML11Number(value string) JSONObject {
+	switch {
+	case yaml11IntPattern.MatchString(value):
+		digits := strings.Replace(value, "_", "", -1)
+		sign := ""
+		if digits[0] == '-' || digits[0] == '+' {
+			sign, digits = digits[:1], digits[1:]
+		}
+		base := 10
+		switch {
+		case strings.HasPrefix(digits, "0b"):
+			base, digits = 2, digits[2:]
+		case strings.HasPrefix(digits, "0x"):
+			base, digits = 16, digits[2:]
+		case len(digits) > 1 && digits[0] == '0':
+			base = 8
+		}
+		if i, err := strconv.ParseInt(sign+digits, base, 64); err == nil {
+			return NewInt(i)
+		}
+	case yaml11FloatPattern.MatchString(value) && value != "." && strings.ContainsAny(value, "0123456789"):
+		if f, err := strconv.ParseFloat(strings.Replace(value, "_", "", -1), 64); err == nil {
+			return NewFloat(f)
+		}
+	}
+	return nil
+}
+
+// resolveScalar returns the JSONObject of a scalar node, honoring its tag
+func (c *yamlConverter) resolveScalar(node *yamlNode) (JSONObject, error) {
+	tag := yamlShortTag(node.tag)
+	switch tag {
+	case "!", "!!str":
+		return NewString(node.value), nil
+	case "!!null":
+		if !yamlCoreNullValues[node.value] {
+			return nil, c.parser.errorf(node.start, "cannot decode %q as %s", node.value, tag)
+		}
+		return JSONNull, nil
+	case "!!bool", "!!int", "!!float":
+		obj := resolveYAMLPlain(node.value, c.opts)
+		switch obj.(type) {
+		case *JSONBool:
+			if tag == "!!bool" {
+				return obj, nil
+			}
+		case *JSONInt:
+			if tag == "!!int" {
+				return obj, nil
+			}
+			if tag == "!!float" {
+				return NewFloat(float64(obj.(*JSONInt).data)), nil
+			}
+		case *JSONFloat:
+			if tag == "!!float" {
+				return obj, nil
+			}
+		}
+		return nil, c.parser.errorf(node.start, "cannot decode %q as %s", node.value, tag)
+	}
+	if node.style != yamlPlainStyle {
+		return NewString(node.value), nil
+	}
+	return resolveYAMLPlain(node.value, c.opts), nil
+}
@@ -0,0 +1,114 @@
+package jsonutils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestYAMLScalarTyping(t *testing.T) {
+	cases := []struct {
+		name   string
+		yaml   string
+		yaml11 bool
+		want   JSONObject
+	}{
+		{"int", "3", false, NewInt(3)},
+		{"negative int", "-12", false, NewInt(-12)},
+		{"octal", "0o17", false, NewInt(15)},
+		{"hex", "0xff", false, NewInt(255)},
+		{"leading zero is decimal", "0755", false, NewInt(755)},
+		{"float", "1.5", false, NewFloat(1.5)},
+		{"exponent", "6.02E+23", false, NewFloat(6.02e23)},
+		{"leading dot", "-.5", false, NewFloat(-0.5)},
+		{"int out of range", "123456789012345678901", false, NewFloat(123456789012345678901)},
+		{"true", "True", false, JSONTrue},
+		{"false", "FALSE", false, JSONFalse},
+		{"null", "~", false, JSONNull},
+		{"Null", "Null", false, JSONNull},
+		{"yes is a string", "yes", false, NewString("yes")},
+		{"version", "1.2.3", false, NewString("1.2.3")},
+		{"mixed case", "tRUE", false, NewString("tRUE")},
+		{"double quoted", `"3"`, false, NewString("3")},
+		{"single quoted", `'true'`, false, NewString("true")},
+		{"literal", "|-\n  42", false, NewString("42")},
+		{"str tag", "!!str 3", false, NewString("3")},
+		{"non-specific tag", "! true", false, NewString("true")},
+		{"float tag on int", "!!float 3", false, NewFloat(3)},
+		{"int tag on quoted", `!!int "7"`, false, NewInt(7)},
+		{"verbatim tag", "!<tag:yaml.org,2002:str> 1", false, NewString("1")},
+		{"unknown tag", "!custom 5", false, NewInt(5)},
+		{"yaml11 yes", "yes", true, JSONTrue},
+		{"yaml11 Off", "Off", true, JSONFalse},
+		{"yaml11 octal", "0755", true, NewInt(493)},
+		{"yaml11 binary", "0b101", true, NewInt(5)},
+		{"yaml11 separators", "1_000_000", true, NewInt(1000000)},
+		{"yaml11 float separators", "1_000.5", true, NewFloat(1000.5)},
+		{"yaml11 core forms", "0x10", true, NewInt(16)},
+		{"yaml11 quoted", `"on"`, true, NewString("on")},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseYAMLWithOptions(c.yaml, &YAMLParseOptions{YAML11: c.yaml11})
+			if err != nil {
+				t.Fatalf("ParseYAML(%q): %v", c.yaml, err)
+			}
+			if jsonTypeName(got) != jsonTypeName(c.want) || !got.Equals(c.want) {
+				t.Errorf("ParseYAML(%q) = %s %s, want %s %s", c.yaml, jsonTypeName(got), got, jsonTypeName(c.want), c.want)
+			}
+		})
+	}
+}
+
+func TestYAMLSpecialFloats(t *testing.T) {
+	cases := []struct {
+		yaml  string
+		check func(float64) bool
+	}{
+		{".inf", func(f float64) bool { return math.IsInf(f, 1) }},
+		{"-.Inf", func(f float64) bool { return math.IsInf(f, -1) }},
+		{".NaN", math.IsNaN},
+	}
+	for _, c := range cases {
+		got, err := ParseYAMLWithOptions(c.yaml, &YAMLParseOptions{SpecialFloats: true})
+		if err != nil {
+			t.Fatalf("ParseYAML(%q): %v", c.yaml, err)
+		}
+		f, ok := got.(*JSONFloat)
+		if !ok || !c.check(f.data) {
+			t.Errorf("ParseYAML(%q) = %s", c.yaml, got)
+		}
+		got, err = ParseYAML(c.yaml)
+		if err != nil || !got.Equals(NewString(c.yaml)) {
+			t.Errorf("ParseYAML(%q) without SpecialFloats = %v, %v", c.yaml, got, err)
+		}
+	}
+	if _, err := ParseYAML("!!float .inf"); err == nil {
+		t.Errorf("!!float .inf without SpecialFloats should fail")
+	}
+	if got := NewString(".inf").YAMLString(); got != "'.inf'" {
+		t.Errorf(".inf string written as %s", got)
+	}
+}
+
+func TestYAMLScalarTypingError(t *testing.T) {
+	for _, yaml := range []string{"a: !!int abc", "a: !!bool 1", "a: !!null x"} {
+		_, err := ParseYAML(yaml)
+		if yerr, ok := err.(*YAMLError); !ok || yerr.Line != 1 || yerr.Column != 4 {
+			t.Errorf("ParseYAML(%q): got error %v", yaml, err)
+		}
+	}
+}
+
+func TestYAMLTypedKeysAndValues(t *testing.T) {
+	got, err := ParseYAML("replicas: 3\nenabled: true\nratio: 0.5\nname: web\n1: one\nnothing:\n")
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	want, _ := ParseString(`{"replicas": 3, "enabled": true, "ratio": 0.5, "name": "web", "1": "one", "nothing": null}`)
+	if !got.Equals(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, ok := got.(*JSONDict).data["replicas"].(*JSONInt); !ok {
+		t.Errorf("replicas is a %s", jsonTypeName(got.(*JSONDict).data["replicas"]))
+	}
+}
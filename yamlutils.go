@@ -2,170 +2,235 @@ package jsonutils
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-func parseYAMLArray(lines []string) ([]JSONObject, error) {
-	var array = make([]JSONObject, 0)
-	var indent = 1
-	for indent < len(lines[0]) && lines[0][indent] == ' ' {
-		indent++
-	}
-	var sublines []string = make([]string, 0)
+func indentLines(lines []string, is_array bool) []string {
+	var ret = make([]string, 0)
+	var first_line = true
 	for _, line := range lines {
-		if indent >= len(line) && len(strings.Trim(line, " ")) == 0 {
-			continue
-		}
-		if line[0] == '-' && len(sublines) > 0 {
-			o, e := parseYAMLLines(sublines)
-			if e != nil {
-				return array, e
-			} else {
-				array = append(array, o)
-			}
-			sublines = make([]string, 0)
-		}
-		sublines = append(sublines, line[indent:])
-	}
-	if len(sublines) > 0 {
-		o, e := parseYAMLLines(sublines)
-		if e != nil {
-			return array, e
+		if is_array && first_line {
+			ret = append(ret, "- "+line)
 		} else {
-			array = append(array, o)
+			ret = append(ret, "  "+line)
 		}
+		first_line = false
 	}
-	return array, nil
+	return ret
 }
 
-func parseYAMLJSONArray(lines []string) (*JSONArray, error) {
-	o, e := parseYAMLArray(lines)
-	if e != nil {
-		return nil, e
-	} else {
-		return &JSONArray{data: o}, nil
+func yamlLines2String(o JSONObject) string {
+	lines := o.yamlLines()
+	if str, ok := o.(*JSONString); ok && str.isYAMLBlock() {
+		lines = append(lines[:1], indentLines(lines[1:], false)...)
+	}
+	yaml := strings.Join(lines, "\n")
+	if yamlEndsWithLineBreak(o) {
+		// the line breaks kept by a final | or |+ block scalar are only
+		// read back if its last line ends with a line break
+		yaml += "\n"
 	}
+	return yaml
 }
 
-func parseYAMLDict(lines []string) (map[string]JSONObject, error) {
-	var dict = make(map[string]JSONObject)
-	var i = 0
-	for i < len(lines) {
-		if len(strings.Trim(lines[i], " ")) == 0 {
-			i++
-			continue
+// yamlEndsWithLineBreak reports whether the YAML of o ends with a block
+// scalar keeping line breaks
+func yamlEndsWithLineBreak(o JSONObject) bool {
+	switch v := o.(type) {
+	case *JSONString:
+		return v.isYAMLBlock() && strings.HasSuffix(v.data, "\n")
+	case *JSONArray:
+		return len(v.data) > 0 && yamlEndsWithLineBreak(v.data[len(v.data)-1])
+	case *JSONDict:
+		keys := v.Keys()
+		return len(keys) > 0 && yamlEndsWithLineBreak(v.data[keys[len(keys)-1]])
+	}
+	return false
+}
+
+// yamlIsPlainSafe reports whether str reads back as the same string when
+// written as a plain scalar
+func yamlIsPlainSafe(str string) bool {
+	if len(str) == 0 || str == "<<" {
+		return false
+	}
+	if _, ok := resolveYAMLPlain(str, YAMLParseOptions{YAML11: true, SpecialFloats: true}).(*JSONString); !ok {
+		// also quote the YAML 1.1 forms like yes, off and 0755, and .inf
+		// and .nan, which other parsers do not read as strings
+		return false
+	}
+	if strings.IndexByte("-?:,[]{}#&*!|>'\"%@`. ", str[0]) >= 0 && !yamlIsPlainDot(str) {
+		return false
+	}
+	if str[len(str)-1] == ' ' || str[len(str)-1] == ':' {
+		return false
+	}
+	if strings.Contains(str, ": ") || strings.Contains(str, " #") || strings.ContainsAny(str, "\t\n") {
+		return false
+	}
+	for _, r := range str {
+		if !unicode.IsPrint(r) {
+			return false
 		}
-		var keypos = strings.IndexByte(lines[i], ':')
-		if keypos <= 0 {
-			return dict, fmt.Errorf("Cannot find JSONDict key: %s", lines[i])
+	}
+	return true
+}
+
+// yamlIsPlainDot allows plain strings starting with a dot, like .gitignore,
+// except for the document end marker
+func yamlIsPlainDot(str string) bool {
+	return str[0] == '.' && !strings.HasPrefix(str, "...")
+}
+
+func yamlNeedsDoubleQuote(str string) bool {
+	for _, r := range str {
+		if r != '\t' && !unicode.IsPrint(r) || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+var yamlEscapeRunes = map[rune]string{
+	'\x00':   `\0`,
+	'\a':     `\a`,
+	'\b':     `\b`,
+	'\t':     `\t`,
+	'\n':     `\n`,
+	'\v':     `\v`,
+	'\f':     `\f`,
+	'\r':     `\r`,
+	'\x1b':   `\e`,
+	'"':      `\"`,
+	'\\':     `\\`,
+	'\u0085': `\N`,
+	'\u00a0': `\_`,
+	'\u2028': `\L`,
+	'\u2029': `\P`,
+}
+
+func yamlDoubleQuote(str string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range str {
+		if esc, ok := yamlEscapeRunes[r]; ok {
+			buf.WriteString(esc)
+		} else if unicode.IsPrint(r) && r != utf8.RuneError {
+			buf.WriteRune(r)
+		} else if r <= 0xff {
+			fmt.Fprintf(&buf, "\\x%02x", r)
+		} else if r <= 0xffff {
+			fmt.Fprintf(&buf, "\\u%04x", r)
 		} else {
-			key := lines[i][0:keypos]
-			val := strings.Trim(lines[i][keypos+1:], " ")
-
-			if len(val) > 0 && val != "|" {
-				dict[key] = NewString(val)
-				i++
-			} else {
-				sublines := make([]string, 0)
-				j := i + 1
-				for j < len(lines) && len(strings.Trim(lines[j], " ")) == 0 {
-					sublines = append(sublines, "")
-					j++
-				}
-				if j < len(lines) {
-					if lines[j][0] != ' ' {
-						return dict, fmt.Errorf("Illformat")
-					}
-
-					indent := 0
-					for indent < len(lines[j]) && lines[j][indent] == ' ' {
-						indent++
-					}
-
-					for j < len(lines) {
-						if indent >= len(lines[j]) && len(strings.Trim(lines[j], " ")) == 0 {
-							sublines = append(sublines, "")
-							j++
-						} else if indent < len(lines[j]) && len(strings.Trim(lines[j][:indent], " ")) == 0 {
-							sublines = append(sublines, lines[j][indent:])
-							j++
-						} else {
-							break
-						}
-					}
-				}
-				if val == "|" {
-					dict[key] = NewString(strings.Join(sublines, "\n"))
-				} else {
-					o, e := parseYAMLLines(sublines)
-					if e != nil {
-						return dict, e
-					}
-					dict[key] = o
-				}
-
-				i = j
-			}
+			fmt.Fprintf(&buf, "\\U%08x", r)
 		}
 	}
-	return dict, nil
+	buf.WriteByte('"')
+	return buf.String()
 }
 
-func parseYAMLJSONDict(lines []string) (*JSONDict, error) {
-	o, e := parseYAMLDict(lines)
-	if e != nil {
-		return nil, e
-	} else {
-		return &JSONDict{data: o}, nil
+func yamlSingleQuote(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+// yamlQuote returns str as a single line scalar: plain if possible, single
+// quoted if it holds only printable characters, double quoted otherwise
+func yamlQuote(str string) string {
+	switch {
+	case yamlIsPlainSafe(str):
+		return str
+	case yamlNeedsDoubleQuote(str):
+		return yamlDoubleQuote(str)
 	}
+	return yamlSingleQuote(str)
 }
 
-func parseYAMLLines(lines []string) (JSONObject, error) {
-	var i = 0
-	for i < len(lines) && (len(lines[i]) == 0 || lines[i][0] == ' ' || lines[i][0] == '#') {
-		i++
+// yamlFlowQuote is yamlQuote for scalars inside flow collections, where
+// the flow indicators end plain scalars
+func yamlFlowQuote(str string) string {
+	if yamlIsPlainSafe(str) && !strings.ContainsAny(str, ",[]{}:") {
+		return str
 	}
-	if i >= len(lines) {
-		return nil, fmt.Errorf("invalid yaml")
+	if yamlNeedsDoubleQuote(str) {
+		return yamlDoubleQuote(str)
 	}
-	if lines[i][0] == '-' {
-		return parseYAMLJSONArray(lines[i:])
-	} else {
-		var keypos = strings.IndexByte(lines[i], ':')
-		if keypos <= 0 {
-			return &JSONString{data: strings.Join(lines[i:], "\n")}, nil
-		} else {
-			return parseYAMLJSONDict(lines[i:])
+	return yamlSingleQuote(str)
+}
+
+// yamlFlowString returns o as a single line flow node, with the dict keys
+// in sorted order if sortKeys is set and in the order of Keys() otherwise
+func yamlFlowString(o JSONObject, sortKeys bool) string {
+	switch v := o.(type) {
+	case *JSONString:
+		return yamlFlowQuote(v.data)
+	case *JSONArray:
+		items := make([]string, len(v.data))
+		for i, item := range v.data {
+			items[i] = yamlFlowString(item, sortKeys)
 		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *JSONDict:
+		items := make([]string, 0, len(v.data))
+		keys := v.Keys()
+		if sortKeys {
+			keys = append([]string{}, keys...)
+			sort.Strings(keys)
+		}
+		for _, key := range keys {
+			items = append(items, yamlFlowQuote(key)+": "+yamlFlowString(v.data[key], sortKeys))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
 	}
+	return o.yamlLines()[0]
 }
 
-func ParseYAML(str string) (JSONObject, error) {
-	lines := strings.Split(str, "\n")
-	return parseYAMLLines(lines)
+// yamlUseBlock reports whether str is written as a literal block scalar,
+// which holds multi-line strings of printable characters
+func yamlUseBlock(str string) bool {
+	return strings.Contains(str, "\n") && len(strings.Trim(str, "\n")) > 0 && !yamlNeedsDoubleQuote(strings.Replace(str, "\n", "", -1))
 }
 
-func indentLines(lines []string, is_array bool) []string {
-	var ret = make([]string, 0)
-	var first_line = true
+// yamlBlockLines returns the indicator of the literal block scalar of str,
+// with the chomping keeping its trailing line breaks, and the lines of str
+func yamlBlockLines(str string) (string, []string) {
+	var indicator string
+	var lines []string
+	switch {
+	case !strings.HasSuffix(str, "\n"):
+		indicator, lines = "|-", strings.Split(str, "\n")
+	case strings.HasSuffix(str, "\n\n"):
+		indicator, lines = "|+", strings.Split(str[:len(str)-1], "\n")
+	default:
+		indicator, lines = "|", strings.Split(str[:len(str)-1], "\n")
+	}
 	for _, line := range lines {
-		if is_array && first_line {
-			ret = append(ret, "- "+line)
-		} else {
-			ret = append(ret, "  "+line)
+		if len(line) > 0 {
+			if line[0] == ' ' {
+				// the indentation cannot be detected from the first line
+				indicator = indicator[:1] + "2" + indicator[1:]
+			}
+			break
 		}
-		first_line = false
 	}
-	return ret
+	return indicator, lines
 }
 
-func yamlLines2String(o JSONObject) string {
-	lines := o.yamlLines()
-	return strings.Join(lines, "\n")
+func (this *JSONString) isYAMLBlock() bool {
+	return yamlUseBlock(this.data)
 }
 
+// yamlLines of a multi-line string are the indicator of the block scalar
+// followed by its lines, which the container indents
 func (this *JSONString) yamlLines() []string {
-	return strings.Split(this.data, "\n")
+	if !this.isYAMLBlock() {
+		return []string{yamlQuote(this.data)}
+	}
+	indicator, lines := yamlBlockLines(this.data)
+	return append([]string{indicator}, lines...)
 }
 
 func (this *JSONValue) yamlLines() []string {
@@ -177,6 +242,14 @@ func (this *JSONInt) yamlLines() []string {
 }
 
 func (this *JSONFloat) yamlLines() []string {
+	switch {
+	case math.IsNaN(this.data):
+		return []string{".nan"}
+	case math.IsInf(this.data, 1):
+		return []string{".inf"}
+	case math.IsInf(this.data, -1):
+		return []string{"-.inf"}
+	}
 	return []string{this.String()}
 }
 
@@ -185,9 +258,19 @@ func (this *JSONBool) yamlLines() []string {
 }
 
 func (this *JSONArray) yamlLines() []string {
+	if len(this.data) == 0 {
+		return []string{"[]"}
+	}
 	var ret = make([]string, 0)
 	for _, o := range this.data {
-		for _, line := range indentLines(o.yamlLines(), true) {
+		lines := o.yamlLines()
+		if str, ok := o.(*JSONString); ok && str.isYAMLBlock() {
+			ret = append(ret, "- "+lines[0])
+			lines = indentLines(lines[1:], false)
+		} else {
+			lines = indentLines(lines, true)
+		}
+		for _, line := range lines {
 			ret = append(ret, line)
 		}
 	}
@@ -195,28 +278,25 @@ func (this *JSONArray) yamlLines() []string {
 }
 
 func (this *JSONDict) yamlLines() []string {
+	if len(this.data) == 0 {
+		return []string{"{}"}
+	}
 	var ret = make([]string, 0)
-	for _, key := range this.SortedKeys() {
+	for _, key := range this.Keys() {
 		val := this.data[key]
-		if val.IsZero() {
-			switch val.(type) {
-			case *JSONString, *JSONDict, *JSONArray, *JSONValue:
-				continue
-			}
-		}
 		lines := val.yamlLines()
-		if !val.isCompond() && len(lines) == 1 {
-			ret = append(ret, fmt.Sprintf("%s: %s", key, lines[0]))
+		if str, ok := val.(*JSONString); ok && str.isYAMLBlock() {
+			ret = append(ret, fmt.Sprintf("%s: %s", yamlQuote(key), lines[0]))
+			lines = lines[1:]
+		} else if (!val.isCompond() || val.IsZero()) && len(lines) == 1 {
+			// scalars and the empty collections {} and []
+			ret = append(ret, fmt.Sprintf("%s: %s", yamlQuote(key), lines[0]))
+			continue
 		} else {
-			switch val.(type) {
-			case *JSONString:
-				ret = append(ret, fmt.Sprintf("%s: |", key))
-			default:
-				ret = append(ret, fmt.Sprintf("%s:", key))
-			}
-			for _, line := range indentLines(lines, false) {
-				ret = append(ret, line)
-			}
+			ret = append(ret, fmt.Sprintf("%s:", yamlQuote(key)))
+		}
+		for _, line := range indentLines(lines, false) {
+			ret = append(ret, line)
 		}
 	}
 	return ret
@@ -249,3 +329,18 @@ func (this *JSONArray) YAMLString() string {
 func (this *JSONDict) YAMLString() string {
 	return yamlLines2String(this)
 }
+
+// YAMLDocumentsString returns the YAML stream of docs, each document
+// starting with a --- marker
+func YAMLDocumentsString(docs []JSONObject) string {
+	var buf strings.Builder
+	for _, doc := range docs {
+		buf.WriteString("---\n")
+		yaml := doc.YAMLString()
+		buf.WriteString(yaml)
+		if !strings.HasSuffix(yaml, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
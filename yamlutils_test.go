@@ -95,3 +95,132 @@ func TestYaml(t *testing.T) {
 	}
 
 }
+
+func TestYAMLDocumentsString(t *testing.T) {
+	docs := []JSONObject{
+		NewDict(JSONPair{key: "a", val: NewInt(1)}),
+		NewArray(NewString("x"), NewString("y")),
+		JSONNull,
+	}
+	want := "---\na: 1\n---\n- x\n- y\n---\nnull\n"
+	got := YAMLDocumentsString(docs)
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	parsed, err := ParseYAMLDocuments(got)
+	if err != nil {
+		t.Fatalf("ParseYAMLDocuments: %v", err)
+	}
+	if len(parsed) != len(docs) {
+		t.Fatalf("got %d documents", len(parsed))
+	}
+	for i := range docs {
+		if !parsed[i].Equals(docs[i]) {
+			t.Errorf("document %d: got %s, want %s", i, parsed[i], docs[i])
+		}
+	}
+}
+
+func TestYAMLStringQuoting(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"http://example.com/a?b=c", "http://example.com/a?b=c"},
+		{"a#b", "a#b"},
+		{".gitignore", ".gitignore"},
+		{"", "''"},
+		{"123", "'123'"},
+		{"1.5", "'1.5'"},
+		{"true", "'true'"},
+		{"yes", "'yes'"},
+		{"0755", "'0755'"},
+		{"null", "'null'"},
+		{"~", "'~'"},
+		{"<<", "'<<'"},
+		{"a: b", "'a: b'"},
+		{"a #b", "'a #b'"},
+		{"- x", "'- x'"},
+		{"*ref", "'*ref'"},
+		{"&anchor", "'&anchor'"},
+		{"!tag", "'!tag'"},
+		{"#comment", "'#comment'"},
+		{"key:", "'key:'"},
+		{"---", "'---'"},
+		{" lead", "' lead'"},
+		{"trail ", "'trail '"},
+		{"it's", "it's"},
+		{"'quoted'", "'''quoted'''"},
+		{`"dq"`, `'"dq"'`},
+		{"tab\there", "'tab\there'"},
+		{"ctl\x01", `"ctl\x01"`},
+		{"cr\r\nlf", `"cr\r\nlf"`},
+		{"\n", `"\n"`},
+		{"two\nlines", "|-\n  two\n  lines"},
+		{"two\nlines\n", "|\n  two\n  lines\n"},
+		{"keep\n\n", "|+\n  keep\n  \n"},
+		{" indented\nfirst\n", "|2\n   indented\n  first\n"},
+	}
+	for _, c := range cases {
+		got := NewString(c.in).YAMLString()
+		if got != c.want {
+			t.Errorf("YAMLString(%q) = %q, want %q", c.in, got, c.want)
+		}
+		back, err := ParseYAML(got)
+		if err != nil {
+			t.Errorf("ParseYAML(%q): %v", got, err)
+		} else if !back.Equals(NewString(c.in)) {
+			t.Errorf("YAMLString(%q) = %q reads back as %s", c.in, got, back)
+		}
+	}
+}
+
+func TestYAMLStringRoundTrip(t *testing.T) {
+	strs := []string{"a: b", "- x", "true", "123", " lead", "keep\n\n", " indented\nfirst\n", "\nleading", "trailing\n  ", "ctl\x01", "é"}
+	dict := NewDict()
+	arr := NewArray()
+	for _, s := range strs {
+		dict.Set(s, NewString(s))
+		arr.Add(NewString(s))
+	}
+	obj := NewDict(
+		JSONPair{key: "map", val: dict},
+		JSONPair{key: "list", val: arr},
+		JSONPair{key: "nested", val: NewArray(NewArray(NewString("x\n"), NewDict(), NewArray()), NewDict(JSONPair{key: "k", val: NewString(" y\nz")}))},
+		JSONPair{key: "numbers", val: NewArray(NewInt(1), NewFloat(2.5), JSONTrue, JSONNull)},
+	)
+	yaml := obj.YAMLString()
+	back, err := ParseYAML(yaml)
+	if err != nil {
+		t.Fatalf("ParseYAML: %v\n%s", err, yaml)
+	}
+	if !back.Equals(obj) {
+		t.Errorf("got %s\nwant %s\nyaml:\n%s", back, obj, yaml)
+	}
+}
+
+func TestYAMLStringEmptyValues(t *testing.T) {
+	cases := []struct {
+		json string
+		want string
+	}{
+		{`{"k": {}}`, "k: {}"},
+		{`{"a": {"b": []}}`, "a:\n  b: []"},
+		{`{"s": "", "n": null, "z": 0, "f": false}`, "f: false\nn: null\ns: ''\nz: 0"},
+		{`[{}, [], "", null]`, "- {}\n- []\n- ''\n- null"},
+	}
+	for _, c := range cases {
+		obj, _ := ParseString(c.json)
+		if got := obj.YAMLString(); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.json, got, c.want)
+		}
+		if got := YAMLStringWithOptions(obj, nil); got != c.want {
+			t.Errorf("%s: YAMLStringWithOptions got %q, want %q", c.json, got, c.want)
+		}
+		back, err := ParseYAML(c.want)
+		if err != nil || !back.Equals(obj) {
+			t.Errorf("%s: read back %v, %v", c.json, back, err)
+		}
+	}
+}